@@ -0,0 +1,175 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
+	"github.com/urfave/cli/v2"
+)
+
+// graphOutput is where and how the dependency graphs are written and
+// analysed, as configured by the --dfg.* flags.
+type graphOutput struct {
+	path     string // file for run, directory for statetest, empty for none
+	format   string
+	level    vm.GraphLevel
+	exporter vm.GraphExporter
+	stats    *analytics.Config // nil if no analytics are reported
+	taint    *taint.Config     // nil if no taint findings are reported
+
+	valueFlow bool // whether the graph leaves out the stack shuffling
+}
+
+// newGraphOutput returns the graph output configured by the flags, or nil if
+// none of --dfg.out, --dfg.stats and --dfg.taint is set.
+func newGraphOutput(ctx *cli.Context) (*graphOutput, error) {
+	if ctx.String(DFGOutFlag.Name) == "" && !ctx.Bool(DFGStatsFlag.Name) && !ctx.Bool(DFGTaintFlag.Name) {
+		return nil, nil
+	}
+	out := &graphOutput{
+		path:      ctx.String(DFGOutFlag.Name),
+		format:    ctx.String(DFGFormatFlag.Name),
+		valueFlow: ctx.Bool(DFGValueFlowFlag.Name),
+	}
+	var err error
+	if out.level, err = vm.ParseGraphLevel(ctx.String(DFGLevelFlag.Name)); err != nil {
+		return nil, err
+	}
+	if out.exporter, err = vm.NewGraphExporter(out.format); err != nil {
+		return nil, err
+	}
+	if ctx.Bool(DFGStatsFlag.Name) {
+		weight, err := analytics.ParseWeight(ctx.String(DFGWeightFlag.Name))
+		if err != nil {
+			return nil, err
+		}
+		out.stats = &analytics.Config{Weight: weight}
+	}
+	if ctx.Bool(DFGTaintFlag.Name) {
+		config, err := taint.NewConfig(ctx.StringSlice(DFGTaintSourcesFlag.Name), ctx.StringSlice(DFGTaintSinksFlag.Name))
+		if err != nil {
+			return nil, err
+		}
+		out.taint = &config
+	}
+	return out, nil
+}
+
+// configure makes the EVM build the graph, recorded through the returned
+// recorder.
+func (o *graphOutput) configure(cfg *vm.Config) *graphRecorder {
+	recorder := &graphRecorder{tracer: cfg.Tracer}
+	cfg.Tracer = recorder
+	cfg.EnableDFG = true
+	cfg.DFGValueFlow = o.valueFlow
+	return recorder
+}
+
+// write exports the graph of the recorded execution to the file at path. There
+// is nothing to write if the execution never started, like for invalid
+// transactions, or if no output was asked for.
+func (o *graphOutput) write(path string, recorder *graphRecorder) error {
+	if recorder.env == nil || o.path == "" {
+		return nil
+	}
+	return vm.ExportGraphFile(path, recorder.env.Graph.Condense(o.level), o.exporter)
+}
+
+// analyze returns the parallelism metrics of the graph of the recorded
+// execution, or nil if there is none or they were not asked for.
+func (o *graphOutput) analyze(recorder *graphRecorder) *analytics.Report {
+	if recorder.env == nil || o.stats == nil {
+		return nil
+	}
+	return analytics.Analyze(recorder.env.Graph.Condense(o.level), *o.stats)
+}
+
+// findings returns where the labeled values of the recorded execution reached
+// a sink, or nil if there is no execution or they were not asked for. The
+// taint flows between instructions, whatever the level.
+func (o *graphOutput) findings(recorder *graphRecorder) *taint.Report {
+	if recorder.env == nil || o.taint == nil {
+		return nil
+	}
+	return taint.Analyze(recorder.env.Graph, *o.taint)
+}
+
+// graphRecorder gets hold of the EVM the execution runs on, to read its
+// dependency graph once done. The events are passed on to the tracer it
+// wraps, if any.
+type graphRecorder struct {
+	tracer vm.EVMLogger
+	env    *vm.EVM
+}
+
+func (r *graphRecorder) NeedsGraph() bool {
+	return true
+}
+
+func (r *graphRecorder) CaptureTxStart(gasLimit uint64) {
+	if r.tracer != nil {
+		r.tracer.CaptureTxStart(gasLimit)
+	}
+}
+
+func (r *graphRecorder) CaptureTxEnd(restGas uint64) {
+	if r.tracer != nil {
+		r.tracer.CaptureTxEnd(restGas)
+	}
+}
+
+func (r *graphRecorder) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	r.env = env
+	if r.tracer != nil {
+		r.tracer.CaptureStart(env, from, to, create, input, gas, value)
+	}
+}
+
+func (r *graphRecorder) CaptureEnd(output []byte, gasUsed uint64, err error) {
+	if r.tracer != nil {
+		r.tracer.CaptureEnd(output, gasUsed, err)
+	}
+}
+
+func (r *graphRecorder) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	if r.tracer != nil {
+		r.tracer.CaptureEnter(typ, from, to, input, gas, value)
+	}
+}
+
+func (r *graphRecorder) CaptureExit(output []byte, gasUsed uint64, err error) {
+	if r.tracer != nil {
+		r.tracer.CaptureExit(output, gasUsed, err)
+	}
+}
+
+func (r *graphRecorder) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if r.tracer != nil {
+		r.tracer.CaptureState(pc, op, gas, cost, scope, rData, depth, err)
+	}
+}
+
+func (r *graphRecorder) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+	if r.tracer != nil {
+		r.tracer.CaptureFault(pc, op, gas, cost, scope, depth, err)
+	}
+}
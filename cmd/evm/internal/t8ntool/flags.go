@@ -21,6 +21,8 @@ import (
 	"strings"
 
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
 	"github.com/ethereum/go-ethereum/tests"
 	"github.com/urfave/cli/v2"
 )
@@ -152,4 +154,51 @@ var (
 		Usage: "sets the verbosity level",
 		Value: 3,
 	}
+	DFGFlag = &cli.BoolFlag{
+		Name:  "dfg",
+		Usage: "Execute the transactions on the dependency-tracking interpreter path",
+	}
+	DFGOutFlag = &cli.StringFlag{
+		Name: "dfg.out",
+		Usage: "Enables the dependency graph and writes it to this directory, within --output.basedir: " +
+			"one file per transaction and the transaction DAG of the block",
+	}
+	DFGFormatFlag = &cli.StringFlag{
+		Name:  "dfg.format",
+		Usage: fmt.Sprintf("Format of the dependency graph files (%s)", strings.Join(vm.GraphFormats, ", ")),
+		Value: "json",
+	}
+	DFGLevelFlag = &cli.StringFlag{
+		Name:  "dfg.level",
+		Usage: "Vertices of the transaction graphs: one per instruction, call or tx",
+		Value: vm.InstructionLevel.String(),
+	}
+	DFGStatsFlag = &cli.BoolFlag{
+		Name: "dfg.stats",
+		Usage: "Reports the parallelism of the block and transaction graphs (critical path, width, speedup) " +
+			"to stats.json, within --dfg.out",
+	}
+	DFGWeightFlag = &cli.StringFlag{
+		Name:  "dfg.weight",
+		Usage: "Cost of the vertices in the parallelism report (count, gas)",
+		Value: analytics.ByCount.String(),
+	}
+	DFGValueFlowFlag = &cli.BoolFlag{
+		Name:  "dfg.valueflow",
+		Usage: "Leaves PUSH, DUP, SWAP and POP out of the dependency graphs",
+	}
+	DFGTaintFlag = &cli.BoolFlag{
+		Name: "dfg.taint",
+		Usage: "Reports the labeled values of every transaction reaching a jump, call, storage write or self-destruct " +
+			"to taint.json, within --dfg.out",
+	}
+	DFGTaintSourcesFlag = &cli.StringSliceFlag{
+		Name: "dfg.taint.sources",
+		Usage: fmt.Sprintf("Values labeled by the taint report: calldata, returndata:<addr>, storage:<addr>[:<slot>] or an opcode (default %s)",
+			strings.Join(taint.DefaultSources, ",")),
+	}
+	DFGTaintSinksFlag = &cli.StringSliceFlag{
+		Name:  "dfg.taint.sinks",
+		Usage: "Operands reported by the taint report: jump, call, sstore, selfdestruct, delegatecall (default all)",
+	}
 )
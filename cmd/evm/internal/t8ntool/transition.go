@@ -31,6 +31,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
 	"github.com/ethereum/go-ethereum/eth/tracers/logger"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -174,8 +176,45 @@ func Transition(ctx *cli.Context) error {
 	}
 	prestate.Env = *inputData.Env
 
+	var (
+		dfgOut      = ctx.String(DFGOutFlag.Name)
+		dfgLevel    vm.GraphLevel
+		dfgExporter vm.GraphExporter
+		dfgStats    *analytics.Config
+		dfgTaint    *taint.Config
+	)
+	if dfgOut != "" {
+		if dfgLevel, err = vm.ParseGraphLevel(ctx.String(DFGLevelFlag.Name)); err != nil {
+			return NewError(ErrorConfig, err)
+		}
+		if dfgExporter, err = vm.NewGraphExporter(ctx.String(DFGFormatFlag.Name)); err != nil {
+			return NewError(ErrorConfig, err)
+		}
+	}
+	if ctx.Bool(DFGStatsFlag.Name) {
+		if dfgOut == "" {
+			return NewError(ErrorConfig, fmt.Errorf("--%s requires --%s", DFGStatsFlag.Name, DFGOutFlag.Name))
+		}
+		weight, err := analytics.ParseWeight(ctx.String(DFGWeightFlag.Name))
+		if err != nil {
+			return NewError(ErrorConfig, err)
+		}
+		dfgStats = &analytics.Config{Weight: weight}
+	}
+	if ctx.Bool(DFGTaintFlag.Name) {
+		if dfgOut == "" {
+			return NewError(ErrorConfig, fmt.Errorf("--%s requires --%s", DFGTaintFlag.Name, DFGOutFlag.Name))
+		}
+		config, err := taint.NewConfig(ctx.StringSlice(DFGTaintSourcesFlag.Name), ctx.StringSlice(DFGTaintSinksFlag.Name))
+		if err != nil {
+			return NewError(ErrorConfig, err)
+		}
+		dfgTaint = &config
+	}
 	vmConfig := vm.Config{
-		Tracer: tracer,
+		Tracer:       tracer,
+		EnableDFG:    ctx.Bool(DFGFlag.Name) || dfgOut != "",
+		DFGValueFlow: ctx.Bool(DFGValueFlowFlag.Name),
 	}
 	// Construct the chainconfig
 	var chainConfig *params.ChainConfig
@@ -204,10 +243,16 @@ func Transition(ctx *cli.Context) error {
 		return err
 	}
 	// Run the test and aggregate the result
-	s, result, body, err := prestate.Apply(vmConfig, chainConfig, txIt, ctx.Int64(RewardFlag.Name), getTracer)
+	s, result, body, graph, err := prestate.Apply(vmConfig, chainConfig, txIt, ctx.Int64(RewardFlag.Name), getTracer)
 	if err != nil {
 		return err
 	}
+	if dfgOut != "" {
+		dir := path.Join(baseDir, dfgOut)
+		if err := saveGraphs(dir, ctx.String(DFGFormatFlag.Name), dfgLevel, dfgExporter, dfgStats, dfgTaint, result, graph); err != nil {
+			return err
+		}
+	}
 	// Dump the excution result
 	collector := make(Alloc)
 	s.DumpToCollector(collector, nil)
@@ -331,6 +376,59 @@ func saveFile(baseDir, filename string, data interface{}) error {
 	return nil
 }
 
+// graphStats is the parallelism report of a block, written to stats.json.
+type graphStats struct {
+	Block *analytics.Report   `json:"block"` // Transaction DAG of the block
+	Txs   []*analytics.Report `json:"txs"`   // Graphs of the transactions, at the requested level
+}
+
+// saveGraphs writes the dependency graph of every transaction of the block, at
+// the given level, and the transaction DAG of the block to files in dir. Their
+// parallelism is reported to stats.json as well if stats is set, and the taint
+// findings of every transaction to taint.json if taintConfig is set.
+func saveGraphs(dir, format string, level vm.GraphLevel, exporter vm.GraphExporter, stats *analytics.Config, taintConfig *taint.Config, result *ExecutionResult, graph *vm.DependencyGraph) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return NewError(ErrorIO, fmt.Errorf("failed creating graph directory: %v", err))
+	}
+	save := func(name string, g *vm.DependencyGraph) error {
+		if err := vm.ExportGraphFile(path.Join(dir, name), g, exporter); err != nil {
+			return NewError(ErrorIO, fmt.Errorf("failed writing graph %s: %v", name, err))
+		}
+		return nil
+	}
+	var (
+		report   = new(graphStats)
+		findings = make([]*taint.Report, 0, len(result.Receipts))
+	)
+	for i, receipt := range result.Receipts {
+		txGraph := graph.TxGraph(i)
+		if taintConfig != nil {
+			findings = append(findings, taint.Analyze(txGraph, *taintConfig))
+		}
+		txGraph = txGraph.Condense(level)
+		if err := save(fmt.Sprintf("tx-%d-%v.%s", i, receipt.TxHash.String(), format), txGraph); err != nil {
+			return err
+		}
+		if stats != nil {
+			report.Txs = append(report.Txs, analytics.Analyze(txGraph, *stats))
+		}
+	}
+	if taintConfig != nil {
+		if err := saveFile(dir, "taint.json", findings); err != nil {
+			return err
+		}
+	}
+	blockGraph := graph.Condense(vm.TxLevel)
+	if err := save("block."+format, blockGraph); err != nil {
+		return err
+	}
+	if stats == nil {
+		return nil
+	}
+	report.Block = analytics.Analyze(blockGraph, *stats)
+	return saveFile(dir, "stats.json", report)
+}
+
 // dispatchOutput writes the output data to either stderr or stdout, or to the specified
 // files
 func dispatchOutput(ctx *cli.Context, baseDir string, result *ExecutionResult, alloc Alloc, body hexutil.Bytes) error {
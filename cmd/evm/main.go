@@ -21,8 +21,12 @@ import (
 	"fmt"
 	"math/big"
 	"os"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
 	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/urfave/cli/v2"
@@ -134,6 +138,59 @@ var (
 		Usage:    "enable return data output",
 		Category: flags.VMCategory,
 	}
+	DFGFlag = &cli.BoolFlag{
+		Name:     "dfg",
+		Usage:    "execute on the dependency-tracking interpreter path",
+		Category: flags.VMCategory,
+	}
+	DFGOutFlag = &cli.StringFlag{
+		Name:     "dfg.out",
+		Usage:    "enables the dependency graph and writes it to this file (run) or directory (statetest)",
+		Category: flags.VMCategory,
+	}
+	DFGFormatFlag = &cli.StringFlag{
+		Name:     "dfg.format",
+		Usage:    fmt.Sprintf("format of the dependency graph (%s)", strings.Join(vm.GraphFormats, ", ")),
+		Value:    "json",
+		Category: flags.VMCategory,
+	}
+	DFGLevelFlag = &cli.StringFlag{
+		Name:     "dfg.level",
+		Usage:    "vertices of the dependency graph: one per instruction, call or tx",
+		Value:    vm.InstructionLevel.String(),
+		Category: flags.VMCategory,
+	}
+	DFGStatsFlag = &cli.BoolFlag{
+		Name:     "dfg.stats",
+		Usage:    "enables the dependency graph and reports its parallelism (critical path, width, speedup)",
+		Category: flags.VMCategory,
+	}
+	DFGWeightFlag = &cli.StringFlag{
+		Name:     "dfg.weight",
+		Usage:    "cost of the vertices in the parallelism report (count, gas)",
+		Value:    analytics.ByCount.String(),
+		Category: flags.VMCategory,
+	}
+	DFGValueFlowFlag = &cli.BoolFlag{
+		Name:     "dfg.valueflow",
+		Usage:    "leaves PUSH, DUP, SWAP and POP out of the dependency graph",
+		Category: flags.VMCategory,
+	}
+	DFGTaintFlag = &cli.BoolFlag{
+		Name:     "dfg.taint",
+		Usage:    "enables the dependency graph and reports the labeled values reaching a jump, call, storage write or self-destruct",
+		Category: flags.VMCategory,
+	}
+	DFGTaintSourcesFlag = &cli.StringSliceFlag{
+		Name:     "dfg.taint.sources",
+		Usage:    fmt.Sprintf("values labeled by the taint report: calldata, returndata:<addr>, storage:<addr>[:<slot>] or an opcode (default %s)", strings.Join(taint.DefaultSources, ",")),
+		Category: flags.VMCategory,
+	}
+	DFGTaintSinksFlag = &cli.StringSliceFlag{
+		Name:     "dfg.taint.sinks",
+		Usage:    "operands reported by the taint report: jump, call, sstore, selfdestruct, delegatecall (default all)",
+		Category: flags.VMCategory,
+	}
 )
 
 var stateTransitionCommand = &cli.Command{
@@ -159,6 +216,16 @@ var stateTransitionCommand = &cli.Command{
 		t8ntool.ChainIDFlag,
 		t8ntool.RewardFlag,
 		t8ntool.VerbosityFlag,
+		t8ntool.DFGFlag,
+		t8ntool.DFGOutFlag,
+		t8ntool.DFGFormatFlag,
+		t8ntool.DFGLevelFlag,
+		t8ntool.DFGStatsFlag,
+		t8ntool.DFGWeightFlag,
+		t8ntool.DFGValueFlowFlag,
+		t8ntool.DFGTaintFlag,
+		t8ntool.DFGTaintSourcesFlag,
+		t8ntool.DFGTaintSinksFlag,
 	},
 }
 
@@ -205,6 +272,16 @@ var vmFlags = []cli.Flag{
 	GenesisFlag,
 	SenderFlag,
 	ReceiverFlag,
+	DFGFlag,
+	DFGOutFlag,
+	DFGFormatFlag,
+	DFGLevelFlag,
+	DFGStatsFlag,
+	DFGWeightFlag,
+	DFGValueFlowFlag,
+	DFGTaintFlag,
+	DFGTaintSourcesFlag,
+	DFGTaintSinksFlag,
 }
 
 // traceFlags contains flags that configure tracing output.
@@ -211,6 +211,10 @@ func runCmd(ctx *cli.Context) error {
 		}
 		code = common.Hex2Bytes(bin)
 	}
+	dfgOut, err := newGraphOutput(ctx)
+	if err != nil {
+		return err
+	}
 	runtimeConfig := runtime.Config{
 		Origin:      sender,
 		State:       statedb,
@@ -224,10 +228,16 @@ func runCmd(ctx *cli.Context) error {
 		BlobHashes:  blobHashes,
 		BlobBaseFee: blobBaseFee,
 		EVMConfig: vm.Config{
-			Tracer: tracer,
+			Tracer:    tracer,
+			EnableDFG: ctx.Bool(DFGFlag.Name),
 		},
 	}
 
+	var recorder *graphRecorder
+	if dfgOut != nil {
+		recorder = dfgOut.configure(&runtimeConfig.EVMConfig)
+	}
+
 	if chainConfig != nil {
 		runtimeConfig.ChainConfig = chainConfig
 	} else {
@@ -270,6 +280,12 @@ func runCmd(ctx *cli.Context) error {
 	bench := ctx.Bool(BenchFlag.Name)
 	output, leftOverGas, stats, err := timedExec(bench, execFunc)
 
+	if dfgOut != nil {
+		if err := dfgOut.write(dfgOut.path, recorder); err != nil {
+			return err
+		}
+	}
+
 	if ctx.Bool(DumpFlag.Name) {
 		statedb.Commit(genesisConfig.Number, true)
 		fmt.Println(string(statedb.Dump(nil)))
@@ -291,6 +307,22 @@ allocations:     %d
 allocated bytes: %d
 `, initialGas-leftOverGas, stats.time, stats.allocs, stats.bytesAllocated)
 	}
+	if dfgOut != nil {
+		if report := dfgOut.analyze(recorder); report != nil {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "#### DEPENDENCY GRAPH ####\n%s\n", out)
+		}
+		if report := dfgOut.findings(recorder); report != nil {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "#### TAINT ####\n%s\n", out)
+		}
+	}
 	if tracer == nil {
 		fmt.Printf("%#x\n", output)
 		if err != nil {
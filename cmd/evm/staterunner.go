@@ -21,12 +21,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
 	"github.com/ethereum/go-ethereum/eth/tracers/logger"
 	"github.com/ethereum/go-ethereum/tests"
 	"github.com/urfave/cli/v2"
@@ -42,12 +46,14 @@ var stateTestCommand = &cli.Command{
 // StatetestResult contains the execution status after running a state test, any
 // error that might have occurred and a dump of the final state if requested.
 type StatetestResult struct {
-	Name  string       `json:"name"`
-	Pass  bool         `json:"pass"`
-	Root  *common.Hash `json:"stateRoot,omitempty"`
-	Fork  string       `json:"fork"`
-	Error string       `json:"error,omitempty"`
-	State *state.Dump  `json:"state,omitempty"`
+	Name  string            `json:"name"`
+	Pass  bool              `json:"pass"`
+	Root  *common.Hash      `json:"stateRoot,omitempty"`
+	Fork  string            `json:"fork"`
+	Error string            `json:"error,omitempty"`
+	State *state.Dump       `json:"state,omitempty"`
+	Stats *analytics.Report `json:"dfgStats,omitempty"`
+	Taint *taint.Report     `json:"dfgTaint,omitempty"`
 }
 
 func stateTestCmd(ctx *cli.Context) error {
@@ -58,7 +64,7 @@ func stateTestCmd(ctx *cli.Context) error {
 		DisableStorage:   ctx.Bool(DisableStorageFlag.Name),
 		EnableReturnData: !ctx.Bool(DisableReturnDataFlag.Name),
 	}
-	var cfg vm.Config
+	cfg := vm.Config{EnableDFG: ctx.Bool(DFGFlag.Name)}
 	switch {
 	case ctx.Bool(MachineFlag.Name):
 		cfg.Tracer = logger.NewJSONLogger(config, os.Stderr)
@@ -66,9 +72,13 @@ func stateTestCmd(ctx *cli.Context) error {
 	case ctx.Bool(DebugFlag.Name):
 		cfg.Tracer = logger.NewStructLogger(config)
 	}
+	dfgOut, err := newGraphOutput(ctx)
+	if err != nil {
+		return err
+	}
 	// Load the test content from the input file
 	if len(ctx.Args().First()) != 0 {
-		return runStateTest(ctx.Args().First(), cfg, ctx.Bool(MachineFlag.Name), ctx.Bool(DumpFlag.Name))
+		return runStateTest(ctx.Args().First(), cfg, ctx.Bool(MachineFlag.Name), ctx.Bool(DumpFlag.Name), dfgOut)
 	}
 	// Read filenames from stdin and execute back-to-back
 	scanner := bufio.NewScanner(os.Stdin)
@@ -77,15 +87,17 @@ func stateTestCmd(ctx *cli.Context) error {
 		if len(fname) == 0 {
 			return nil
 		}
-		if err := runStateTest(fname, cfg, ctx.Bool(MachineFlag.Name), ctx.Bool(DumpFlag.Name)); err != nil {
+		if err := runStateTest(fname, cfg, ctx.Bool(MachineFlag.Name), ctx.Bool(DumpFlag.Name), dfgOut); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-// runStateTest loads the state-test given by fname, and executes the test.
-func runStateTest(fname string, cfg vm.Config, jsonOut, dump bool) error {
+// runStateTest loads the state-test given by fname, and executes the test. The
+// dependency graph of every subtest is written to the directory of dfgOut, if
+// set, and its parallelism reported along with the result if asked for.
+func runStateTest(fname string, cfg vm.Config, jsonOut, dump bool, dfgOut *graphOutput) error {
 	src, err := os.ReadFile(fname)
 	if err != nil {
 		return err
@@ -94,13 +106,23 @@ func runStateTest(fname string, cfg vm.Config, jsonOut, dump bool) error {
 	if err := json.Unmarshal(src, &tests); err != nil {
 		return err
 	}
+	if dfgOut != nil && dfgOut.path != "" {
+		if err := os.MkdirAll(dfgOut.path, 0755); err != nil {
+			return err
+		}
+	}
 	// Iterate over all the tests, run them and aggregate the results
 	results := make([]StatetestResult, 0, len(tests))
 	for key, test := range tests {
 		for _, st := range test.Subtests() {
 			// Run the test and aggregate the result
 			result := &StatetestResult{Name: key, Fork: st.Fork, Pass: true}
-			test.Run(st, cfg, false, rawdb.HashScheme, func(err error, snaps *snapshot.Tree, state *state.StateDB) {
+			subCfg := cfg
+			var recorder *graphRecorder
+			if dfgOut != nil {
+				recorder = dfgOut.configure(&subCfg)
+			}
+			test.Run(st, subCfg, false, rawdb.HashScheme, func(err error, snaps *snapshot.Tree, state *state.StateDB) {
 				if state != nil {
 					root := state.IntermediateRoot(false)
 					result.Root = &root
@@ -118,6 +140,15 @@ func runStateTest(fname string, cfg vm.Config, jsonOut, dump bool) error {
 					result.Pass, result.Error = false, err.Error()
 				}
 			})
+			if dfgOut != nil {
+				// The name of the test may hold path separators
+				name := fmt.Sprintf("%s-%s-%d.%s", strings.NewReplacer("/", "_", `\`, "_").Replace(key), st.Fork, st.Index, dfgOut.format)
+				if err := dfgOut.write(filepath.Join(dfgOut.path, name), recorder); err != nil {
+					return err
+				}
+				result.Stats = dfgOut.analyze(recorder)
+				result.Taint = dfgOut.findings(recorder)
+			}
 			results = append(results, *result)
 		}
 	}
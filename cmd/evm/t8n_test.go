@@ -25,6 +25,8 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
 	"github.com/ethereum/go-ethereum/internal/cmdtest"
 	"github.com/ethereum/go-ethereum/internal/reexec"
 )
@@ -320,6 +322,134 @@ func TestT8n(t *testing.T) {
 	}
 }
 
+func TestT8nDFG(t *testing.T) {
+	var (
+		base   = "./testdata/9" // two transactions calling a contract
+		outDir = t.TempDir()
+		input  = t8nInput{"alloc.json", "txs.json", "env.json", "London", ""}
+		output = t8nOutput{result: true}
+	)
+	run := func(extra ...string) []byte {
+		tt := new(testT8n)
+		tt.TestCmd = cmdtest.NewTestCmd(t, tt)
+		args := append([]string{"t8n", "--output.basedir", outDir}, extra...)
+		args = append(args, output.get()...)
+		tt.Run("evm-test", append(args, input.get(base)...)...)
+		have := tt.Output()
+		tt.WaitExit()
+		if status := tt.ExitStatus(); status != 0 {
+			t.Fatalf("wrong exit code %d", status)
+		}
+		return have
+	}
+	// The graph does not change the result
+	want := run()
+	if have := run("--dfg.out", "graphs", "--dfg.level", "call"); string(have) != string(want) {
+		t.Fatalf("output wrong, have \n%s\nwant\n%s", have, want)
+	}
+	files, _ := os.ReadDir(outDir + "/graphs")
+	if len(files) != 3 || files[0].Name() != "block.json" || !strings.HasPrefix(files[1].Name(), "tx-0-0x") || !strings.HasPrefix(files[2].Name(), "tx-1-0x") {
+		t.Fatalf("have graph files %v", files)
+	}
+	// The block graph has a vertex per transaction, the tx ones a vertex per call
+	for i, file := range files {
+		var graph struct {
+			Vertices []struct {
+				OpCode string `json:"opcode"`
+			} `json:"vertices"`
+		}
+		blob, _ := os.ReadFile(outDir + "/graphs/" + file.Name())
+		if err := json.Unmarshal(blob, &graph); err != nil {
+			t.Fatalf("invalid graph %s: %v", file.Name(), err)
+		}
+		want, vertices := "call", 1
+		if i == 0 {
+			want, vertices = "tx", 2
+		}
+		if sources := len(graph.Vertices) - vertices; sources != 5 || graph.Vertices[sources].OpCode != want {
+			t.Errorf("graph %s: have vertices %+v", file.Name(), graph.Vertices)
+		}
+	}
+	// At the instruction level, the tx graphs hold the instructions of the
+	// contract. Both transactions only read its storage, so the block graph
+	// leaves them independent
+	run("--dfg.out", "instructions")
+	type graphFile struct {
+		Vertices []struct {
+			Tx     int    `json:"tx"`
+			Index  int    `json:"index"`
+			OpCode string `json:"opcode"`
+		} `json:"vertices"`
+		Edges []struct {
+			Source int    `json:"source"`
+			Target int    `json:"target"`
+			Kind   string `json:"kind"`
+		} `json:"edges"`
+	}
+	read := func(name string) graphFile {
+		var graph graphFile
+		blob, _ := os.ReadFile(outDir + "/instructions/" + name)
+		if err := json.Unmarshal(blob, &graph); err != nil {
+			t.Fatalf("invalid graph %s: %v", name, err)
+		}
+		return graph
+	}
+	files, _ = os.ReadDir(outDir + "/instructions")
+	for i, file := range files[1:] {
+		var ops []string
+		for _, v := range read(file.Name()).Vertices {
+			if v.Index < 0 {
+				continue // Sources
+			}
+			if v.Tx != i {
+				t.Errorf("graph %s: vertex %d of tx %d", file.Name(), v.Index, v.Tx)
+			}
+			ops = append(ops, v.OpCode)
+		}
+		if want := []string{"PC", "PC", "SLOAD", "SLOAD", "STOP"}; !reflect.DeepEqual(ops, want) {
+			t.Errorf("graph %s: have instructions %v, want %v", file.Name(), ops, want)
+		}
+	}
+	for _, edge := range read("block.json").Edges {
+		if edge.Source >= 0 {
+			t.Errorf("block graph: dependency of tx %d on tx %d", edge.Target, edge.Source)
+		}
+	}
+	// The parallelism is reported next to the graphs
+	run("--dfg.out", "stats", "--dfg.stats", "--dfg.weight", "gas")
+	var stats struct {
+		Block analytics.Report   `json:"block"`
+		Txs   []analytics.Report `json:"txs"`
+	}
+	blob, err := os.ReadFile(outDir + "/stats/stats.json")
+	if err != nil {
+		t.Fatalf("failed reading stats: %v", err)
+	}
+	if err := json.Unmarshal(blob, &stats); err != nil {
+		t.Fatalf("invalid stats: %v", err)
+	}
+	if stats.Block.Vertices != 2 || stats.Block.Weight != "gas" || len(stats.Txs) != 2 {
+		t.Fatalf("have stats %+v", stats)
+	}
+	for i, tx := range stats.Txs {
+		if tx.Work == 0 || tx.CriticalPath == 0 || tx.CriticalPath > tx.Work {
+			t.Errorf("tx %d: have stats %+v", i, tx)
+		}
+	}
+	// So are the taint findings of every transaction
+	run("--dfg.out", "taint", "--dfg.taint", "--dfg.taint.sinks", "sstore,call")
+	var findings []taint.Report
+	if blob, err = os.ReadFile(outDir + "/taint/taint.json"); err != nil {
+		t.Fatalf("failed reading taint findings: %v", err)
+	}
+	if err := json.Unmarshal(blob, &findings); err != nil {
+		t.Fatalf("invalid taint findings: %v", err)
+	}
+	if len(findings) != 2 || !reflect.DeepEqual(findings[0].Sources, taint.DefaultSources) || !reflect.DeepEqual(findings[1].Sinks, []string{"sstore", "call"}) {
+		t.Fatalf("have taint findings %+v", findings)
+	}
+}
+
 type t9nInput struct {
 	inTxs  string
 	stFork string
@@ -0,0 +1,43 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import "github.com/ethereum/go-ethereum/core/vm"
+
+// BlockGraph is the dependency graph of all the transactions of a block, as
+// built by StateProcessor.ProcessGraph.
+type BlockGraph struct {
+	// Graph is the instruction level graph of the block. Its vertices are
+	// attributed to the transactions by their TxId.
+	Graph *vm.DependencyGraph
+
+	// TxDeps is the transaction level DAG condensed from Graph: tx i maps to
+	// the later transactions reading storage, balances or code that i was
+	// the last to write.
+	TxDeps map[int][]int
+}
+
+// DependsOn reports whether transaction j reads state last written by
+// transaction i.
+func (g *BlockGraph) DependsOn(j, i int) bool {
+	for _, tx := range g.TxDeps[i] {
+		if tx == j {
+			return true
+		}
+	}
+	return false
+}
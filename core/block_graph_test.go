@@ -0,0 +1,113 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that the graph of a block links the transactions reading a slot to
+// the last one writing it, and nothing else.
+func TestProcessGraph(t *testing.T) {
+	var (
+		key1, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _  = crypto.HexToECDSA("8a1f9a8f95be41cd7ccb6168179afb4504aefe388d1e14474d32c45c72ce7b7a")
+		addr1    = crypto.PubkeyToAddress(key1.PublicKey)
+		addr2    = crypto.PubkeyToAddress(key2.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		engine   = ethash.NewFaker()
+		funds    = big.NewInt(params.Ether)
+	)
+	// With calldata the contract stores it in slot 0, otherwise it reads slot 0
+	code := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0x09, byte(vm.JUMPI),
+		byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP),
+		byte(vm.JUMPDEST),
+		byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0x00, byte(vm.SSTORE), byte(vm.STOP),
+	}
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			addr1:    {Balance: funds},
+			addr2:    {Balance: funds},
+			contract: {Balance: common.Big0, Code: code},
+		},
+	}
+	_, blocks, _ := GenerateChainWithGenesis(gspec, engine, 1, func(i int, b *BlockGen) {
+		signer := types.LatestSigner(gspec.Config)
+		for _, tx := range []struct {
+			key  *ecdsa.PrivateKey
+			data []byte
+		}{
+			{key1, []byte{0x01}}, // tx 0 writes the slot
+			{key2, nil},          // tx 1 reads what tx 0 wrote
+			{key1, []byte{0x02}}, // tx 2 overwrites it
+			{key2, nil},          // tx 3 reads what tx 2 wrote
+		} {
+			nonce := b.TxNonce(crypto.PubkeyToAddress(tx.key.PublicKey))
+			signed, _ := types.SignTx(types.NewTransaction(nonce, contract, common.Big0, 100000, b.BaseFee(), tx.data), signer, tx.key)
+			b.AddTx(signed)
+		}
+	})
+	chain, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	statedb, _ := state.New(chain.Genesis().Root(), chain.StateCache(), nil)
+	graph, _, _, usedGas, err := NewStateProcessor(gspec.Config, chain, engine).ProcessGraph(blocks[0], statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to process block: %v", err)
+	}
+	if usedGas != blocks[0].GasUsed() {
+		t.Errorf("gas used mismatch: have %d, want %d", usedGas, blocks[0].GasUsed())
+	}
+	if want := map[int][]int{0: {1}, 2: {3}}; !reflect.DeepEqual(graph.TxDeps, want) {
+		t.Errorf("tx dependencies mismatch: have %v, want %v", graph.TxDeps, want)
+	}
+	if !graph.DependsOn(3, 2) || graph.DependsOn(3, 0) {
+		t.Errorf("tx 3 should only depend on tx 2")
+	}
+	// Every vertex is attributed to the transaction that executed it
+	txs := make(map[int]bool)
+	graph.Graph.ForEachVertex(func(v vm.Metadata) error {
+		if v.IsSource() {
+			return nil
+		}
+		txs[v.TxId] = true
+		if v.ID.Block != 1 || v.ID.Tx != v.TxId {
+			t.Errorf("vertex %d of tx %d has id %v", v.Index, v.TxId, v.ID)
+		}
+		return nil
+	})
+	if len(txs) != 4 {
+		t.Errorf("vertices span %d transactions, want 4", len(txs))
+	}
+}
@@ -179,6 +179,21 @@ func (ga *GenesisAlloc) flush(db ethdb.Database, triedb *trie.Database, blockhas
 	return nil
 }
 
+// FakeState returns a FakeState holding the accounts of the allocation, to
+// analyse transactions against a genesis or a t8n pre-state offline.
+func (ga *GenesisAlloc) FakeState() *state.FakeState {
+	alloc := make(state.FakeAlloc, len(*ga))
+	for addr, account := range *ga {
+		alloc[addr] = state.FakeAccount{
+			Balance: account.Balance,
+			Nonce:   account.Nonce,
+			Code:    account.Code,
+			Storage: account.Storage,
+		}
+	}
+	return state.NewFakeStateFromAlloc(alloc)
+}
+
 // GenesisAccount is an account in the state of the genesis block.
 type GenesisAccount struct {
 	Code       []byte                      `json:"code,omitempty"`
@@ -26,6 +26,8 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/params"
@@ -255,6 +257,27 @@ func TestReadWriteGenesisAlloc(t *testing.T) {
 	}
 }
 
+func TestGenesisAllocFakeState(t *testing.T) {
+	alloc := &GenesisAlloc{
+		{1}: {Balance: big.NewInt(1), Nonce: 2, Code: []byte{0x60, 0x00}, Storage: map[common.Hash]common.Hash{{1}: {1}}},
+		{2}: {Balance: big.NewInt(2)},
+	}
+	fake := alloc.FakeState()
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	for addr, account := range *alloc {
+		statedb.SetBalance(addr, account.Balance)
+		statedb.SetNonce(addr, account.Nonce)
+		statedb.SetCode(addr, account.Code)
+		for key, value := range account.Storage {
+			statedb.SetState(addr, key, value)
+		}
+		if fake.GetBalance(addr).Cmp(statedb.GetBalance(addr)) != 0 || fake.GetNonce(addr) != statedb.GetNonce(addr) ||
+			fake.GetCodeHash(addr) != statedb.GetCodeHash(addr) || fake.GetState(addr, common.Hash{1}) != statedb.GetState(addr, common.Hash{1}) {
+			t.Errorf("account %x mismatch", addr)
+		}
+	}
+}
+
 func newDbConfig(scheme string) *trie.Config {
 	if scheme == rawdb.HashScheme {
 		return trie.HashDefaults
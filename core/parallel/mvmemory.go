@@ -0,0 +1,234 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package parallel
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// account is the state of an account as seen by a transaction.
+type account struct {
+	exist    bool
+	balance  *big.Int
+	nonce    uint64
+	codeHash common.Hash
+	code     []byte
+
+	// gen is bumped whenever the storage of the account is wiped, which is
+	// when it is deleted or created anew. Storage written under an older gen
+	// is not visible anymore.
+	gen uint64
+}
+
+// empty reports whether the account is empty as defined by EIP-161.
+func (a *account) empty() bool {
+	return a.nonce == 0 && a.balance.Sign() == 0 && a.codeHash == types.EmptyCodeHash
+}
+
+// equal reports whether two reads of an account saw the same state.
+func (a *account) equal(b *account) bool {
+	if a.exist != b.exist || a.gen != b.gen {
+		return false
+	}
+	return !a.exist || (a.balance.Cmp(b.balance) == 0 && a.nonce == b.nonce && a.codeHash == b.codeHash)
+}
+
+func (a *account) copy() *account {
+	cpy := *a
+	cpy.balance = new(big.Int).Set(a.balance)
+	return &cpy
+}
+
+// deleted returns the account left once a is deleted.
+func (a *account) deleted() *account {
+	return &account{balance: new(big.Int), gen: a.gen + 1}
+}
+
+type storageKey struct {
+	addr common.Address
+	slot common.Hash
+}
+
+// accountVersion is an account as written by a transaction. Transactions that
+// only credited or debited an account without reading it write the change of
+// balance instead, so that all the transactions paying the coinbase don't
+// depend on each other.
+type accountVersion struct {
+	tx      int
+	account *account // nil for balance changes
+	delta   *big.Int
+}
+
+// storageVersion is a storage slot as written by a transaction.
+type storageVersion struct {
+	tx    int
+	gen   uint64
+	value common.Hash
+}
+
+// writeSet is everything a transaction wrote to the state.
+type writeSet struct {
+	accounts map[common.Address]accountVersion
+	storage  map[storageKey]storageVersion
+}
+
+// mvMemory is the multi-version state of a block: for every account and slot
+// it holds the values written by each transaction. A transaction reads the
+// value written by the closest transaction before it, or the state of the
+// block if there is none.
+type mvMemory struct {
+	mu       sync.RWMutex
+	accounts map[common.Address][]accountVersion // sorted by transaction
+	storage  map[storageKey][]storageVersion     // sorted by transaction
+	writes   []*writeSet                         // last write set of each transaction
+
+	base        *state.StateDB
+	baseMu      sync.Mutex // the state of the block is not safe for concurrent use
+	deleteEmpty bool       // whether empty accounts are deleted when touched
+}
+
+func newMVMemory(base *state.StateDB, txs int, deleteEmpty bool) *mvMemory {
+	return &mvMemory{
+		accounts:    make(map[common.Address][]accountVersion),
+		storage:     make(map[storageKey][]storageVersion),
+		writes:      make([]*writeSet, txs),
+		base:        base,
+		deleteEmpty: deleteEmpty,
+	}
+}
+
+// publish replaces the values written by a transaction with a new write set.
+func (mv *mvMemory) publish(tx int, ws *writeSet) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+
+	if prev := mv.writes[tx]; prev != nil {
+		for addr := range prev.accounts {
+			mv.accounts[addr] = removeVersion(mv.accounts[addr], tx, func(v accountVersion) int { return v.tx })
+		}
+		for key := range prev.storage {
+			mv.storage[key] = removeVersion(mv.storage[key], tx, func(v storageVersion) int { return v.tx })
+		}
+	}
+	mv.writes[tx] = ws
+	for addr, v := range ws.accounts {
+		mv.accounts[addr] = insertVersion(mv.accounts[addr], v, func(v accountVersion) int { return v.tx })
+	}
+	for key, v := range ws.storage {
+		mv.storage[key] = insertVersion(mv.storage[key], v, func(v storageVersion) int { return v.tx })
+	}
+}
+
+func removeVersion[T any](versions []T, tx int, txOf func(T) int) []T {
+	i := sort.Search(len(versions), func(i int) bool { return txOf(versions[i]) >= tx })
+	if i < len(versions) && txOf(versions[i]) == tx {
+		versions = append(versions[:i], versions[i+1:]...)
+	}
+	return versions
+}
+
+func insertVersion[T any](versions []T, v T, txOf func(T) int) []T {
+	i := sort.Search(len(versions), func(i int) bool { return txOf(versions[i]) >= txOf(v) })
+	versions = append(versions, v)
+	copy(versions[i+1:], versions[i:])
+	versions[i] = v
+	return versions
+}
+
+// account returns the account as seen by the given transaction.
+func (mv *mvMemory) account(addr common.Address, tx int) *account {
+	mv.mu.RLock()
+	defer mv.mu.RUnlock()
+
+	var (
+		versions = mv.accounts[addr]
+		end      = sort.Search(len(versions), func(i int) bool { return versions[i].tx >= tx })
+		start    = end
+	)
+	for start > 0 && versions[start-1].account == nil {
+		start--
+	}
+	var acc *account
+	if start > 0 {
+		acc = versions[start-1].account.copy()
+	} else {
+		acc = mv.baseAccount(addr)
+	}
+	for _, v := range versions[start:end] {
+		acc = mv.applyDelta(acc, v.delta)
+	}
+	return acc
+}
+
+// applyDelta credits an account the way StateDB does, creating it if needed,
+// and deletes it at the end of the transaction if it is left empty.
+func (mv *mvMemory) applyDelta(acc *account, delta *big.Int) *account {
+	if !acc.exist {
+		acc = &account{exist: true, balance: new(big.Int), codeHash: types.EmptyCodeHash, gen: acc.gen}
+	}
+	acc.balance.Add(acc.balance, delta)
+	if mv.deleteEmpty && acc.empty() {
+		return acc.deleted()
+	}
+	return acc
+}
+
+func (mv *mvMemory) baseAccount(addr common.Address) *account {
+	mv.baseMu.Lock()
+	defer mv.baseMu.Unlock()
+
+	if !mv.base.Exist(addr) {
+		return &account{balance: new(big.Int)}
+	}
+	return &account{
+		exist:    true,
+		balance:  new(big.Int).Set(mv.base.GetBalance(addr)),
+		nonce:    mv.base.GetNonce(addr),
+		codeHash: mv.base.GetCodeHash(addr),
+		code:     mv.base.GetCode(addr),
+	}
+}
+
+// storageAt returns a slot as seen by the given transaction, for the given gen
+// of the account.
+func (mv *mvMemory) storageAt(addr common.Address, slot common.Hash, gen uint64, tx int) common.Hash {
+	mv.mu.RLock()
+	var (
+		key      = storageKey{addr, slot}
+		versions = mv.storage[key]
+	)
+	for i := sort.Search(len(versions), func(i int) bool { return versions[i].tx >= tx }) - 1; i >= 0; i-- {
+		if versions[i].gen == gen {
+			mv.mu.RUnlock()
+			return versions[i].value
+		}
+	}
+	mv.mu.RUnlock()
+
+	if gen != 0 {
+		return common.Hash{}
+	}
+	mv.baseMu.Lock()
+	defer mv.baseMu.Unlock()
+	return mv.base.GetState(addr, slot)
+}
@@ -0,0 +1,309 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package parallel implements an optimistic executor running the transactions
+// of a block concurrently, in the manner of Block-STM.
+//
+// Every transaction first runs against its own view of a multi-version state,
+// which records what it reads and buffers what it writes. The transactions are
+// then validated in block order. When a transaction's reads don't match what
+// the transactions before it wrote, it is run again, concurrently with every
+// later transaction invalid at that point. Once all of them are valid, their
+// writes are committed to the state of the block, in order.
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Processor is a core.Processor executing the transactions of a block
+// concurrently. It produces the same state, receipts and gas as
+// core.StateProcessor.
+type Processor struct {
+	config  *params.ChainConfig // Chain configuration options
+	bc      *core.BlockChain    // Canonical block chain
+	engine  consensus.Engine    // Consensus engine used for block rewards
+	workers int                 // Number of transactions executed at once
+
+	reverse bool // Dispatches the transactions last to first, for testing
+}
+
+// NewProcessor initialises a new Processor. A non-positive number of workers
+// runs as many as there are CPUs.
+func NewProcessor(config *params.ChainConfig, bc *core.BlockChain, engine consensus.Engine, workers int) *Processor {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return &Processor{
+		config:  config,
+		bc:      bc,
+		engine:  engine,
+		workers: workers,
+	}
+}
+
+// txTask is a transaction of the block along with its last execution.
+type txTask struct {
+	index int
+	tx    *types.Transaction
+	msg   *core.Message
+
+	state  *txState
+	result *core.ExecutionResult
+	err    error
+}
+
+// Process processes the state changes according to the Ethereum rules, like
+// core.StateProcessor does, running the transactions concurrently.
+//
+// Tracers are not safe for concurrent use and are left out of the execution.
+func (p *Processor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	receipts, allLogs, usedGas, _, err := p.process(block, statedb, cfg)
+	return receipts, allLogs, usedGas, err
+}
+
+// process is Process, also returning the number of transactions that had to
+// be executed again.
+func (p *Processor) process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, int, error) {
+	var (
+		header      = block.Header()
+		blockNumber = block.Number()
+		signer      = types.MakeSigner(p.config, header.Number, header.Time)
+	)
+	cfg.Tracer = nil
+
+	// Mutate the block and state according to any hard-fork specs
+	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(blockNumber) == 0 {
+		misc.ApplyDAOHardFork(statedb)
+	}
+	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		vmenv := vm.NewEVM(core.NewEVMBlockContext(header, p.bc, nil), vm.TxContext{}, statedb, p.config, cfg)
+		core.ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
+	}
+	tasks := make([]*txTask, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		msg, err := core.TransactionToMessage(tx, signer, header.BaseFee)
+		if err != nil {
+			return nil, nil, 0, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+		}
+		tasks[i] = &txTask{index: i, tx: tx, msg: msg}
+	}
+	deleteEmpty := p.config.IsByzantium(blockNumber) || p.config.IsEIP158(blockNumber)
+	mv := newMVMemory(statedb, len(tasks), deleteEmpty)
+
+	// Execute all transactions optimistically
+	if p.reverse {
+		reversed := make([]*txTask, len(tasks))
+		for i, task := range tasks {
+			reversed[len(tasks)-1-i] = task
+		}
+		p.executeAll(header, mv, reversed, cfg)
+	} else {
+		p.executeAll(header, mv, tasks, cfg)
+	}
+	// Validate them in order. All the transactions before the one validated
+	// are final, so running it again yields its final result as well. The
+	// later ones invalid by then are run again alongside it, as they would
+	// most likely fail their own validation otherwise.
+	var (
+		gp         = new(core.GasPool).AddGas(block.GasLimit())
+		reexecuted int
+	)
+	for i, task := range tasks {
+		if !task.state.validate() {
+			invalid := []*txTask{task}
+			for _, later := range tasks[i+1:] {
+				if !later.state.validate() {
+					invalid = append(invalid, later)
+				}
+			}
+			p.executeAll(header, mv, invalid, cfg)
+			reexecuted += len(invalid)
+		}
+		if task.err == nil {
+			task.err = gp.SubGas(task.msg.GasLimit)
+		}
+		if task.err != nil {
+			return nil, nil, 0, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, task.tx.Hash().Hex(), task.err)
+		}
+		gp.AddGas(task.msg.GasLimit - task.result.UsedGas)
+	}
+	// Commit the transactions to the state
+	var (
+		receipts types.Receipts
+		allLogs  []*types.Log
+		usedGas  uint64
+	)
+	for _, task := range tasks {
+		receipt := p.commit(block, statedb, mv, task, &usedGas)
+		receipts = append(receipts, receipt)
+		allLogs = append(allLogs, receipt.Logs...)
+	}
+	// Fail if Shanghai not enabled and len(withdrawals) is non-zero.
+	withdrawals := block.Withdrawals()
+	if len(withdrawals) > 0 && !p.config.IsShanghai(blockNumber, block.Time()) {
+		return nil, nil, 0, 0, errors.New("withdrawals before shanghai")
+	}
+	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
+	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
+
+	return receipts, allLogs, usedGas, reexecuted, nil
+}
+
+// executeAll runs the given transactions on the workers, dispatching them in
+// the order given, and returns once all of them have run.
+func (p *Processor) executeAll(header *types.Header, mv *mvMemory, tasks []*txTask, cfg vm.Config) {
+	var (
+		wg    sync.WaitGroup
+		queue = make(chan *txTask)
+	)
+	for i := 0; i < p.workers && i < len(tasks); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				p.execute(header, mv, task, cfg)
+			}
+		}()
+	}
+	for _, task := range tasks {
+		queue <- task
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// execute runs a transaction against the multi-version state and publishes
+// what it wrote.
+func (p *Processor) execute(header *types.Header, mv *mvMemory, task *txTask, cfg vm.Config) {
+	var (
+		st = newTxState(mv, task.index)
+		// The block hash cache of the context is not safe for concurrent use
+		vmenv = vm.NewEVM(core.NewEVMBlockContext(header, p.bc, nil), core.NewEVMTxContext(task.msg), st, p.config, cfg)
+	)
+	task.state = st
+	task.result, task.err = core.ApplyMessage(vmenv, task.msg, new(core.GasPool).AddGas(header.GasLimit))
+	if task.err != nil {
+		mv.publish(task.index, new(writeSet))
+		return
+	}
+	mv.publish(task.index, st.finalise())
+}
+
+// commit applies the writes of a transaction to the state and returns its
+// receipt.
+func (p *Processor) commit(block *types.Block, statedb *state.StateDB, mv *mvMemory, task *txTask, usedGas *uint64) *types.Receipt {
+	var (
+		ws          = mv.writes[task.index]
+		blockNumber = block.Number()
+		addrs       = make([]common.Address, 0, len(ws.accounts))
+		slots       = make(map[common.Address][]common.Hash)
+	)
+	statedb.SetTxContext(task.tx.Hash(), task.index)
+
+	for addr := range ws.accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Cmp(addrs[j]) < 0 })
+	for key := range ws.storage {
+		slots[key.addr] = append(slots[key.addr], key.slot)
+	}
+	for _, addr := range addrs {
+		v := ws.accounts[addr]
+		if v.account == nil {
+			if v.delta.Sign() >= 0 {
+				statedb.AddBalance(addr, v.delta)
+			} else {
+				statedb.SubBalance(addr, new(big.Int).Neg(v.delta))
+			}
+			continue
+		}
+		if !v.account.exist {
+			// Deleted, be it destructed or left empty
+			statedb.SelfDestruct(addr)
+			continue
+		}
+		if v.account.gen != task.state.reads[addr].gen {
+			statedb.CreateAccount(addr)
+		}
+		statedb.SetBalance(addr, v.account.balance)
+		statedb.SetNonce(addr, v.account.nonce)
+		if statedb.GetCodeHash(addr) != v.account.codeHash {
+			statedb.SetCode(addr, v.account.code)
+		}
+		keys := slots[addr]
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Cmp(keys[j]) < 0 })
+		for _, slot := range keys {
+			statedb.SetState(addr, slot, ws.storage[storageKey{addr, slot}].value)
+		}
+	}
+	for _, log := range task.state.logs {
+		statedb.AddLog(log)
+	}
+	for hash, preimage := range task.state.images {
+		statedb.AddPreimage(hash, preimage)
+	}
+	// Update the state with pending changes.
+	var root []byte
+	if p.config.IsByzantium(blockNumber) {
+		statedb.Finalise(true)
+	} else {
+		root = statedb.IntermediateRoot(p.config.IsEIP158(blockNumber)).Bytes()
+	}
+	*usedGas += task.result.UsedGas
+
+	// Create a new receipt for the transaction, storing the intermediate root and gas used
+	// by the tx.
+	receipt := &types.Receipt{Type: task.tx.Type(), PostState: root, CumulativeGasUsed: *usedGas}
+	if task.result.Failed() {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		receipt.Status = types.ReceiptStatusSuccessful
+	}
+	receipt.TxHash = task.tx.Hash()
+	receipt.GasUsed = task.result.UsedGas
+
+	if task.tx.Type() == types.BlobTxType {
+		receipt.BlobGasUsed = uint64(len(task.tx.BlobHashes()) * params.BlobTxBlobGasPerBlob)
+		receipt.BlobGasPrice = core.NewEVMBlockContext(block.Header(), p.bc, nil).BlobBaseFee
+	}
+	// If the transaction created a contract, store the creation address in the receipt.
+	if task.msg.To == nil {
+		receipt.ContractAddress = crypto.CreateAddress(task.msg.From, task.tx.Nonce())
+	}
+	// Set the receipt logs and create the bloom filter.
+	receipt.Logs = statedb.GetLogs(task.tx.Hash(), blockNumber.Uint64(), block.Hash())
+	receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+	receipt.BlockHash = block.Hash()
+	receipt.BlockNumber = blockNumber
+	receipt.TransactionIndex = uint(task.index)
+	return receipt
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package parallel
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/beacon"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/holiman/uint256"
+)
+
+var (
+	// counter increments slot 0 and logs the new value
+	counterAddr = common.HexToAddress("0xc0de")
+	counterCode = []byte{
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.ADD),
+		byte(vm.DUP1), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.LOG0),
+		byte(vm.STOP),
+	}
+	// reverter writes slot 0, then reverts
+	reverterAddr = common.HexToAddress("0x0bad")
+	reverterCode = []byte{
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT),
+	}
+	// caller calls the reverter, stores whether it succeeded, then calls the
+	// counter
+	callerAddr = common.HexToAddress("0xca11")
+	callerCode = []byte{
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH2), 0x0b, 0xad, byte(vm.GAS), byte(vm.CALL),
+		byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0,
+		byte(vm.PUSH2), 0xc0, 0xde, byte(vm.GAS), byte(vm.CALL), byte(vm.POP),
+		byte(vm.STOP),
+	}
+	// destructor sends its balance to the caller and destructs
+	destructorAddr = common.HexToAddress("0xdead")
+	destructorCode = []byte{byte(vm.CALLER), byte(vm.SELFDESTRUCT)}
+
+	// reader stores the balance of the coinbase
+	readerAddr = common.HexToAddress("0xba1")
+	readerCode = []byte{byte(vm.COINBASE), byte(vm.BALANCE), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+	// transient stores what it finds in transient storage before setting it,
+	// then the blob base fee and first blob hash
+	transientAddr = common.HexToAddress("0x7157")
+	transientCode = []byte{
+		byte(vm.PUSH1), 0, byte(vm.TLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 0, byte(vm.TSTORE),
+		byte(vm.PUSH1), 0, byte(vm.TLOAD), byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.BLOBBASEFEE), byte(vm.PUSH1), 2, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.BLOBHASH), byte(vm.PUSH1), 3, byte(vm.SSTORE),
+		byte(vm.STOP),
+	}
+
+	// initCode deploys an account with a slot set and no code
+	initCode = []byte{byte(vm.PUSH1), 7, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)}
+
+	sharedAddr = common.HexToAddress("0x5ea7")
+)
+
+// generateBlocks generates a chain whose transactions conflict in most of
+// the ways they can: through storage, balances, nonces, the coinbase, and
+// accounts created, destructed and left empty. From Cancun on, they also go
+// through transient storage and pay for blobs.
+func generateBlocks(t *testing.T, config *params.ChainConfig, engine consensus.Engine) (*core.Genesis, []*types.Block) {
+	var (
+		keys  []*ecdsa.PrivateKey
+		alloc = core.GenesisAlloc{
+			counterAddr:    {Balance: common.Big0, Code: counterCode},
+			reverterAddr:   {Balance: common.Big0, Code: reverterCode},
+			callerAddr:     {Balance: common.Big0, Code: callerCode},
+			destructorAddr: {Balance: common.Big1, Code: destructorCode},
+			readerAddr:     {Balance: common.Big0, Code: readerCode},
+			transientAddr:  {Balance: common.Big0, Code: transientCode},
+		}
+	)
+	for i := 0; i < 8; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	gspec := &core.Genesis{Config: config, Alloc: alloc, GasLimit: 30_000_000}
+
+	_, blocks, _ := core.GenerateChainWithGenesis(gspec, engine, 4, func(i int, b *core.BlockGen) {
+		signer := types.MakeSigner(config, b.Number(), b.Timestamp())
+		gasPrice := big.NewInt(params.GWei)
+		if config.IsLondon(b.Number()) {
+			gasPrice = new(big.Int).Mul(b.BaseFee(), common.Big2)
+		}
+		if i == 2 {
+			// The coinbase also sends transactions
+			b.SetCoinbase(crypto.PubkeyToAddress(keys[5].PublicKey))
+		}
+		send := func(key *ecdsa.PrivateKey, to *common.Address, value int64, data []byte) {
+			nonce := b.TxNonce(crypto.PubkeyToAddress(key.PublicKey))
+			var tx *types.Transaction
+			if to == nil {
+				tx = types.NewContractCreation(nonce, big.NewInt(value), 200000, gasPrice, data)
+			} else {
+				tx = types.NewTransaction(nonce, *to, big.NewInt(value), 200000, gasPrice, data)
+			}
+			signed, err := types.SignTx(tx, signer, key)
+			if err != nil {
+				t.Fatalf("failed to sign tx: %v", err)
+			}
+			b.AddTx(signed)
+		}
+		for k, key := range keys {
+			fresh := common.BigToAddress(big.NewInt(int64(0x10000 + i*16 + k)))
+			switch (i + k) % 8 {
+			case 0:
+				send(key, &fresh, 1000, nil)
+			case 1:
+				send(key, &counterAddr, 0, nil)
+			case 2:
+				send(key, &callerAddr, 0, nil)
+			case 3:
+				send(key, &destructorAddr, 1, nil)
+			case 4:
+				// Touches an empty account
+				send(key, &fresh, 0, nil)
+			case 5:
+				send(key, &readerAddr, 0, nil)
+			case 6:
+				send(key, nil, 5, initCode)
+			case 7:
+				send(key, &sharedAddr, 1, nil)
+			}
+		}
+		// A second transaction of the first sender depends on its first one
+		send(keys[0], &counterAddr, 0, nil)
+
+		if config.IsCancun(b.Number(), b.Timestamp()) {
+			send(keys[1], &transientAddr, 0, nil)
+			for k, key := range keys[2:4] {
+				tx, err := types.SignTx(types.NewTx(&types.BlobTx{
+					Nonce:      b.TxNonce(crypto.PubkeyToAddress(key.PublicKey)),
+					GasTipCap:  uint256.NewInt(params.GWei),
+					GasFeeCap:  uint256.MustFromBig(gasPrice),
+					Gas:        200000,
+					To:         transientAddr,
+					BlobFeeCap: uint256.NewInt(params.GWei),
+					BlobHashes: []common.Hash{{params.BlobTxHashVersion, byte(i), byte(k)}},
+				}), signer, key)
+				if err != nil {
+					t.Fatalf("failed to sign blob tx: %v", err)
+				}
+				b.AddTx(tx)
+			}
+		}
+	})
+	return gspec, blocks
+}
+
+// Tests that the parallel processor produces the same state, receipts and gas
+// as the sequential one.
+func TestProcessorMatchesStateProcessor(t *testing.T) {
+	preByzantium := *params.TestChainConfig
+	preByzantium.ByzantiumBlock = nil
+	preByzantium.ConstantinopleBlock = nil
+	preByzantium.PetersburgBlock = nil
+	preByzantium.IstanbulBlock = nil
+	preByzantium.MuirGlacierBlock = nil
+	preByzantium.BerlinBlock = nil
+	preByzantium.LondonBlock = nil
+	preByzantium.ArrowGlacierBlock = nil
+	preByzantium.GrayGlacierBlock = nil
+
+	cancun := *params.TestChainConfig
+	cancun.TerminalTotalDifficulty = common.Big0
+	cancun.TerminalTotalDifficultyPassed = true
+	cancun.ShanghaiTime = new(uint64)
+	cancun.CancunTime = new(uint64)
+
+	for name, config := range map[string]*params.ChainConfig{
+		"london":        params.TestChainConfig,
+		"pre-byzantium": &preByzantium,
+		"cancun":        &cancun,
+	} {
+		var engine consensus.Engine = ethash.NewFaker()
+		if config.TerminalTotalDifficultyPassed {
+			engine = beacon.NewFaker()
+		}
+		gspec, blocks := generateBlocks(t, config, engine)
+		chain, err := core.NewBlockChain(rawdb.NewMemoryDatabase(), nil, gspec, nil, engine, vm.Config{}, nil, nil)
+		if err != nil {
+			t.Fatalf("%s: failed to create chain: %v", name, err)
+		}
+		if _, err := chain.InsertChain(blocks); err != nil {
+			t.Fatalf("%s: failed to insert chain: %v", name, err)
+		}
+		for _, workers := range []int{1, 4, 16} {
+			processor := NewProcessor(config, chain, engine, workers)
+			// Running the transactions last to first makes every conflict
+			// surface, instead of depending on the scheduling
+			processor.reverse = workers == 1
+			for _, block := range blocks {
+				parent := chain.GetBlockByHash(block.ParentHash())
+				statedb, _ := chain.StateAt(parent.Root())
+				receipts, logs, usedGas, reexecuted, err := processor.process(block, statedb, vm.Config{})
+				if err != nil {
+					t.Fatalf("%s: block %d, %d workers: failed to process: %v", name, block.NumberU64(), workers, err)
+				}
+				if processor.reverse && reexecuted == 0 {
+					t.Errorf("%s: block %d: no conflicts caught running the transactions in reverse", name, block.NumberU64())
+				}
+
+				if usedGas != block.GasUsed() {
+					t.Errorf("%s: block %d, %d workers: gas used %d, want %d", name, block.NumberU64(), workers, usedGas, block.GasUsed())
+				}
+				if root := statedb.IntermediateRoot(config.IsEIP158(block.Number())); root != block.Root() {
+					t.Errorf("%s: block %d, %d workers: root %x, want %x", name, block.NumberU64(), workers, root, block.Root())
+				}
+				if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != block.ReceiptHash() {
+					t.Errorf("%s: block %d, %d workers: receipt hash %x, want %x", name, block.NumberU64(), workers, hash, block.ReceiptHash())
+				}
+				// The receipts must match beyond their consensus fields
+				statedb, _ = chain.StateAt(parent.Root())
+				want, wantLogs, _, err := core.NewStateProcessor(config, chain, engine).Process(block, statedb, vm.Config{})
+				if err != nil {
+					t.Fatalf("%s: block %d: failed to process sequentially: %v", name, block.NumberU64(), err)
+				}
+				have, _ := json.Marshal(receipts)
+				exp, _ := json.Marshal(want)
+				if !bytes.Equal(have, exp) {
+					t.Errorf("%s: block %d, %d workers: receipts mismatch\nhave %s\nwant %s", name, block.NumberU64(), workers, have, exp)
+				}
+				if len(logs) != len(wantLogs) {
+					t.Errorf("%s: block %d, %d workers: %d logs, want %d", name, block.NumberU64(), workers, len(logs), len(wantLogs))
+				}
+			}
+		}
+		chain.Stop()
+	}
+}
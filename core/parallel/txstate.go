@@ -0,0 +1,472 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package parallel
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// txAccount is an account touched by a transaction.
+//
+// Accounts are only read from the multi-version state when the transaction
+// looks at them. Until then, balance changes are recorded as a delta, and an
+// account created by such a change is only marked touched.
+type txAccount struct {
+	loaded bool
+	pre    *account // the account before the transaction, once loaded
+
+	exist      bool
+	nonce      uint64
+	codeHash   common.Hash
+	code       []byte
+	gen        uint64
+	storage    map[common.Hash]common.Hash // slots written under the current gen
+	created    bool
+	destructed bool
+
+	touched bool     // created by a balance change before being loaded
+	delta   *big.Int // balance change of the transaction
+	dirty   bool
+}
+
+func (a *txAccount) exists() bool {
+	return a.exist || a.touched
+}
+
+func (a *txAccount) balance() *big.Int {
+	if !a.pre.exist {
+		return new(big.Int).Set(a.delta)
+	}
+	return new(big.Int).Add(a.pre.balance, a.delta)
+}
+
+func (a *txAccount) hash() common.Hash {
+	if a.exist {
+		return a.codeHash
+	}
+	if a.touched {
+		return types.EmptyCodeHash
+	}
+	return common.Hash{}
+}
+
+func (a *txAccount) empty() bool {
+	return a.nonce == 0 && a.balance().Sign() == 0 && a.hash() == types.EmptyCodeHash
+}
+
+// txState is the state a transaction runs against. It reads from the
+// multi-version state of the block, records what it read and buffers what it
+// wrote, so the transaction can be validated and its writes published.
+//
+// txState implements vm.StateDB.
+type txState struct {
+	mv *mvMemory
+	tx int
+
+	accounts map[common.Address]*txAccount
+	reads    map[common.Address]*account // accounts as first read
+	slots    map[storageKey]common.Hash  // slots as first read
+	addrs    map[common.Address]bool     // access list addresses
+	access   map[storageKey]bool         // access list slots
+	tstore   map[storageKey]common.Hash
+	refund   uint64
+	logs     []*types.Log
+	images   map[common.Hash][]byte
+	journal  []func()
+}
+
+func newTxState(mv *mvMemory, tx int) *txState {
+	return &txState{
+		mv:       mv,
+		tx:       tx,
+		accounts: make(map[common.Address]*txAccount),
+		reads:    make(map[common.Address]*account),
+		slots:    make(map[storageKey]common.Hash),
+		addrs:    make(map[common.Address]bool),
+		access:   make(map[storageKey]bool),
+		tstore:   make(map[storageKey]common.Hash),
+		images:   make(map[common.Hash][]byte),
+	}
+}
+
+// get returns the account, without loading it.
+func (s *txState) get(addr common.Address) *txAccount {
+	obj := s.accounts[addr]
+	if obj == nil {
+		obj = &txAccount{delta: new(big.Int)}
+		s.accounts[addr] = obj
+	}
+	return obj
+}
+
+// load returns the account, reading it from the multi-version state if it
+// was not yet.
+func (s *txState) load(addr common.Address) *txAccount {
+	obj := s.get(addr)
+	if !obj.loaded {
+		pre := s.mv.account(addr, s.tx)
+		s.reads[addr] = pre
+
+		obj.loaded, obj.pre = true, pre
+		obj.exist, obj.nonce, obj.codeHash, obj.code, obj.gen = pre.exist, pre.nonce, pre.codeHash, pre.code, pre.gen
+		obj.storage = make(map[common.Hash]common.Hash)
+	}
+	return obj
+}
+
+// loadOrNew loads the account, creating it if it does not exist.
+func (s *txState) loadOrNew(addr common.Address) *txAccount {
+	obj := s.load(addr)
+	if !obj.exist {
+		s.modify(obj)
+		obj.exist, obj.codeHash, obj.created = true, types.EmptyCodeHash, true
+	}
+	return obj
+}
+
+// modify journals the account fields and marks the account dirty.
+func (s *txState) modify(obj *txAccount) {
+	prev := *obj
+	prev.delta = new(big.Int).Set(obj.delta)
+	s.journal = append(s.journal, func() {
+		if obj.loaded && !prev.loaded {
+			// Loading is not undone, as the account could be read differently
+			// the next time. Only the changes made before it are.
+			obj.touched, obj.delta, obj.dirty = prev.touched, prev.delta, prev.dirty
+			return
+		}
+		storage := obj.storage
+		*obj = prev
+		obj.storage = storage
+	})
+	obj.dirty = true
+}
+
+// committed returns a slot as of the start of the transaction.
+func (s *txState) committed(addr common.Address, obj *txAccount, slot common.Hash) common.Hash {
+	if obj.gen != obj.pre.gen {
+		return common.Hash{}
+	}
+	key := storageKey{addr, slot}
+	if value, ok := s.slots[key]; ok {
+		return value
+	}
+	value := s.mv.storageAt(addr, slot, obj.pre.gen, s.tx)
+	s.slots[key] = value
+	return value
+}
+
+func (s *txState) CreateAccount(addr common.Address) {
+	obj := s.load(addr)
+	s.modify(obj)
+
+	storage := obj.storage
+	s.journal = append(s.journal, func() { obj.storage = storage })
+	obj.storage = make(map[common.Hash]common.Hash)
+
+	// The balance carries over, everything else starts afresh
+	obj.exist, obj.nonce, obj.codeHash, obj.code, obj.gen = true, 0, types.EmptyCodeHash, nil, obj.gen+1
+	obj.created, obj.destructed = true, false
+}
+
+func (s *txState) SubBalance(addr common.Address, amount *big.Int) {
+	s.AddBalance(addr, new(big.Int).Neg(amount))
+}
+
+func (s *txState) AddBalance(addr common.Address, amount *big.Int) {
+	obj := s.get(addr)
+	if obj.loaded {
+		obj = s.loadOrNew(addr)
+		s.modify(obj)
+	} else {
+		s.modify(obj)
+		obj.touched = true
+	}
+	obj.delta.Add(obj.delta, amount)
+}
+
+func (s *txState) GetBalance(addr common.Address) *big.Int {
+	if obj := s.load(addr); obj.exists() {
+		return obj.balance()
+	}
+	return new(big.Int)
+}
+
+func (s *txState) GetNonce(addr common.Address) uint64 {
+	return s.load(addr).nonce
+}
+
+func (s *txState) SetNonce(addr common.Address, nonce uint64) {
+	obj := s.loadOrNew(addr)
+	s.modify(obj)
+	obj.nonce = nonce
+}
+
+func (s *txState) GetCodeHash(addr common.Address) common.Hash {
+	return s.load(addr).hash()
+}
+
+func (s *txState) GetCode(addr common.Address) []byte {
+	return s.load(addr).code
+}
+
+func (s *txState) SetCode(addr common.Address, code []byte) {
+	obj := s.loadOrNew(addr)
+	s.modify(obj)
+	obj.code, obj.codeHash = code, crypto.Keccak256Hash(code)
+}
+
+func (s *txState) GetCodeSize(addr common.Address) int {
+	return len(s.load(addr).code)
+}
+
+func (s *txState) AddRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	s.refund += gas
+}
+
+func (s *txState) SubRefund(gas uint64) {
+	prev := s.refund
+	s.journal = append(s.journal, func() { s.refund = prev })
+	if gas > s.refund {
+		panic("refund counter below zero")
+	}
+	s.refund -= gas
+}
+
+func (s *txState) GetRefund() uint64 {
+	return s.refund
+}
+
+func (s *txState) GetCommittedState(addr common.Address, slot common.Hash) common.Hash {
+	obj := s.load(addr)
+	if !obj.exists() {
+		return common.Hash{}
+	}
+	return s.committed(addr, obj, slot)
+}
+
+func (s *txState) GetState(addr common.Address, slot common.Hash) common.Hash {
+	obj := s.load(addr)
+	if !obj.exists() {
+		return common.Hash{}
+	}
+	if value, ok := obj.storage[slot]; ok {
+		return value
+	}
+	return s.committed(addr, obj, slot)
+}
+
+func (s *txState) SetState(addr common.Address, slot, value common.Hash) {
+	obj := s.loadOrNew(addr)
+	if s.GetState(addr, slot) == value {
+		return
+	}
+	s.modify(obj)
+	prev, ok := obj.storage[slot]
+	s.journal = append(s.journal, func() {
+		if ok {
+			obj.storage[slot] = prev
+		} else {
+			delete(obj.storage, slot)
+		}
+	})
+	obj.storage[slot] = value
+}
+
+func (s *txState) GetTransientState(addr common.Address, key common.Hash) common.Hash {
+	return s.tstore[storageKey{addr, key}]
+}
+
+func (s *txState) SetTransientState(addr common.Address, key, value common.Hash) {
+	k := storageKey{addr, key}
+	prev := s.tstore[k]
+	if prev == value {
+		return
+	}
+	s.journal = append(s.journal, func() { s.tstore[k] = prev })
+	s.tstore[k] = value
+}
+
+func (s *txState) SelfDestruct(addr common.Address) {
+	obj := s.load(addr)
+	if !obj.exists() {
+		return
+	}
+	s.modify(obj)
+	obj.destructed = true
+	obj.delta.Sub(obj.delta, obj.balance())
+}
+
+func (s *txState) HasSelfDestructed(addr common.Address) bool {
+	return s.load(addr).destructed
+}
+
+func (s *txState) Selfdestruct6780(addr common.Address) {
+	obj := s.load(addr)
+	if obj.created || (obj.touched && !obj.pre.exist) {
+		s.SelfDestruct(addr)
+	}
+}
+
+func (s *txState) Exist(addr common.Address) bool {
+	return s.load(addr).exists()
+}
+
+func (s *txState) Empty(addr common.Address) bool {
+	obj := s.load(addr)
+	return !obj.exists() || obj.empty()
+}
+
+func (s *txState) AddressInAccessList(addr common.Address) bool {
+	return s.addrs[addr]
+}
+
+func (s *txState) SlotInAccessList(addr common.Address, slot common.Hash) (addressOk bool, slotOk bool) {
+	return s.addrs[addr], s.access[storageKey{addr, slot}]
+}
+
+func (s *txState) AddAddressToAccessList(addr common.Address) {
+	if s.addrs[addr] {
+		return
+	}
+	s.journal = append(s.journal, func() { delete(s.addrs, addr) })
+	s.addrs[addr] = true
+}
+
+func (s *txState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	s.AddAddressToAccessList(addr)
+	key := storageKey{addr, slot}
+	if s.access[key] {
+		return
+	}
+	s.journal = append(s.journal, func() { delete(s.access, key) })
+	s.access[key] = true
+}
+
+// Prepare sets up the access list and clears the transient storage, as
+// StateDB does at the start of a transaction.
+func (s *txState) Prepare(rules params.Rules, sender, coinbase common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	if rules.IsBerlin {
+		s.addrs = map[common.Address]bool{sender: true}
+		s.access = make(map[storageKey]bool)
+		if dst != nil {
+			s.addrs[*dst] = true
+		}
+		for _, addr := range precompiles {
+			s.addrs[addr] = true
+		}
+		for _, el := range list {
+			s.addrs[el.Address] = true
+			for _, key := range el.StorageKeys {
+				s.access[storageKey{el.Address, key}] = true
+			}
+		}
+		if rules.IsShanghai {
+			s.addrs[coinbase] = true
+		}
+	}
+	s.tstore = make(map[storageKey]common.Hash)
+}
+
+func (s *txState) RevertToSnapshot(revid int) {
+	for i := len(s.journal) - 1; i >= revid; i-- {
+		s.journal[i]()
+	}
+	s.journal = s.journal[:revid]
+}
+
+func (s *txState) Snapshot() int {
+	return len(s.journal)
+}
+
+func (s *txState) AddLog(log *types.Log) {
+	n := len(s.logs)
+	s.journal = append(s.journal, func() { s.logs = s.logs[:n] })
+	s.logs = append(s.logs, log)
+}
+
+func (s *txState) AddPreimage(hash common.Hash, preimage []byte) {
+	if _, ok := s.images[hash]; ok {
+		return
+	}
+	s.journal = append(s.journal, func() { delete(s.images, hash) })
+	s.images[hash] = common.CopyBytes(preimage)
+}
+
+func (s *txState) GetTxId() int {
+	return s.tx
+}
+
+// finalise ends the transaction the way StateDB.Finalise does, and returns
+// what the transaction wrote.
+func (s *txState) finalise() *writeSet {
+	ws := &writeSet{
+		accounts: make(map[common.Address]accountVersion),
+		storage:  make(map[storageKey]storageVersion),
+	}
+	for addr, obj := range s.accounts {
+		if !obj.dirty {
+			continue
+		}
+		if !obj.loaded {
+			ws.accounts[addr] = accountVersion{tx: s.tx, delta: obj.delta}
+			continue
+		}
+		acc := &account{
+			exist:    obj.exists(),
+			balance:  obj.balance(),
+			nonce:    obj.nonce,
+			codeHash: obj.hash(),
+			code:     obj.code,
+			gen:      obj.gen,
+		}
+		if acc.exist && (obj.destructed || (s.mv.deleteEmpty && acc.empty())) {
+			acc = acc.deleted()
+		}
+		ws.accounts[addr] = accountVersion{tx: s.tx, account: acc}
+		if !acc.exist {
+			continue
+		}
+		for slot, value := range obj.storage {
+			ws.storage[storageKey{addr, slot}] = storageVersion{tx: s.tx, gen: acc.gen, value: value}
+		}
+	}
+	return ws
+}
+
+// validate reports whether everything the transaction read is still what
+// the multi-version state holds.
+func (s *txState) validate() bool {
+	for addr, read := range s.reads {
+		if !s.mv.account(addr, s.tx).equal(read) {
+			return false
+		}
+	}
+	for key, read := range s.slots {
+		if s.mv.storageAt(key.addr, key.slot, s.reads[key.addr].gen, s.tx) != read {
+			return false
+		}
+	}
+	return true
+}
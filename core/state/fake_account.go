@@ -20,6 +20,10 @@ type fakeAccountObject struct {
 	Data         fakeAccountData             `json:"data,omitempty"`
 	CacheStorage map[common.Hash]common.Hash `json:"cache_storage,omitempty"` // 用于缓存存储的变量
 	IsAlive      bool                        `json:"is_alive,omitempty"`
+
+	created       bool                        // created in the current transaction
+	newStorage    bool                        // created in the state, so not to be fetched
+	originStorage map[common.Hash]common.Hash // slot values at the start of the transaction, for the slots written since
 }
 
 func newFakeAccountObject(address common.Address, data fakeAccountData) *fakeAccountObject {
@@ -30,10 +34,11 @@ func newFakeAccountObject(address common.Address, data fakeAccountData) *fakeAcc
 		data.CodeHash = types.EmptyCodeHash
 	}
 	return &fakeAccountObject{
-		Address:      address,
-		Data:         data,
-		CacheStorage: make(map[common.Hash]common.Hash),
-		IsAlive:      true,
+		Address:       address,
+		Data:          data,
+		CacheStorage:  make(map[common.Hash]common.Hash),
+		IsAlive:       true,
+		originStorage: make(map[common.Hash]common.Hash),
 	}
 }
 
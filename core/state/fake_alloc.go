@@ -0,0 +1,197 @@
+package state
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// FakeAccount is an account of a FakeState in the JSON format shared by the
+// prestateTracer output, the genesis alloc and the t8n alloc.json. Balances and
+// nonces are read as hex or decimal, addresses with or without 0x prefix.
+type FakeAccount struct {
+	Balance *big.Int
+	Nonce   uint64
+	Code    []byte
+	Storage map[common.Hash]common.Hash
+}
+
+type fakeAccountJSON struct {
+	Balance *math.HexOrDecimal256               `json:"balance,omitempty"`
+	Nonce   math.HexOrDecimal64                 `json:"nonce,omitempty"`
+	Code    hexutil.Bytes                       `json:"code,omitempty"`
+	Storage map[fakeStorageJSON]fakeStorageJSON `json:"storage,omitempty"`
+}
+
+// fakeStorageJSON is a storage key or value, which the t8n tool allows to be
+// shorter than 32 bytes.
+type fakeStorageJSON common.Hash
+
+func (h *fakeStorageJSON) UnmarshalText(text []byte) error {
+	text = bytes.TrimPrefix(text, []byte("0x"))
+	if len(text) > 64 {
+		return fmt.Errorf("too many hex characters in storage key/value %q", text)
+	}
+	offset := len(h) - len(text)/2 // pad on the left
+	if _, err := hex.Decode(h[offset:], text); err != nil {
+		return fmt.Errorf("invalid hex storage key/value %q", text)
+	}
+	return nil
+}
+
+func (h fakeStorageJSON) MarshalText() ([]byte, error) {
+	return hexutil.Bytes(h[:]).MarshalText()
+}
+
+// MarshalJSON marshals as JSON.
+func (a FakeAccount) MarshalJSON() ([]byte, error) {
+	enc := fakeAccountJSON{
+		Balance: (*math.HexOrDecimal256)(a.Balance),
+		Nonce:   math.HexOrDecimal64(a.Nonce),
+		Code:    a.Code,
+	}
+	if a.Storage != nil {
+		enc.Storage = make(map[fakeStorageJSON]fakeStorageJSON, len(a.Storage))
+		for key, value := range a.Storage {
+			enc.Storage[fakeStorageJSON(key)] = fakeStorageJSON(value)
+		}
+	}
+	return json.Marshal(enc)
+}
+
+// UnmarshalJSON unmarshals from JSON.
+func (a *FakeAccount) UnmarshalJSON(input []byte) error {
+	var dec fakeAccountJSON
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*a = FakeAccount{
+		Balance: (*big.Int)(dec.Balance),
+		Nonce:   uint64(dec.Nonce),
+		Code:    dec.Code,
+	}
+	if dec.Storage != nil {
+		a.Storage = make(map[common.Hash]common.Hash, len(dec.Storage))
+		for key, value := range dec.Storage {
+			a.Storage[common.Hash(key)] = common.Hash(value)
+		}
+	}
+	return nil
+}
+
+// FakeAlloc is a set of accounts a FakeState is loaded from or saved to.
+type FakeAlloc map[common.Address]FakeAccount
+
+func (fa *FakeAlloc) UnmarshalJSON(data []byte) error {
+	m := make(map[common.UnprefixedAddress]FakeAccount)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*fa = make(FakeAlloc)
+	for addr, a := range m {
+		(*fa)[common.Address(addr)] = a
+	}
+	return nil
+}
+
+// NewFakeStateFromAlloc 从账户集合创建FakeState
+//
+// The accounts and slots of the alloc are all the state known: reading others
+// clears StateJudge.
+func NewFakeStateFromAlloc(alloc FakeAlloc) *FakeState {
+	s := NewFakeState()
+	for addr, account := range alloc {
+		obj := newFakeAccountObject(addr, fakeAccountData{Nonce: account.Nonce})
+		if account.Balance != nil {
+			obj.Data.Balance = new(big.Int).Set(account.Balance)
+		}
+		if len(account.Code) > 0 {
+			obj.SetCode(crypto.Keccak256Hash(account.Code), common.CopyBytes(account.Code))
+		}
+		for key, value := range account.Storage {
+			obj.SetStorageState(key, value)
+		}
+		s.setAccountObject(obj)
+	}
+	return s
+}
+
+// NewFakeStateFromPrestate 从prestateTracer的输出创建FakeState
+//
+// Both modes of the tracer are accepted. In diff mode, the state before the
+// transaction is loaded.
+func NewFakeStateFromPrestate(data []byte) (*FakeState, error) {
+	var diff struct {
+		Pre  *FakeAlloc `json:"pre"`
+		Post *FakeAlloc `json:"post"`
+	}
+	if err := json.Unmarshal(data, &diff); err == nil && diff.Pre != nil && diff.Post != nil {
+		return NewFakeStateFromAlloc(*diff.Pre), nil
+	}
+	var alloc FakeAlloc
+	if err := json.Unmarshal(data, &alloc); err != nil {
+		return nil, err
+	}
+	return NewFakeStateFromAlloc(alloc), nil
+}
+
+// NewFakeStateFromStateDB 从StateDB中复制给定的账户和变量
+//
+// Only the accounts of the list which exist are copied, along with the listed
+// slots.
+func NewFakeStateFromStateDB(db *StateDB, list types.AccessList) *FakeState {
+	alloc := make(FakeAlloc)
+	for _, el := range list {
+		if !db.Exist(el.Address) {
+			continue
+		}
+		account, ok := alloc[el.Address]
+		if !ok {
+			account = FakeAccount{
+				Balance: db.GetBalance(el.Address),
+				Nonce:   db.GetNonce(el.Address),
+				Code:    db.GetCode(el.Address),
+				Storage: make(map[common.Hash]common.Hash),
+			}
+			alloc[el.Address] = account
+		}
+		for _, key := range el.StorageKeys {
+			account.Storage[key] = db.GetState(el.Address, key)
+		}
+	}
+	return NewFakeStateFromAlloc(alloc)
+}
+
+// Alloc 导出FakeState中的账户
+//
+// Self-destructed accounts are left out. Slots known to be empty are kept, so
+// that loading the alloc back knows them as well.
+func (s *FakeState) Alloc() FakeAlloc {
+	alloc := make(FakeAlloc, len(s.Accounts))
+	for addr, obj := range s.Accounts {
+		if !obj.IsAlive {
+			continue
+		}
+		account := FakeAccount{
+			Balance: new(big.Int).Set(obj.Data.Balance),
+			Nonce:   obj.Data.Nonce,
+			Code:    common.CopyBytes(obj.ByteCode),
+		}
+		if len(obj.CacheStorage) > 0 {
+			account.Storage = make(map[common.Hash]common.Hash, len(obj.CacheStorage))
+			for key, value := range obj.CacheStorage {
+				account.Storage[key] = value
+			}
+		}
+		alloc[addr] = account
+	}
+	return alloc
+}
@@ -0,0 +1,147 @@
+package state
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	fakeAllocAddr  = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	fakeAllocOther = common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	fakeAllocSlot  = common.HexToHash("0x01")
+	fakeAllocValue = common.HexToHash("0x2a")
+)
+
+// checkFakeAlloc checks that the state holds the accounts of the test inputs,
+// and nothing else.
+func checkFakeAlloc(t *testing.T, s *FakeState) {
+	t.Helper()
+	if have := s.GetBalance(fakeAllocAddr); have.Cmp(big.NewInt(0x100)) != 0 {
+		t.Errorf("balance: have %v, want 256", have)
+	}
+	if have := s.GetNonce(fakeAllocAddr); have != 3 {
+		t.Errorf("nonce: have %d, want 3", have)
+	}
+	if have := s.GetCode(fakeAllocAddr); !reflect.DeepEqual(have, []byte{0x60, 0x00}) {
+		t.Errorf("code: have %x, want 6000", have)
+	}
+	if have := s.GetState(fakeAllocAddr, fakeAllocSlot); have != fakeAllocValue {
+		t.Errorf("slot: have %x, want %x", have, fakeAllocValue)
+	}
+	if !s.Exist(fakeAllocOther) || s.GetCodeSize(fakeAllocOther) != 0 {
+		t.Errorf("account without code missing")
+	}
+	if !s.StateJudge {
+		t.Errorf("known state reported as missing")
+	}
+	s.GetState(fakeAllocAddr, common.HexToHash("0x02"))
+	if s.StateJudge {
+		t.Errorf("unknown slot not reported as missing")
+	}
+}
+
+func TestFakeStateFromPrestate(t *testing.T) {
+	prestate := `{
+		"0x00000000000000000000000000000000000000aa": {
+			"balance": "0x100",
+			"nonce": 3,
+			"code": "0x6000",
+			"storage": {"0x0000000000000000000000000000000000000000000000000000000000000001": "0x000000000000000000000000000000000000000000000000000000000000002a"}
+		},
+		"0x00000000000000000000000000000000000000bb": {"balance": "0x0"}
+	}`
+	s, err := NewFakeStateFromPrestate([]byte(prestate))
+	if err != nil {
+		t.Fatalf("failed to load prestate: %v", err)
+	}
+	checkFakeAlloc(t, s)
+
+	diff := `{"pre": ` + prestate + `, "post": {"0x00000000000000000000000000000000000000aa": {"nonce": 4}}}`
+	if s, err = NewFakeStateFromPrestate([]byte(diff)); err != nil {
+		t.Fatalf("failed to load diff mode prestate: %v", err)
+	}
+	checkFakeAlloc(t, s)
+
+	if _, err := NewFakeStateFromPrestate([]byte(`{"0xaa": {}}`)); err == nil {
+		t.Errorf("invalid address accepted")
+	}
+}
+
+func TestFakeStateFromT8nAlloc(t *testing.T) {
+	alloc := `{
+		"00000000000000000000000000000000000000aa": {
+			"balance": "256",
+			"nonce": "0x3",
+			"code": "0x6000",
+			"storage": {"0x01": "0x2a"}
+		},
+		"0x00000000000000000000000000000000000000bb": {"balance": "0"}
+	}`
+	var fa FakeAlloc
+	if err := json.Unmarshal([]byte(alloc), &fa); err != nil {
+		t.Fatalf("failed to decode alloc: %v", err)
+	}
+	checkFakeAlloc(t, NewFakeStateFromAlloc(fa))
+}
+
+func TestFakeStateFromStateDB(t *testing.T) {
+	sdb := newStateDB()
+	sdb.SetBalance(fakeAllocAddr, big.NewInt(0x100))
+	sdb.SetNonce(fakeAllocAddr, 3)
+	sdb.SetCode(fakeAllocAddr, []byte{0x60, 0x00})
+	sdb.SetState(fakeAllocAddr, fakeAllocSlot, fakeAllocValue)
+	sdb.SetState(fakeAllocAddr, common.HexToHash("0x02"), fakeAllocValue)
+	sdb.CreateAccount(fakeAllocOther)
+	sdb.SetBalance(common.HexToAddress("0xcc"), big.NewInt(1))
+
+	s := NewFakeStateFromStateDB(sdb, types.AccessList{
+		{Address: fakeAllocAddr, StorageKeys: []common.Hash{fakeAllocSlot}},
+		{Address: fakeAllocOther},
+		{Address: common.HexToAddress("0xdd")},
+	})
+	checkFakeAlloc(t, s)
+	if len(s.Accounts) != 2 {
+		t.Errorf("have %d accounts, want 2", len(s.Accounts))
+	}
+}
+
+func TestFakeStateAllocRoundTrip(t *testing.T) {
+	s := NewFakeStateFromAlloc(FakeAlloc{
+		fakeAllocAddr: {
+			Balance: big.NewInt(0x100),
+			Nonce:   3,
+			Code:    []byte{0x60, 0x00},
+			Storage: map[common.Hash]common.Hash{fakeAllocSlot: fakeAllocValue},
+		},
+		fakeAllocOther: {},
+	})
+	// Execution changes are part of the saved state
+	s.SetState(fakeAllocAddr, common.HexToHash("0x03"), fakeAllocValue)
+	s.CreateAccount(common.HexToAddress("0xcc"))
+	s.SelfDestruct(common.HexToAddress("0xcc"))
+	s.Finalise(true)
+
+	blob, err := json.Marshal(s.Alloc())
+	if err != nil {
+		t.Fatalf("failed to encode alloc: %v", err)
+	}
+	loaded, err := NewFakeStateFromPrestate(blob)
+	if err != nil {
+		t.Fatalf("failed to load saved alloc: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Alloc(), s.Alloc()) {
+		t.Errorf("alloc mismatch after round trip\nhave %v\nwant %v", loaded.Alloc(), s.Alloc())
+	}
+	if have := loaded.GetState(fakeAllocAddr, common.HexToHash("0x03")); have != fakeAllocValue {
+		t.Errorf("slot written by execution: have %x, want %x", have, fakeAllocValue)
+	}
+	checkFakeAlloc(t, loaded)
+	if _, ok := loaded.Accounts[common.HexToAddress("0xcc")]; ok {
+		t.Errorf("destructed account saved")
+	}
+}
@@ -0,0 +1,91 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// FakeStateBackend is where a FakeState fetches the state it misses from. It
+// is satisfied by ethclient.Client, so that a historic transaction can be
+// executed against a remote node.
+type FakeStateBackend interface {
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// NewFakeStateWithBackend 创建从backend按需获取状态的FakeState
+//
+// The state is the one at the given block, nil being the latest. Everything
+// fetched is cached, so the backend is queried once per account and slot.
+func NewFakeStateWithBackend(backend FakeStateBackend, blockNumber *big.Int) *FakeState {
+	s := NewFakeState()
+	s.backend = backend
+	if blockNumber != nil {
+		s.blockNumber = new(big.Int).Set(blockNumber)
+	}
+	return s
+}
+
+// fetchAccount 从backend获取账户
+//
+// Empty accounts are deemed not to exist, as the backend can't tell them apart.
+func (s *FakeState) fetchAccount(addr common.Address) *fakeAccountObject {
+	var (
+		ctx   = context.Background()
+		nonce uint64
+		code  []byte
+	)
+	balance, err := s.backend.BalanceAt(ctx, addr, s.blockNumber)
+	if err == nil {
+		nonce, err = s.backend.NonceAt(ctx, addr, s.blockNumber)
+	}
+	if err == nil {
+		code, err = s.backend.CodeAt(ctx, addr, s.blockNumber)
+	}
+	if err != nil {
+		s.setFetchError(fmt.Errorf("failed to fetch account %x: %w", addr, err))
+		return nil
+	}
+	obj := newFakeAccountObject(addr, fakeAccountData{Nonce: nonce, Balance: balance})
+	if len(code) > 0 {
+		obj.SetCode(crypto.Keccak256Hash(code), code)
+	}
+	if obj.Empty() {
+		s.absent[addr] = struct{}{}
+		return nil
+	}
+	s.setAccountObject(obj)
+	return obj
+}
+
+// fetchStorage 从backend获取变量
+func (s *FakeState) fetchStorage(obj *fakeAccountObject, key common.Hash) common.Hash {
+	enc, err := s.backend.StorageAt(context.Background(), obj.Address, key, s.blockNumber)
+	if err != nil {
+		s.setFetchError(fmt.Errorf("failed to fetch slot %x of %x: %w", key, obj.Address, err))
+		return common.Hash{}
+	}
+	value := common.BytesToHash(enc)
+	obj.SetStorageState(key, value)
+	return value
+}
+
+// setFetchError remembers the first failure to fetch the state. The state read
+// in its stead is empty, so StateJudge is cleared as well.
+func (s *FakeState) setFetchError(err error) {
+	if s.fetchErr == nil {
+		s.fetchErr = err
+	}
+	s.StateJudge = false
+}
+
+// Error returns the first error met fetching the state from the backend.
+func (s *FakeState) Error() error {
+	return s.fetchErr
+}
@@ -0,0 +1,147 @@
+package state_test
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// simulatedAPI serves the state of a simulated backend over the eth namespace,
+// counting the queries.
+type simulatedAPI struct {
+	b       *backends.SimulatedBackend
+	queries int
+}
+
+func (api *simulatedAPI) number(number rpc.BlockNumber) *big.Int {
+	if number < 0 {
+		return nil
+	}
+	return big.NewInt(number.Int64())
+}
+
+func (api *simulatedAPI) GetBalance(ctx context.Context, addr common.Address, number rpc.BlockNumber) (*hexutil.Big, error) {
+	api.queries++
+	balance, err := api.b.BalanceAt(ctx, addr, api.number(number))
+	return (*hexutil.Big)(balance), err
+}
+
+func (api *simulatedAPI) GetTransactionCount(ctx context.Context, addr common.Address, number rpc.BlockNumber) (hexutil.Uint64, error) {
+	api.queries++
+	nonce, err := api.b.NonceAt(ctx, addr, api.number(number))
+	return hexutil.Uint64(nonce), err
+}
+
+func (api *simulatedAPI) GetCode(ctx context.Context, addr common.Address, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	api.queries++
+	return api.b.CodeAt(ctx, addr, api.number(number))
+}
+
+func (api *simulatedAPI) GetStorageAt(ctx context.Context, addr common.Address, key string, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	api.queries++
+	return api.b.StorageAt(ctx, addr, common.HexToHash(key), api.number(number))
+}
+
+func TestFakeStateBackend(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		receiver = common.HexToAddress("0xbb")
+		contract = common.HexToAddress("0xcc")
+		// Returns slot 1
+		code = []byte{
+			byte(vm.PUSH1), 1, byte(vm.SLOAD), byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		}
+		sim = backends.NewSimulatedBackend(core.GenesisAlloc{
+			sender:   {Balance: big.NewInt(params.Ether)},
+			receiver: {Balance: big.NewInt(1)},
+			contract: {Balance: common.Big0, Code: code, Storage: map[common.Hash]common.Hash{{31: 1}: {31: 42}}},
+		}, 10_000_000)
+	)
+	defer sim.Close()
+
+	// Credit the receiver in block 1
+	tx, _ := types.SignTx(types.NewTransaction(0, receiver, big.NewInt(1000), params.TxGas, big.NewInt(params.InitialBaseFee*2), nil), types.LatestSigner(sim.Blockchain().Config()), key)
+	if err := sim.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	sim.Commit()
+
+	api := &simulatedAPI{b: sim}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := ethclient.NewClient(rpc.DialInProc(server))
+	defer client.Close()
+
+	// The state is pinned at the genesis
+	fake := state.NewFakeStateWithBackend(client, common.Big0)
+	if have := fake.GetBalance(receiver); have.Cmp(common.Big1) != 0 {
+		t.Errorf("receiver balance: have %v, want 1", have)
+	}
+	if have := fake.GetNonce(sender); have != 0 {
+		t.Errorf("sender nonce: have %d, want 0", have)
+	}
+	if fake.Exist(common.HexToAddress("0xdd")) {
+		t.Errorf("missing account exists")
+	}
+	// Historic execution reads the contract and its storage through the backend
+	var (
+		blockCtx = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: common.Big0,
+			Difficulty:  common.Big0,
+			BaseFee:     common.Big0,
+			GasLimit:    10_000_000,
+		}
+		evm = vm.NewEVM(blockCtx, vm.TxContext{Origin: sender, GasPrice: common.Big0}, fake, params.AllEthashProtocolChanges, vm.Config{})
+	)
+	ret, _, err := evm.Call(vm.AccountRef(sender), contract, nil, 100000, common.Big0, -1)
+	if err != nil {
+		t.Fatalf("failed to call contract: %v", err)
+	}
+	if have := common.BytesToHash(ret); have != (common.Hash{31: 42}) {
+		t.Errorf("returned slot: have %x, want 42", have)
+	}
+	// Everything fetched is cached
+	queries := api.queries
+	fake.GetBalance(receiver)
+	fake.GetCode(contract)
+	fake.GetState(contract, common.Hash{31: 1})
+	fake.Exist(common.HexToAddress("0xdd"))
+	if api.queries != queries {
+		t.Errorf("%d queries for cached state", api.queries-queries)
+	}
+	if !fake.StateJudge || fake.Error() != nil {
+		t.Errorf("state reported as missing: %v", fake.Error())
+	}
+	// The latest state has the transfer
+	latest := state.NewFakeStateWithBackend(client, nil)
+	if have := latest.GetBalance(receiver); have.Cmp(big.NewInt(1001)) != 0 {
+		t.Errorf("latest receiver balance: have %v, want 1001", have)
+	}
+	if have := latest.GetNonce(sender); have != 1 {
+		t.Errorf("latest sender nonce: have %d, want 1", have)
+	}
+	// Failures to fetch are reported
+	server.Stop()
+	if latest.GetBalance(common.HexToAddress("0xee")); latest.Error() == nil || latest.StateJudge {
+		t.Errorf("fetch failure not reported")
+	}
+}
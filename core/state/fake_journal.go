@@ -0,0 +1,132 @@
+package state
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeJournalEntry is a journal entry that can be reverted on a FakeState.
+// FakeState shares the journal, and its entries, with StateDB.
+type fakeJournalEntry interface {
+	revertFake(*FakeState)
+}
+
+// revertFake undoes a batch of journalled modifications on a FakeState.
+func (j *journal) revertFake(s *FakeState, snapshot int) {
+	for i := len(j.entries) - 1; i >= snapshot; i-- {
+		j.entries[i].(fakeJournalEntry).revertFake(s)
+
+		if addr := j.entries[i].dirtied(); addr != nil {
+			if j.dirties[*addr]--; j.dirties[*addr] == 0 {
+				delete(j.dirties, *addr)
+			}
+		}
+	}
+	j.entries = j.entries[:snapshot]
+}
+
+// fakeResetObjectChange is the resetObjectChange of FakeState, which keeps
+// the replaced account object as is. It is never journalled by StateDB.
+type fakeResetObjectChange struct {
+	account *common.Address
+	prev    *fakeAccountObject
+}
+
+func (ch fakeResetObjectChange) revert(s *StateDB) {
+	panic("fake state journal entry reverted on StateDB")
+}
+
+func (ch fakeResetObjectChange) dirtied() *common.Address {
+	return ch.account
+}
+
+func (ch fakeResetObjectChange) revertFake(s *FakeState) {
+	s.setAccountObject(ch.prev)
+}
+
+// fakeStorageChange is the storageChange of FakeState. It also records
+// whether the slot was cached, so that reverting the write of a slot the state
+// did not know forgets it again instead of caching its previous value.
+type fakeStorageChange struct {
+	account  *common.Address
+	key      common.Hash
+	prevalue common.Hash
+	cached   bool
+}
+
+func (ch fakeStorageChange) revert(s *StateDB) {
+	panic("fake state journal entry reverted on StateDB")
+}
+
+func (ch fakeStorageChange) dirtied() *common.Address {
+	return ch.account
+}
+
+func (ch fakeStorageChange) revertFake(s *FakeState) {
+	obj := s.getAccountObject(*ch.account)
+	if ch.cached {
+		obj.SetStorageState(ch.key, ch.prevalue)
+		return
+	}
+	delete(obj.CacheStorage, ch.key)
+	delete(obj.originStorage, ch.key)
+}
+
+func (ch createObjectChange) revertFake(s *FakeState) {
+	delete(s.Accounts, *ch.account)
+}
+
+func (ch selfDestructChange) revertFake(s *FakeState) {
+	if obj := s.getAccountObject(*ch.account); obj != nil {
+		obj.IsAlive = !ch.prev
+		obj.Data.Balance = ch.prevbalance
+	}
+}
+
+func (ch touchChange) revertFake(s *FakeState) {
+}
+
+func (ch balanceChange) revertFake(s *FakeState) {
+	s.getAccountObject(*ch.account).Data.Balance = ch.prev
+}
+
+func (ch nonceChange) revertFake(s *FakeState) {
+	s.getAccountObject(*ch.account).Data.Nonce = ch.prev
+}
+
+func (ch codeChange) revertFake(s *FakeState) {
+	s.getAccountObject(*ch.account).SetCode(common.BytesToHash(ch.prevhash), ch.prevcode)
+}
+
+func (ch storageChange) revertFake(s *FakeState) {
+	s.getAccountObject(*ch.account).SetStorageState(ch.key, ch.prevalue)
+}
+
+func (ch transientStorageChange) revertFake(s *FakeState) {
+	s.transientStorage.Set(*ch.account, ch.key, ch.prevalue)
+}
+
+func (ch refundChange) revertFake(s *FakeState) {
+	s.refund = ch.prev
+}
+
+func (ch addLogChange) revertFake(s *FakeState) {
+	logs := s.Logs[ch.txhash]
+	if len(logs) == 1 {
+		delete(s.Logs, ch.txhash)
+	} else {
+		s.Logs[ch.txhash] = logs[:len(logs)-1]
+	}
+	s.logSize--
+}
+
+func (ch addPreimageChange) revertFake(s *FakeState) {
+	delete(s.preimages, ch.hash)
+}
+
+func (ch accessListAddAccountChange) revertFake(s *FakeState) {
+	s.accessList.DeleteAddress(*ch.address)
+}
+
+func (ch accessListAddSlotChange) revertFake(s *FakeState) {
+	s.accessList.DeleteSlot(*ch.address, *ch.slot)
+}
@@ -1,7 +1,9 @@
 package state
 
 import (
+	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -25,6 +27,11 @@ import (
 // and under this circumstance, we don't need to force commit phase and execution phase to happen in turn.
 
 // FakeState for APEX & APEX+
+//
+// FakeState is an in-memory vm.StateDB holding the accounts it was given
+// (prefetched). Accounts and slots missing from it are fetched from its
+// backend if it has one. Otherwise they read as empty, like in a StateDB, but
+// clear StateJudge: the state may not have been complete.
 type FakeState struct {
 	Accounts map[common.Address]*fakeAccountObject `json:"accounts,omitempty"`
 
@@ -37,15 +44,31 @@ type FakeState struct {
 	Journal        *journal `json:"journal,omitempty"`
 	ValidRevisions []revision
 	NextRevisionId int
+
+	absent map[common.Address]struct{} // accounts known not to exist
+
+	// Backend the missing state is fetched from, at the given block
+	backend     FakeStateBackend
+	blockNumber *big.Int
+	fetchErr    error
+
+	refund           uint64
+	accessList       *accessList
+	transientStorage transientStorage
+	preimages        map[common.Hash][]byte
 }
 
 func NewFakeState() *FakeState {
 	return &FakeState{
-		Accounts:    make(map[common.Address]*fakeAccountObject),
-		Journal:     newJournal(),
-		Logs:        make(map[common.Hash][]*types.Log),
-		StateJudge:  true,
-		prefetching: false,
+		Accounts:         make(map[common.Address]*fakeAccountObject),
+		Journal:          newJournal(),
+		Logs:             make(map[common.Hash][]*types.Log),
+		StateJudge:       true,
+		prefetching:      false,
+		absent:           make(map[common.Address]struct{}),
+		accessList:       newAccessList(),
+		transientStorage: newTransientStorage(),
+		preimages:        make(map[common.Hash][]byte),
 	}
 }
 
@@ -58,55 +81,97 @@ func (s *FakeState) getAccountObject(addr common.Address) *fakeAccountObject {
 	}
 }
 
+// getAccount 获取账户, 缓存中没有时从backend获取
+//
+// Accounts neither cached nor known not to exist clear StateJudge when there
+// is no backend to fetch them from.
+func (s *FakeState) getAccount(addr common.Address) *fakeAccountObject {
+	if obj := s.getAccountObject(addr); obj != nil {
+		return obj
+	}
+	if _, ok := s.absent[addr]; ok {
+		return nil
+	}
+	if s.backend != nil {
+		return s.fetchAccount(addr)
+	}
+	s.StateJudge = false
+	return nil
+}
+
 func (s *FakeState) setAccountObject(obj *fakeAccountObject) {
 	s.Accounts[obj.Address] = obj
 }
 
+// getOrNewAccountObject 获取账户, 不存在时创建
+func (s *FakeState) getOrNewAccountObject(addr common.Address) *fakeAccountObject {
+	stateObject := s.getAccount(addr)
+	if stateObject == nil {
+		stateObject, _ = s.createObject(addr)
+		// An account merely missing from the prefetch may well exist
+		_, stateObject.created = s.absent[addr]
+	}
+	return stateObject
+}
+
+// createObject creates a new account object, replacing the existing one if
+// any, and returns both.
+func (s *FakeState) createObject(addr common.Address) (newobj, prev *fakeAccountObject) {
+	prev = s.getAccount(addr)
+	newobj = newFakeAccountObject(addr, fakeAccountData{})
+	newobj.newStorage = true
+	if !s.prefetching {
+		if prev == nil {
+			s.Journal.append(createObjectChange{account: &addr})
+		} else {
+			s.Journal.append(fakeResetObjectChange{account: &addr, prev: prev})
+		}
+	}
+	s.setAccountObject(newobj)
+	return newobj, prev
+}
+
 // ------------------------------- Getter --------------------------------
 
 // GetBalance 获取某个账户的余额
 func (s *FakeState) GetBalance(addr common.Address) *big.Int {
-	stateObject := s.getAccountObject(addr)
+	stateObject := s.getAccount(addr)
 	if stateObject != nil {
 		return stateObject.GetBalance()
 	}
-	s.StateJudge = false
 	return new(big.Int).SetInt64(0)
 }
 
 // GetNonce 获取nonce
 func (s *FakeState) GetNonce(addr common.Address) uint64 {
-	stateObject := s.getAccountObject(addr)
+	stateObject := s.getAccount(addr)
 	if stateObject != nil {
 		return stateObject.GetNonce()
 	}
-	s.StateJudge = false
 	return 0
 }
 
 // GetCodeHash 获取代码的hash值
 func (s *FakeState) GetCodeHash(addr common.Address) common.Hash {
-	stateObject := s.getAccountObject(addr)
+	stateObject := s.getAccount(addr)
 	if stateObject != nil {
 		return stateObject.CodeHash()
 	}
-	s.StateJudge = false
 	return common.Hash{}
 }
 
 // GetCode 获取智能合约的代码
 func (s *FakeState) GetCode(addr common.Address) []byte {
-	stateObject := s.getAccountObject(addr)
+	stateObject := s.getAccount(addr)
 	if stateObject != nil {
 		return stateObject.Code()
 	}
-	s.StateJudge = false
 	return nil
 }
 
 // GetCodeSize 获取code的大小
 func (s *FakeState) GetCodeSize(addr common.Address) int {
-	stateObject := s.getAccountObject(addr)
+	stateObject := s.getAccount(addr)
 	if stateObject != nil {
 		if stateObject.ByteCode != nil {
 			return len(stateObject.ByteCode)
@@ -114,97 +179,107 @@ func (s *FakeState) GetCodeSize(addr common.Address) int {
 			return 0
 		}
 	}
-	s.StateJudge = false
 	return 0
 }
 
-// GetRefund ...
+// GetRefund returns the current value of the refund counter.
 func (s *FakeState) GetRefund() uint64 {
-	return 0
+	return s.refund
 }
 
+// GetCommittedState 获取交易开始时变量的值
 func (s *FakeState) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	stateObject := s.getAccount(addr)
+	if stateObject != nil {
+		if val, ok := stateObject.originStorage[key]; ok {
+			return val
+		}
+	}
 	return s.GetState(addr, key)
 }
 
 // GetState 和SetState 是用于保存合约执行时 存储的变量是否发生变化 evm对变量存储的改变消耗的gas是有区别的
 func (s *FakeState) GetState(addr common.Address, key common.Hash) common.Hash {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		val, ok := stateObject.GetStorageState(key)
-		if ok {
-			return val
-		}
-		s.StateJudge = false
+	stateObject := s.getAccount(addr)
+	if stateObject == nil {
 		return common.Hash{}
 	}
+	if val, ok := stateObject.GetStorageState(key); ok {
+		return val
+	}
+	// The storage of an account created in this state is known to be empty
+	if stateObject.newStorage {
+		return common.Hash{}
+	}
+	if s.backend != nil {
+		return s.fetchStorage(stateObject, key)
+	}
 	s.StateJudge = false
 	return common.Hash{}
 }
 
 // GetTransientState gets transient storage for a given account.
 func (s *FakeState) GetTransientState(addr common.Address, key common.Hash) common.Hash {
-	return s.GetState(addr, key)
+	return s.transientStorage.Get(addr, key)
 }
 
 // Exist 检查账户是否存在
 func (s *FakeState) Exist(addr common.Address) bool {
-	return s.getAccountObject(addr) != nil
+	if s.getAccount(addr) == nil {
+		return false
+	}
+	return true
 }
 
 // Empty 是否是空账户
 func (s *FakeState) Empty(addr common.Address) bool {
-	so := s.getAccountObject(addr)
+	so := s.getAccount(addr)
 	return so == nil || so.Empty()
 }
 
 // ---------------------------------------- Setter -------------------------------------
 
+// CreateAccount explicitly creates an account. If an account with the address
+// already exists, the balance is carried over to the new account.
 func (s *FakeState) CreateAccount(addr common.Address) {
-	if s.getAccountObject(addr) != nil {
-		return
-	}
-	if !s.prefetching {
-		s.Journal.append(createObjectChange{&addr})
+	newObj, prev := s.createObject(addr)
+	newObj.created = true
+	if prev != nil {
+		newObj.SetBalance(prev.Data.Balance)
 	}
-	obj := newFakeAccountObject(addr, fakeAccountData{})
-	s.setAccountObject(obj)
 }
 
 func (s *FakeState) SubBalance(addr common.Address, amount *big.Int) {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		if !s.prefetching {
-			s.Journal.append(balanceChange{&addr, stateObject.Data.Balance})
-		}
-		stateObject.SubBalance(amount)
+	stateObject := s.getOrNewAccountObject(addr)
+	if amount.Sign() == 0 {
 		return
 	}
-	// fmt.Println("SubBalance:", addr)
-	s.StateJudge = false
+	if !s.prefetching {
+		s.Journal.append(balanceChange{&addr, stateObject.Data.Balance})
+	}
+	stateObject.SubBalance(amount)
 }
 
 // AddBalance 增加某个账户的余额
 func (s *FakeState) AddBalance(addr common.Address, amount *big.Int) {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		if !s.prefetching {
-			s.Journal.append(balanceChange{&addr, stateObject.Data.Balance})
+	stateObject := s.getOrNewAccountObject(addr)
+	if amount.Sign() == 0 {
+		// An empty account is touched all the same, so that it gets deleted
+		if stateObject.Empty() && !s.prefetching {
+			s.Journal.append(touchChange{account: &addr})
 		}
-		stateObject.AddBalance(amount)
 		return
 	}
-	s.StateJudge = false
+	if !s.prefetching {
+		s.Journal.append(balanceChange{&addr, stateObject.Data.Balance})
+	}
+	stateObject.AddBalance(amount)
 }
 
 func (s *FakeState) SetBalance(addr common.Address, amount *big.Int) {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		s.Journal.append(balanceChange{&addr, stateObject.Data.Balance})
-		stateObject.SetBalance(amount)
-		return
-	}
-	s.StateJudge = false
+	stateObject := s.getOrNewAccountObject(addr)
+	s.Journal.append(balanceChange{&addr, stateObject.Data.Balance})
+	stateObject.SetBalance(new(big.Int).Set(amount))
 }
 
 func (s *FakeState) setBalancePrefetch(addr common.Address, amount *big.Int) {
@@ -217,13 +292,9 @@ func (s *FakeState) setBalancePrefetch(addr common.Address, amount *big.Int) {
 
 // SetNonce 设置nonce
 func (s *FakeState) SetNonce(addr common.Address, nonce uint64) {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		s.Journal.append(nonceChange{&addr, stateObject.Data.Nonce})
-		stateObject.SetNonce(nonce)
-		return
-	}
-	s.StateJudge = false
+	stateObject := s.getOrNewAccountObject(addr)
+	s.Journal.append(nonceChange{&addr, stateObject.Data.Nonce})
+	stateObject.SetNonce(nonce)
 }
 
 func (s *FakeState) setNoncePrefetch(addr common.Address, nonce uint64) {
@@ -236,15 +307,11 @@ func (s *FakeState) setNoncePrefetch(addr common.Address, nonce uint64) {
 
 // SetCode 设置智能合约的code
 func (s *FakeState) SetCode(addr common.Address, code []byte) {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		if !s.prefetching {
-			s.Journal.append(codeChange{&addr, stateObject.ByteCode, stateObject.Data.CodeHash.Bytes()})
-		}
-		stateObject.SetCode(crypto.Keccak256Hash(code), code)
-		return
+	stateObject := s.getOrNewAccountObject(addr)
+	if !s.prefetching {
+		s.Journal.append(codeChange{&addr, stateObject.ByteCode, stateObject.Data.CodeHash.Bytes()})
 	}
-	s.StateJudge = false
+	stateObject.SetCode(crypto.Keccak256Hash(code), code)
 }
 
 func (s *FakeState) setCodePrefetch(addr common.Address, code []byte) {
@@ -264,32 +331,36 @@ func (s *FakeState) setCodeHashPrefetch(addr common.Address, codeHash common.Has
 	}
 }
 
-// AddRefund
-func (s *FakeState) AddRefund(amount uint64) {
+// AddRefund adds gas to the refund counter
+func (s *FakeState) AddRefund(gas uint64) {
+	s.Journal.append(refundChange{prev: s.refund})
+	s.refund += gas
 }
 
-// SubRefund
-func (s *FakeState) SubRefund(amount uint64) {
+// SubRefund removes gas from the refund counter.
+// This method will panic if the refund counter goes below zero
+func (s *FakeState) SubRefund(gas uint64) {
+	s.Journal.append(refundChange{prev: s.refund})
+	if gas > s.refund {
+		panic(fmt.Sprintf("Refund counter below zero (gas: %d > refund: %d)", gas, s.refund))
+	}
+	s.refund -= gas
 }
 
 // SetState 设置变量的状态
 func (s *FakeState) SetState(addr common.Address, key common.Hash, value common.Hash) {
-	stateObject := s.getAccountObject(addr)
-	if stateObject != nil {
-		val, ok := stateObject.GetStorageState(key)
-		if ok {
-			// the value is present in the cache, so we need to record the change
-			s.Journal.append(storageChange{&addr, key, val})
-			stateObject.SetStorageState(key, value)
-		} else {
-			// we write something that was not prefectched before, so we need to invalidate the cache
-			// fmt.Println("SetState without slot:", addr, " ", key)
-			s.StateJudge = false
-		}
+	stateObject := s.getOrNewAccountObject(addr)
+	prev := s.GetState(addr, key)
+	if prev == value {
 		return
 	}
-	// fmt.Println("SetState without addr:", addr)
-	s.StateJudge = false
+	// Remember the value the slot had at the start of the transaction
+	if _, ok := stateObject.originStorage[key]; !ok {
+		stateObject.originStorage[key] = prev
+	}
+	_, cached := stateObject.GetStorageState(key)
+	s.Journal.append(fakeStorageChange{&addr, key, prev, cached})
+	stateObject.SetStorageState(key, value)
 }
 
 func (s *FakeState) setStatePrefetch(addr common.Address, key common.Hash, value common.Hash) {
@@ -304,25 +375,37 @@ func (s *FakeState) setStatePrefetch(addr common.Address, key common.Hash, value
 // adds the change to the journal so that it can be rolled back
 // to its previous value if there is a revert.
 func (s *FakeState) SetTransientState(addr common.Address, key, value common.Hash) {
-	s.SetState(addr, key, value)
+	prev := s.GetTransientState(addr, key)
+	if prev == value {
+		return
+	}
+	s.Journal.append(transientStorageChange{
+		account:  &addr,
+		key:      key,
+		prevalue: prev,
+	})
+	s.transientStorage.Set(addr, key, value)
 }
 
-// Suicide
+// SelfDestruct marks the given account as selfdestructed.
+// This clears the account balance.
+//
+// The account is still available until the state is finalised.
 func (s *FakeState) SelfDestruct(addr common.Address) {
-	stateObject := s.getAccountObject(addr)
+	stateObject := s.getAccount(addr)
 	if stateObject == nil {
 		return
 	}
 	s.Journal.append(selfDestructChange{
 		account:     &addr,
-		prev:        stateObject.IsAlive,
+		prev:        !stateObject.IsAlive,
 		prevbalance: stateObject.Data.Balance,
 	})
 	stateObject.IsAlive = false
 	stateObject.Data.Balance = new(big.Int)
 }
 
-// HasSuicided ...
+// HasSelfDestructed ...
 func (s *FakeState) HasSelfDestructed(addr common.Address) bool {
 	stateObject := s.getAccountObject(addr)
 	if stateObject == nil {
@@ -331,8 +414,16 @@ func (s *FakeState) HasSelfDestructed(addr common.Address) bool {
 	return !stateObject.IsAlive
 }
 
+// Selfdestruct6780 only destructs accounts created in the same transaction,
+// as specified by EIP-6780.
 func (s *FakeState) Selfdestruct6780(addr common.Address) {
-	s.SelfDestruct(addr)
+	stateObject := s.getAccount(addr)
+	if stateObject == nil {
+		return
+	}
+	if stateObject.created {
+		s.SelfDestruct(addr)
+	}
 }
 
 func (s *FakeState) setIsAlivePrefetch(addr common.Address, isAlive bool) {
@@ -345,35 +436,51 @@ func (s *FakeState) setIsAlivePrefetch(addr common.Address, isAlive bool) {
 
 // AddAddressToAccessList adds the given address to the access list
 func (s *FakeState) AddAddressToAccessList(addr common.Address) {
+	if s.accessList.AddAddress(addr) {
+		s.Journal.append(accessListAddAccountChange{&addr})
+	}
 }
 
 // AddSlotToAccessList adds the given (address, slot)-tuple to the access list
 func (s *FakeState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	addrMod, slotMod := s.accessList.AddSlot(addr, slot)
+	if addrMod {
+		// In practice, this should not happen, since there is no way to enter the
+		// scope of 'address' without having the 'address' become already added
+		// to the access list (via call-variant, create, etc).
+		// Better safe than sorry, though
+		s.Journal.append(accessListAddAccountChange{&addr})
+	}
+	if slotMod {
+		s.Journal.append(accessListAddSlotChange{
+			address: &addr,
+			slot:    &slot,
+		})
+	}
 }
 
 // SlotInAccessList returns true if the given (address, slot)-tuple is in the access list.
 func (s *FakeState) SlotInAccessList(addr common.Address, slot common.Hash) (addressPresent bool, slotPresent bool) {
-	return true, true
+	return s.accessList.Contains(addr, slot)
 }
 
-// RevertToSnapshot ...
-// 暂且忽略
+// RevertToSnapshot reverts all state changes made since the given revision.
 func (s *FakeState) RevertToSnapshot(revid int) {
-	// // Find the snapshot in the stack of valid snapshots.
-	// idx := sort.Search(len(s.ValidRevisions), func(i int) bool {
-	// 	return s.ValidRevisions[i].id >= revid
-	// })
-	// if idx == len(s.ValidRevisions) || s.ValidRevisions[idx].id != revid {
-	// 	panic(fmt.Errorf("revision id %v cannot be reverted", revid))
-	// }
-	// snapshot := s.ValidRevisions[idx].journalIndex
+	// Find the snapshot in the stack of valid snapshots.
+	idx := sort.Search(len(s.ValidRevisions), func(i int) bool {
+		return s.ValidRevisions[i].id >= revid
+	})
+	if idx == len(s.ValidRevisions) || s.ValidRevisions[idx].id != revid {
+		panic(fmt.Errorf("revision id %v cannot be reverted", revid))
+	}
+	snapshot := s.ValidRevisions[idx].journalIndex
 
-	// // Replay the journal to undo changes and remove invalidated snapshots
-	// s.Journal.revert(s, snapshot)
-	// s.ValidRevisions = s.ValidRevisions[:idx]
+	// Replay the journal to undo changes and remove invalidated snapshots
+	s.Journal.revertFake(s, snapshot)
+	s.ValidRevisions = s.ValidRevisions[:idx]
 }
 
-// Snapshot ...
+// Snapshot returns an identifier for the current revision of the state.
 func (s *FakeState) Snapshot() int {
 	id := s.NextRevisionId
 	s.NextRevisionId++
@@ -383,22 +490,98 @@ func (s *FakeState) Snapshot() int {
 
 // AddLog
 func (s *FakeState) AddLog(log *types.Log) {
+	s.Journal.append(addLogChange{txhash: s.thash})
+
 	log.TxHash = s.thash
 	log.TxIndex = uint(s.txIndex)
 	log.Index = s.logSize
 	s.Logs[s.thash] = append(s.Logs[s.thash], log)
+	s.logSize++
+}
+
+// GetLogs returns the logs matching the specified transaction hash, and annotates
+// them with the given blockNumber and blockHash.
+func (s *FakeState) GetLogs(hash common.Hash, blockNumber uint64, blockHash common.Hash) []*types.Log {
+	logs := s.Logs[hash]
+	for _, l := range logs {
+		l.BlockNumber = blockNumber
+		l.BlockHash = blockHash
+	}
+	return logs
 }
 
-// AddPreimage
+// AddPreimage records a SHA3 preimage seen by the VM.
 func (s *FakeState) AddPreimage(hash common.Hash, preimage []byte) {
+	if _, ok := s.preimages[hash]; !ok {
+		s.Journal.append(addPreimageChange{hash: hash})
+		s.preimages[hash] = common.CopyBytes(preimage)
+	}
+}
+
+// Preimages returns a list of SHA3 preimages that have been submitted.
+func (s *FakeState) Preimages() map[common.Hash][]byte {
+	return s.preimages
 }
 
+// Prepare handles the preparatory steps for executing a state transition with,
+// like StateDB.Prepare does: it sets up the access list of the transaction and
+// clears the transient storage.
 func (s *FakeState) Prepare(rules params.Rules, sender, coinbase common.Address, dst *common.Address, precompiles []common.Address, list types.AccessList) {
+	if rules.IsBerlin {
+		// Clear out any leftover from previous executions
+		al := newAccessList()
+		s.accessList = al
+
+		al.AddAddress(sender)
+		if dst != nil {
+			al.AddAddress(*dst)
+			// If it's a create-tx, the destination will be added inside evm.create
+		}
+		for _, addr := range precompiles {
+			al.AddAddress(addr)
+		}
+		for _, el := range list {
+			al.AddAddress(el.Address)
+			for _, key := range el.StorageKeys {
+				al.AddSlot(el.Address, key)
+			}
+		}
+		if rules.IsShanghai { // EIP-3651: warm coinbase
+			al.AddAddress(coinbase)
+		}
+	}
+	// Reset transient storage at the beginning of transaction execution
+	s.transientStorage = newTransientStorage()
 }
 
 // AddressInAccessList returns true if the given address is in the access list.
 func (s *FakeState) AddressInAccessList(addr common.Address) bool {
-	return true
+	return s.accessList.ContainsAddress(addr)
+}
+
+// Finalise finalises the state at the end of a transaction, like
+// StateDB.Finalise: self-destructed accounts are removed, and so are the
+// touched empty ones if deleteEmptyObjects is set. The journal and the
+// refund counter are cleared, snapshots can't be reverted across it.
+func (s *FakeState) Finalise(deleteEmptyObjects bool) {
+	for addr := range s.Journal.dirties {
+		obj := s.getAccountObject(addr)
+		if obj == nil {
+			continue
+		}
+		if !obj.IsAlive || (deleteEmptyObjects && obj.Empty()) {
+			delete(s.Accounts, addr)
+			s.absent[addr] = struct{}{}
+			continue
+		}
+		obj.created = false
+	}
+	for _, obj := range s.Accounts {
+		obj.originStorage = make(map[common.Hash]common.Hash)
+	}
+	s.Journal = newJournal()
+	s.ValidRevisions = s.ValidRevisions[:0]
+	s.refund = 0
 }
 
 // SetTxContext sets the current transaction hash and index which are
@@ -0,0 +1,475 @@
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// fakeTestedState is what is compared between FakeState and StateDB.
+type fakeTestedState interface {
+	vm.StateDB
+	SetBalance(common.Address, *big.Int)
+	SetTxContext(common.Hash, int)
+	GetLogs(common.Hash, uint64, common.Hash) []*types.Log
+	Finalise(bool)
+}
+
+// A fakeStateTest checks that FakeState behaves as StateDB does, applying the
+// same random actions to both and comparing them after every action.
+type fakeStateTest struct {
+	actions []fakeTestAction
+	err     error // failure details are reported through this field
+}
+
+type fakeTestAction struct {
+	name string
+	fn   func(fakeTestAction, fakeTestedState)
+	args []int64
+}
+
+var (
+	fakeTestAddrs = []common.Address{{1}, {2}, {3}, {4}}
+	fakeTestSlots = []common.Hash{{}, {1}, {2}}
+	fakeTestTx    = common.Hash{0xaa}
+)
+
+// newFakeTestAction creates a random action. Snapshot, RevertToSnapshot and
+// Finalise are handled by the test itself.
+func newFakeTestAction(addr common.Address, r *rand.Rand) fakeTestAction {
+	slot := func(a fakeTestAction) common.Hash { return fakeTestSlots[a.args[0]%int64(len(fakeTestSlots))] }
+	value := func(v int64) common.Hash {
+		var h common.Hash
+		binary.BigEndian.PutUint16(h[30:], uint16(v%3)) // mostly collide with the current value
+		return h
+	}
+	actions := []fakeTestAction{
+		{name: "SetBalance", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.SetBalance(addr, big.NewInt(a.args[0]%3))
+		}},
+		{name: "AddBalance", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.AddBalance(addr, big.NewInt(a.args[0]%3))
+		}},
+		{name: "SubBalance", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.SubBalance(addr, big.NewInt(a.args[0]%3))
+		}},
+		{name: "SetNonce", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.SetNonce(addr, uint64(a.args[0]%2))
+		}},
+		{name: "SetCode", fn: func(a fakeTestAction, s fakeTestedState) {
+			code := make([]byte, a.args[0]%3)
+			for i := range code {
+				code[i] = byte(a.args[1])
+			}
+			s.SetCode(addr, code)
+		}},
+		{name: "SetState", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.SetState(addr, slot(a), value(a.args[1]))
+		}},
+		{name: "SetTransientState", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.SetTransientState(addr, slot(a), value(a.args[1]))
+		}},
+		{name: "CreateAccount", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.CreateAccount(addr)
+		}},
+		{name: "SelfDestruct", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.SelfDestruct(addr)
+		}},
+		{name: "Selfdestruct6780", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.Selfdestruct6780(addr)
+		}},
+		{name: "AddRefund", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.AddRefund(uint64(a.args[0] % 100))
+		}},
+		{name: "SubRefund", fn: func(a fakeTestAction, s fakeTestedState) {
+			if gas := uint64(a.args[0] % 100); gas <= s.GetRefund() {
+				s.SubRefund(gas)
+			}
+		}},
+		{name: "AddLog", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.AddLog(&types.Log{Address: addr, Data: []byte{byte(a.args[0])}})
+		}},
+		{name: "AddPreimage", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.AddPreimage(value(a.args[0]), []byte{byte(a.args[0])})
+		}},
+		{name: "AddAddressToAccessList", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.AddAddressToAccessList(addr)
+		}},
+		{name: "AddSlotToAccessList", fn: func(a fakeTestAction, s fakeTestedState) {
+			s.AddSlotToAccessList(addr, slot(a))
+		}},
+		{name: "Snapshot"},
+		{name: "RevertToSnapshot"},
+		{name: "Finalise"},
+	}
+	action := actions[r.Intn(len(actions))]
+	action.args = []int64{r.Int63n(1 << 16), r.Int63n(1 << 16)}
+	return action
+}
+
+// Generate returns a new fakeStateTest with random actions.
+func (*fakeStateTest) Generate(r *rand.Rand, size int) reflect.Value {
+	actions := make([]fakeTestAction, size)
+	for i := range actions {
+		actions[i] = newFakeTestAction(fakeTestAddrs[r.Intn(len(fakeTestAddrs))], r)
+	}
+	return reflect.ValueOf(&fakeStateTest{actions: actions})
+}
+
+func (test *fakeStateTest) String() string {
+	out := new(strings.Builder)
+	for i, action := range test.actions {
+		fmt.Fprintf(out, "%3d: %s %v\n", i, action.name, action.args)
+	}
+	return out.String()
+}
+
+// newCompleteFakeState returns an empty FakeState which knows that none of the
+// test accounts exist, like the StateDB it is compared with.
+func newCompleteFakeState() *FakeState {
+	fake := NewFakeState()
+	for _, addr := range fakeTestAddrs {
+		fake.absent[addr] = struct{}{}
+	}
+	fake.absent[fakeEVMSender] = struct{}{}
+	fake.absent[common.Address{}] = struct{}{}
+	return fake
+}
+
+func (test *fakeStateTest) run() bool {
+	var (
+		fake  = newCompleteFakeState()
+		sdb   = newStateDB()
+		snaps []int
+	)
+	fake.SetTxContext(fakeTestTx, 0)
+	sdb.SetTxContext(fakeTestTx, 0)
+
+	for i, action := range test.actions {
+		switch action.name {
+		case "Snapshot":
+			id := sdb.Snapshot()
+			if have := fake.Snapshot(); have != id {
+				test.err = fmt.Errorf("action %d: snapshot id %d, want %d", i, have, id)
+				return false
+			}
+			snaps = append(snaps, id)
+		case "RevertToSnapshot":
+			if len(snaps) == 0 {
+				continue
+			}
+			n := int(action.args[0]) % len(snaps)
+			sdb.RevertToSnapshot(snaps[n])
+			fake.RevertToSnapshot(snaps[n])
+			snaps = snaps[:n]
+		case "Finalise":
+			deleteEmpty := action.args[0]%2 == 0
+			sdb.Finalise(deleteEmpty)
+			fake.Finalise(deleteEmpty)
+			snaps = nil
+		default:
+			action.fn(action, sdb)
+			action.fn(action, fake)
+		}
+		if err := compareFakeState(fake, sdb); err != nil {
+			test.err = fmt.Errorf("action %d: %v", i, err)
+			return false
+		}
+	}
+	return true
+}
+
+func newStateDB() *StateDB {
+	sdb, _ := New(types.EmptyRootHash, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	return sdb
+}
+
+// compareFakeState compares everything observable through vm.StateDB.
+func compareFakeState(fake *FakeState, sdb *StateDB) error {
+	check := func(what string, addr common.Address, have, want interface{}) error {
+		if !reflect.DeepEqual(have, want) {
+			return fmt.Errorf("%s(%x): have %v, want %v", what, addr[:1], have, want)
+		}
+		return nil
+	}
+	for _, addr := range fakeTestAddrs {
+		for _, fn := range []func() error{
+			func() error { return check("Exist", addr, fake.Exist(addr), sdb.Exist(addr)) },
+			func() error { return check("Empty", addr, fake.Empty(addr), sdb.Empty(addr)) },
+			func() error {
+				return check("GetBalance", addr, fake.GetBalance(addr).String(), sdb.GetBalance(addr).String())
+			},
+			func() error { return check("GetNonce", addr, fake.GetNonce(addr), sdb.GetNonce(addr)) },
+			func() error { return check("GetCodeHash", addr, fake.GetCodeHash(addr), sdb.GetCodeHash(addr)) },
+			func() error { return check("GetCodeSize", addr, fake.GetCodeSize(addr), sdb.GetCodeSize(addr)) },
+			func() error {
+				return check("GetCode", addr, common.Bytes2Hex(fake.GetCode(addr)), common.Bytes2Hex(sdb.GetCode(addr)))
+			},
+			func() error {
+				return check("HasSelfDestructed", addr, fake.HasSelfDestructed(addr), sdb.HasSelfDestructed(addr))
+			},
+			func() error {
+				return check("AddressInAccessList", addr, fake.AddressInAccessList(addr), sdb.AddressInAccessList(addr))
+			},
+		} {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+		for _, slot := range fakeTestSlots {
+			if err := check("GetState", addr, fake.GetState(addr, slot), sdb.GetState(addr, slot)); err != nil {
+				return err
+			}
+			if err := check("GetCommittedState", addr, fake.GetCommittedState(addr, slot), sdb.GetCommittedState(addr, slot)); err != nil {
+				return err
+			}
+			if err := check("GetTransientState", addr, fake.GetTransientState(addr, slot), sdb.GetTransientState(addr, slot)); err != nil {
+				return err
+			}
+			fa, fs := fake.SlotInAccessList(addr, slot)
+			sa, ss := sdb.SlotInAccessList(addr, slot)
+			if err := check("SlotInAccessList", addr, [2]bool{fa, fs}, [2]bool{sa, ss}); err != nil {
+				return err
+			}
+		}
+	}
+	if have, want := fake.GetRefund(), sdb.GetRefund(); have != want {
+		return fmt.Errorf("GetRefund: have %d, want %d", have, want)
+	}
+	if have, want := len(fake.Preimages()), len(sdb.Preimages()); have != want {
+		return fmt.Errorf("Preimages: have %d, want %d", have, want)
+	}
+	return compareLogs(fake.GetLogs(fakeTestTx, 0, common.Hash{}), sdb.GetLogs(fakeTestTx, 0, common.Hash{}))
+}
+
+func compareLogs(have, want []*types.Log) error {
+	if len(have) != len(want) {
+		return fmt.Errorf("logs: have %d, want %d", len(have), len(want))
+	}
+	for i := range have {
+		if !reflect.DeepEqual(have[i], want[i]) {
+			return fmt.Errorf("log %d: have %+v, want %+v", i, have[i], want[i])
+		}
+	}
+	return nil
+}
+
+func TestFakeStateMatchesStateDB(t *testing.T) {
+	config := &quick.Config{MaxCount: 1000}
+	err := quick.Check((*fakeStateTest).run, config)
+	if cerr, ok := err.(*quick.CheckError); ok {
+		test := cerr.In[0].(*fakeStateTest)
+		t.Errorf("%v:\n%s", test.err, test)
+	} else if err != nil {
+		t.Error(err)
+	}
+}
+
+// A fakeEVMTest checks that the EVM yields the same results on FakeState as
+// on StateDB, calling a contract made of random snippets, which itself calls
+// another one.
+type fakeEVMTest struct {
+	outer, inner []byte
+	value        int64
+	err          error // failure details are reported through this field
+}
+
+var (
+	fakeEVMSender = common.Address{0xff}
+	fakeEVMOuter  = fakeTestAddrs[0]
+	fakeEVMInner  = fakeTestAddrs[1]
+)
+
+// fakeEVMSnippet returns a random stack neutral piece of code.
+func fakeEVMSnippet(r *rand.Rand) []byte {
+	var (
+		key   = byte(r.Intn(len(fakeTestSlots)))
+		dst   = byte(r.Intn(len(fakeTestSlots)))
+		val   = byte(r.Intn(3))
+		other = fakeTestAddrs[r.Intn(len(fakeTestAddrs))]
+	)
+	push20 := append([]byte{byte(vm.PUSH20)}, other[:]...)
+
+	switch r.Intn(8) {
+	case 0:
+		return []byte{byte(vm.PUSH1), val, byte(vm.PUSH1), key, byte(vm.SSTORE)}
+	case 1:
+		return []byte{byte(vm.PUSH1), key, byte(vm.SLOAD), byte(vm.PUSH1), dst, byte(vm.SSTORE)}
+	case 2:
+		return []byte{byte(vm.PUSH1), val, byte(vm.PUSH1), key, byte(vm.TSTORE)}
+	case 3:
+		return []byte{byte(vm.PUSH1), key, byte(vm.TLOAD), byte(vm.PUSH1), dst, byte(vm.SSTORE)}
+	case 4:
+		code := append(push20, byte(vm.BALANCE))
+		return append(code, byte(vm.PUSH1), dst, byte(vm.SSTORE))
+	case 5:
+		return []byte{byte(vm.PUSH1), val, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.LOG1)}
+	case 6:
+		code := append(push20, byte(vm.EXTCODEHASH))
+		return append(code, byte(vm.PUSH1), dst, byte(vm.SSTORE))
+	default:
+		// Calls the inner contract, or another account, with some value
+		target := append([]byte{byte(vm.PUSH20)}, fakeEVMInner[:]...)
+		if r.Intn(3) == 0 {
+			target = push20
+		}
+		code := []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.PUSH1), val}
+		code = append(code, target...)
+		return append(code, byte(vm.GAS), byte(vm.CALL), byte(vm.PUSH1), dst, byte(vm.SSTORE))
+	}
+}
+
+// fakeEVMEnding returns a random way for a contract to terminate.
+func fakeEVMEnding(r *rand.Rand) []byte {
+	switch r.Intn(4) {
+	case 0:
+		return []byte{byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.REVERT)}
+	case 1:
+		return append(append([]byte{byte(vm.PUSH20)}, fakeEVMSender[:]...), byte(vm.SELFDESTRUCT))
+	case 2:
+		return []byte{byte(vm.INVALID)}
+	default:
+		return []byte{byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN)}
+	}
+}
+
+// Generate returns a new fakeEVMTest with random contracts.
+func (*fakeEVMTest) Generate(r *rand.Rand, size int) reflect.Value {
+	contract := func() []byte {
+		var code []byte
+		for i := r.Intn(size/4 + 1); i >= 0; i-- {
+			code = append(code, fakeEVMSnippet(r)...)
+		}
+		return append(code, fakeEVMEnding(r)...)
+	}
+	return reflect.ValueOf(&fakeEVMTest{outer: contract(), inner: contract(), value: r.Int63n(2)})
+}
+
+func (test *fakeEVMTest) String() string {
+	return fmt.Sprintf("outer: %x\ninner: %x\nvalue: %d", test.outer, test.inner, test.value)
+}
+
+func (test *fakeEVMTest) run() bool {
+	config := *params.AllEthashProtocolChanges
+	config.ShanghaiTime = new(uint64)
+	config.CancunTime = new(uint64)
+
+	type result struct {
+		ret  []byte
+		gas  uint64
+		err  error
+		logs []*types.Log
+	}
+	execute := func(s fakeTestedState) result {
+		s.CreateAccount(fakeEVMSender)
+		s.SetBalance(fakeEVMSender, big.NewInt(params.Ether))
+		s.SetCode(fakeEVMOuter, test.outer)
+		s.SetState(fakeEVMOuter, fakeTestSlots[1], common.Hash{31: 1})
+		s.SetCode(fakeEVMInner, test.inner)
+		s.SetBalance(fakeEVMInner, big.NewInt(1))
+		s.Finalise(true)
+
+		var (
+			blockCtx = vm.BlockContext{
+				CanTransfer: func(db vm.StateDB, addr common.Address, amount *big.Int) bool {
+					return db.GetBalance(addr).Cmp(amount) >= 0
+				},
+				Transfer: func(db vm.StateDB, sender, recipient common.Address, amount *big.Int) {
+					db.SubBalance(sender, amount)
+					db.AddBalance(recipient, amount)
+				},
+				GetHash:     func(uint64) common.Hash { return common.Hash{} },
+				BlockNumber: new(big.Int),
+				Difficulty:  new(big.Int),
+				BaseFee:     new(big.Int),
+				Random:      &common.Hash{},
+				GasLimit:    10_000_000,
+			}
+			evm   = vm.NewEVM(blockCtx, vm.TxContext{Origin: fakeEVMSender, GasPrice: new(big.Int)}, s, &config, vm.Config{})
+			rules = config.Rules(blockCtx.BlockNumber, true, blockCtx.Time)
+		)
+		s.SetTxContext(fakeTestTx, 0)
+		s.Prepare(rules, fakeEVMSender, common.Address{}, &fakeEVMOuter, vm.ActivePrecompiles(rules), nil)
+		ret, gas, err := evm.Call(vm.AccountRef(fakeEVMSender), fakeEVMOuter, nil, 1_000_000, big.NewInt(test.value), -1)
+		s.Finalise(true)
+		return result{ret, gas, err, s.GetLogs(fakeTestTx, 0, common.Hash{})}
+	}
+	fake := newCompleteFakeState()
+	sdb := newStateDB()
+	have, want := execute(fake), execute(sdb)
+
+	switch {
+	case !bytes.Equal(have.ret, want.ret):
+		test.err = fmt.Errorf("return data: have %x, want %x", have.ret, want.ret)
+	case have.gas != want.gas:
+		test.err = fmt.Errorf("gas left: have %d, want %d", have.gas, want.gas)
+	case !errors.Is(have.err, want.err) && fmt.Sprint(have.err) != fmt.Sprint(want.err):
+		test.err = fmt.Errorf("error: have %v, want %v", have.err, want.err)
+	default:
+		if err := compareLogs(have.logs, want.logs); err != nil {
+			test.err = err
+		} else {
+			test.err = compareFakeState(fake, sdb)
+		}
+	}
+	return test.err == nil
+}
+
+func TestFakeStateEVMMatchesStateDB(t *testing.T) {
+	config := &quick.Config{MaxCount: 500}
+	err := quick.Check((*fakeEVMTest).run, config)
+	if cerr, ok := err.(*quick.CheckError); ok {
+		test := cerr.In[0].(*fakeEVMTest)
+		t.Errorf("%v:\n%s", test.err, test)
+	} else if err != nil {
+		t.Error(err)
+	}
+}
+
+// Tests that the state does not make up what it did not know: reverting the
+// write of an unknown slot forgets it again, and accounts missing from it are
+// not taken as created in the transaction.
+func TestFakeStateUnknown(t *testing.T) {
+	var (
+		fake    = NewFakeState()
+		addr    = common.Address{1}
+		created = common.Address{2}
+		slot    = common.Hash{1}
+	)
+	fake.setAccountObject(newFakeAccountObject(addr, fakeAccountData{Balance: big.NewInt(1)}))
+
+	snap := fake.Snapshot()
+	fake.SetState(addr, slot, common.Hash{2})
+	fake.RevertToSnapshot(snap)
+	fake.StateJudge = true
+	if fake.GetState(addr, slot); fake.StateJudge {
+		t.Error("reverted unknown slot read as known")
+	}
+	if fake.GetCommittedState(addr, slot); fake.StateJudge {
+		t.Error("reverted unknown slot read as known at transaction start")
+	}
+
+	fake.AddBalance(common.Address{3}, big.NewInt(1))
+	fake.Selfdestruct6780(common.Address{3})
+	if fake.HasSelfDestructed(common.Address{3}) {
+		t.Error("account missing from the state destructed")
+	}
+	fake.CreateAccount(created)
+	fake.Selfdestruct6780(created)
+	if !fake.HasSelfDestructed(created) {
+		t.Error("created account not destructed")
+	}
+}
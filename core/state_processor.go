@@ -58,6 +58,28 @@ func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consen
 // returns the amount of gas that was used in the process. If any of the
 // transactions failed to execute due to insufficient gas it will return an error.
 func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, error) {
+	receipts, allLogs, usedGas, _, err := p.process(block, statedb, cfg)
+	return receipts, allLogs, usedGas, err
+}
+
+// ProcessGraph processes the block like Process, with the dependency graph
+// enabled. All transactions run through the same EVM, whose shadow state is
+// carried over from one transaction to the next, so the returned graph spans
+// the whole block.
+func (p *StateProcessor) ProcessGraph(block *types.Block, statedb *state.StateDB, cfg vm.Config) (*BlockGraph, types.Receipts, []*types.Log, uint64, error) {
+	cfg.EnableDFG = true
+	receipts, allLogs, usedGas, vmenv, err := p.process(block, statedb, cfg)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
+	graph := &BlockGraph{
+		Graph:  vmenv.Graph,
+		TxDeps: vmenv.Graph.TxDependencies(),
+	}
+	return graph, receipts, allLogs, usedGas, nil
+}
+
+func (p *StateProcessor) process(block *types.Block, statedb *state.StateDB, cfg vm.Config) (types.Receipts, []*types.Log, uint64, *vm.EVM, error) {
 	var (
 		receipts    types.Receipts
 		usedGas     = new(uint64)
@@ -77,18 +99,22 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		signer  = types.MakeSigner(p.config, header.Number, header.Time)
 	)
 	if beaconRoot := block.BeaconRoot(); beaconRoot != nil {
+		if cfg.EnableDFG {
+			// Keep the system call apart from the first transaction in the graph
+			statedb.SetTxContext(common.Hash{}, -1)
+		}
 		ProcessBeaconBlockRoot(*beaconRoot, vmenv, statedb)
 	}
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
 		msg, err := TransactionToMessage(tx, signer, header.BaseFee)
 		if err != nil {
-			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			return nil, nil, 0, nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		statedb.SetTxContext(tx.Hash(), i)
 		receipt, err := applyTransaction(msg, p.config, gp, statedb, blockNumber, blockHash, tx, usedGas, vmenv)
 		if err != nil {
-			return nil, nil, 0, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
+			return nil, nil, 0, nil, fmt.Errorf("could not apply tx %d [%v]: %w", i, tx.Hash().Hex(), err)
 		}
 		receipts = append(receipts, receipt)
 		allLogs = append(allLogs, receipt.Logs...)
@@ -96,12 +122,12 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 	// Fail if Shanghai not enabled and len(withdrawals) is non-zero.
 	withdrawals := block.Withdrawals()
 	if len(withdrawals) > 0 && !p.config.IsShanghai(block.Number(), block.Time()) {
-		return nil, nil, 0, errors.New("withdrawals before shanghai")
+		return nil, nil, 0, nil, errors.New("withdrawals before shanghai")
 	}
 	// Finalize the block, applying any consensus engine specific extras (e.g. block rewards)
 	p.engine.Finalize(p.bc, header, statedb, block.Transactions(), block.Uncles(), withdrawals)
 
-	return receipts, allLogs, *usedGas, nil
+	return receipts, allLogs, *usedGas, vmenv, nil
 }
 
 func applyTransaction(msg *Message, config *params.ChainConfig, gp *GasPool, statedb *state.StateDB, blockNumber *big.Int, blockHash common.Hash, tx *types.Transaction, usedGas *uint64, evm *vm.EVM) (*types.Receipt, error) {
@@ -0,0 +1,235 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package analytics measures the parallelism available in a dependency graph:
+// how long its critical path is, how wide it is, and how much faster than
+// sequentially it could run on a number of workers.
+package analytics
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// Weight is the cost of executing a vertex of the graph.
+type Weight uint8
+
+const (
+	ByCount Weight = iota // every vertex costs one
+	ByGas                 // every vertex costs the gas charged for it
+)
+
+func (w Weight) String() string {
+	if w == ByGas {
+		return "gas"
+	}
+	return "count"
+}
+
+// ParseWeight returns the weight of the given name, the empty name being
+// ByCount.
+func ParseWeight(name string) (Weight, error) {
+	switch name {
+	case "", "count":
+		return ByCount, nil
+	case "gas":
+		return ByGas, nil
+	}
+	return 0, fmt.Errorf("unknown weight %q, want count or gas", name)
+}
+
+func (w Weight) of(v vm.Metadata) uint64 {
+	if w == ByGas {
+		return v.Gas
+	}
+	return 1
+}
+
+// DefaultWorkers are the numbers of workers the speedup is computed for if
+// none are given.
+var DefaultWorkers = []int{2, 4, 8, 16}
+
+// Config selects what Analyze computes.
+type Config struct {
+	Weight  Weight // Cost of the vertices
+	Workers []int  // Numbers of workers to compute the speedup for, DefaultWorkers if nil
+}
+
+// Report holds the parallelism metrics of a graph. The source vertices, one
+// per vm.SourceKind, are left out of all of them.
+type Report struct {
+	Weight       string         `json:"weight"`
+	Vertices     int            `json:"vertices"`
+	Dependencies int            `json:"dependencies"` // Pairs of dependent vertices
+	Work         uint64         `json:"work"`         // Weight of all the vertices, the sequential execution time
+	CriticalPath uint64         `json:"criticalPath"` // Weight of the heaviest path
+	PathLength   int            `json:"pathLength"`   // Vertices on the heaviest path
+	Levels       int            `json:"levels"`       // Topological levels
+	MaxWidth     int            `json:"maxWidth"`     // Vertices of the widest level
+	AvgWidth     float64        `json:"avgWidth"`     // Vertices per level
+	Parallelism  float64        `json:"parallelism"`  // Work over critical path, the speedup with unlimited workers
+	Speedups     []Speedup      `json:"speedups"`
+	Contracts    []ContractStat `json:"contracts,omitempty"`
+}
+
+// Speedup is the outcome of list scheduling the graph on some workers.
+type Speedup struct {
+	Workers  int     `json:"workers"`
+	Makespan uint64  `json:"makespan"` // Time taken to execute the graph
+	Speedup  float64 `json:"speedup"`  // Work over makespan
+}
+
+// ContractStat holds the metrics of the vertices executing the code of one
+// contract, with only the dependencies between them.
+type ContractStat struct {
+	Address      common.Address `json:"address"`
+	Vertices     int            `json:"vertices"`
+	Work         uint64         `json:"work"`
+	CriticalPath uint64         `json:"criticalPath"`
+	Levels       int            `json:"levels"`
+	Parallelism  float64        `json:"parallelism"`
+}
+
+// Analyze computes the parallelism metrics of the graph.
+func Analyze(g *vm.DependencyGraph, config Config) *Report {
+	d := newDAG(g, config.Weight)
+	report := d.report(config)
+
+	// Break the metrics down per contract, heaviest first
+	groups := make(map[common.Address][]uint32)
+	for n, i := range d.index {
+		v, _ := g.Vertex(i)
+		groups[v.Addr] = append(groups[v.Addr], uint32(n))
+	}
+	for addr, nodes := range groups {
+		sub := d.sub(nodes)
+		stat := ContractStat{
+			Address:  addr,
+			Vertices: sub.len(),
+			Work:     sub.work(),
+			Levels:   countLevels(sub.levels()),
+		}
+		_, stat.CriticalPath = sub.criticalPath()
+		stat.Parallelism = ratio(stat.Work, stat.CriticalPath)
+		report.Contracts = append(report.Contracts, stat)
+	}
+	sort.Slice(report.Contracts, func(i, j int) bool {
+		a, b := report.Contracts[i], report.Contracts[j]
+		if a.Work != b.Work {
+			return a.Work > b.Work
+		}
+		return bytes.Compare(a.Address[:], b.Address[:]) < 0
+	})
+	return report
+}
+
+// report computes the metrics of the whole dag.
+func (d *dag) report(config Config) *Report {
+	report := &Report{
+		Weight:   config.Weight.String(),
+		Vertices: d.len(),
+		Work:     d.work(),
+		Speedups: make([]Speedup, 0),
+	}
+	for _, succs := range d.succs {
+		report.Dependencies += len(succs)
+	}
+	path, weight := d.criticalPath()
+	report.CriticalPath, report.PathLength = weight, len(path)
+	report.Parallelism = ratio(report.Work, report.CriticalPath)
+
+	widths := levelWidths(d.levels())
+	report.Levels = len(widths)
+	for _, width := range widths {
+		if width > report.MaxWidth {
+			report.MaxWidth = width
+		}
+	}
+	if report.Levels > 0 {
+		report.AvgWidth = float64(report.Vertices) / float64(report.Levels)
+	}
+	workers := config.Workers
+	if workers == nil {
+		workers = DefaultWorkers
+	}
+	for _, n := range workers {
+		makespan := d.makespan(n)
+		report.Speedups = append(report.Speedups, Speedup{
+			Workers:  n,
+			Makespan: makespan,
+			Speedup:  ratio(report.Work, makespan),
+		})
+	}
+	return report
+}
+
+// Levels returns the topological level of every vertex of the graph but the
+// source: 0 for the vertices depending on nothing else, one more than the
+// highest level of their predecessors for the others.
+func Levels(g *vm.DependencyGraph) map[int]int {
+	d := newDAG(g, ByCount)
+	levels := make(map[int]int, d.len())
+	for n, level := range d.levels() {
+		levels[d.index[n]] = level
+	}
+	return levels
+}
+
+// CriticalPath returns the indexes of the vertices on the heaviest path
+// through the graph, in execution order, and its weight.
+func CriticalPath(g *vm.DependencyGraph, weight Weight) ([]int, uint64) {
+	d := newDAG(g, weight)
+	nodes, total := d.criticalPath()
+	path := make([]int, len(nodes))
+	for i, n := range nodes {
+		path[i] = d.index[n]
+	}
+	return path, total
+}
+
+// Makespan returns the time list scheduling takes to execute the graph on the
+// given number of workers.
+func Makespan(g *vm.DependencyGraph, weight Weight, workers int) uint64 {
+	return newDAG(g, weight).makespan(workers)
+}
+
+// levelWidths returns the number of vertices of every level.
+func levelWidths(levels []int) []int {
+	var widths []int
+	for _, level := range levels {
+		for len(widths) <= level {
+			widths = append(widths, 0)
+		}
+		widths[level]++
+	}
+	return widths
+}
+
+func countLevels(levels []int) int {
+	return len(levelWidths(levels))
+}
+
+// ratio returns a over b, 1 if b is zero.
+func ratio(a, b uint64) float64 {
+	if b == 0 {
+		return 1
+	}
+	return float64(a) / float64(b)
+}
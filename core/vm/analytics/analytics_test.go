@@ -0,0 +1,169 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analytics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testGraph returns the graph
+//
+//	   0
+//	 / | \
+//	1  2  3     4
+//	 \ |      /
+//	   5 ----
+//
+// with vertex 4 at another address, plus an edge from the source.
+func testGraph() *vm.DependencyGraph {
+	var (
+		g   = vm.NewDependencyGraph()
+		gas = []uint64{3, 10, 3, 3, 100, 5}
+		v   = make([]vm.Metadata, len(gas))
+	)
+	for i := range v {
+		v[i] = vm.Metadata{Index: i, OpCode: "ADD", Gas: gas[i], Addr: common.Address{1}}
+	}
+	v[4].Addr = common.Address{2}
+	for _, i := range []int{1, 2, 3} {
+		g.AddDependency([]vm.Metadata{v[0]}, v[i])
+	}
+	g.AddDependency([]vm.Metadata{v[1], v[2], v[4]}, v[5])
+	g.AddEdge(v[1], v[5], vm.Edge{Kind: vm.MemoryEdge, Size: 32})
+	g.AddEdge(vm.ContextSourceMeta, v[0], vm.Edge{Kind: vm.ContextEdge})
+	return g
+}
+
+func TestAnalyze(t *testing.T) {
+	report := Analyze(testGraph(), Config{Workers: []int{1, 2, 3}})
+	want := &Report{
+		Weight:       "count",
+		Vertices:     6,
+		Dependencies: 6,
+		Work:         6,
+		CriticalPath: 3,
+		PathLength:   3,
+		Levels:       3,
+		MaxWidth:     3,
+		AvgWidth:     2,
+		Parallelism:  2,
+		Speedups: []Speedup{
+			{Workers: 1, Makespan: 6, Speedup: 1},
+			{Workers: 2, Makespan: 3, Speedup: 2},
+			{Workers: 3, Makespan: 3, Speedup: 2},
+		},
+		Contracts: []ContractStat{
+			{Address: common.Address{1}, Vertices: 5, Work: 5, CriticalPath: 3, Levels: 3, Parallelism: 5. / 3},
+			{Address: common.Address{2}, Vertices: 1, Work: 1, CriticalPath: 1, Levels: 1, Parallelism: 1},
+		},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("have report\n%+v\nwant\n%+v", report, want)
+	}
+	// Weighted by gas, the independent vertex takes the longest
+	report = Analyze(testGraph(), Config{Weight: ByGas, Workers: []int{2}})
+	if report.Work != 124 || report.CriticalPath != 105 || report.PathLength != 2 {
+		t.Errorf("have work %d and critical path %d of %d vertices, want 124 and 105 of 2", report.Work, report.CriticalPath, report.PathLength)
+	}
+	// The vertex 4 is started first as it has the heaviest path ahead, all
+	// the others run on the other worker meanwhile
+	if have := report.Speedups[0].Makespan; have != 105 {
+		t.Errorf("have makespan %d on two workers, want 105", have)
+	}
+	if report.Contracts[0].Address != (common.Address{2}) {
+		t.Errorf("contracts not sorted by work")
+	}
+	path, weight := CriticalPath(testGraph(), ByGas)
+	if !reflect.DeepEqual(path, []int{4, 5}) || weight != 105 {
+		t.Errorf("have critical path %v of %d", path, weight)
+	}
+	if levels := Levels(testGraph()); levels[0] != 0 || levels[4] != 0 || levels[3] != 1 || levels[5] != 2 {
+		t.Errorf("have levels %v", levels)
+	}
+	// An empty graph has no parallelism to speak of
+	if report := Analyze(vm.NewDependencyGraph(), Config{}); report.Vertices != 0 || report.Parallelism != 1 || len(report.Speedups) != len(DefaultWorkers) {
+		t.Errorf("have empty report %+v", report)
+	}
+}
+
+// TestAnalyzeExecution checks the metrics of the graph of a contract calling
+// another one.
+func TestAnalyzeExecution(t *testing.T) {
+	var (
+		callee = common.HexToAddress("0xbb")
+		code   = []byte{
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.DUP1),
+			byte(vm.DUP1), byte(vm.PUSH1), 0xbb, byte(vm.GAS),
+			byte(vm.CALL), byte(vm.POP),
+			byte(vm.PUSH1), 0, byte(vm.MLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		}
+		calleeCode = []byte{
+			byte(vm.PUSH1), 42, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		}
+		contract = common.HexToAddress("0xaa")
+		blockCtx = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: common.Big0,
+			GasLimit:    10_000_000,
+		}
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(contract, code)
+	statedb.SetCode(callee, calleeCode)
+	rules := params.AllEthashProtocolChanges.Rules(common.Big0, false, 0)
+	statedb.Prepare(rules, common.Address{}, common.Address{}, &contract, vm.ActivePrecompiles(rules), nil)
+
+	evm := vm.NewEVM(blockCtx, vm.TxContext{GasPrice: common.Big0}, statedb, params.AllEthashProtocolChanges, vm.Config{EnableDFG: true})
+	if _, _, err := evm.Call(vm.AccountRef(common.Address{}), contract, nil, 1000000, common.Big0, vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("failed to call: %v", err)
+	}
+	g := evm.Graph
+
+	// The indexes follow the execution, so that no dependency is dropped
+	d := newDAG(g, ByCount)
+	var edges int
+	g.ForEachVertex(func(v vm.Metadata) error {
+		if !v.IsSource() {
+			edges += len(g.Targets(v.Index))
+		}
+		return nil
+	})
+	report := d.report(Config{Workers: []int{1, g.NumVertices()}})
+	if report.Dependencies != edges {
+		t.Errorf("have %d dependencies, want %d", report.Dependencies, edges)
+	}
+	if have := report.Speedups[0].Makespan; have != report.Work {
+		t.Errorf("have makespan %d on a worker, want the work %d", have, report.Work)
+	}
+	if have := report.Speedups[1].Makespan; have != report.CriticalPath {
+		t.Errorf("have makespan %d on unlimited workers, want the critical path %d", have, report.CriticalPath)
+	}
+	if report.Parallelism <= 1 {
+		t.Errorf("have parallelism %v", report.Parallelism)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analytics
+
+import (
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// dag is the compact form of a dependency graph the metrics are computed on.
+// The vertices are numbered from 0 in a topological order, the source vertices
+// being left out: the transaction input, storage, balances, code and block
+// context they stand for cost nothing and are available from the start.
+type dag struct {
+	index  []int      // vertex index in the dependency graph
+	weight []uint64   // weight of the vertex
+	preds  [][]uint32 // distinct predecessors of the vertex
+	succs  [][]uint32 // distinct successors of the vertex
+}
+
+// newDAG condenses the graph, with the vertices weighted as given. The edges
+// between two vertices count as a single dependency, whatever their kinds.
+//
+// Dependencies always go forward in the execution, so that the order of the
+// indexes is topological. Should an edge go backwards nonetheless, it is
+// dropped rather than creating a cycle.
+func newDAG(g *vm.DependencyGraph, weight Weight) *dag {
+	var (
+		indexes []int
+		weights []uint64
+	)
+	g.ForEachVertex(func(v vm.Metadata) error {
+		if !v.IsSource() {
+			indexes = append(indexes, v.Index)
+			weights = append(weights, weight.of(v))
+		}
+		return nil
+	})
+
+	d := &dag{
+		index:  indexes,
+		weight: weights,
+		preds:  make([][]uint32, len(indexes)),
+		succs:  make([][]uint32, len(indexes)),
+	}
+	position := make(map[int]uint32, len(indexes))
+	for n, i := range indexes {
+		position[i] = uint32(n)
+	}
+	// The targets come in increasing order, and so do the predecessors as
+	// the vertices are walked in order
+	for n, i := range indexes {
+		for _, target := range g.Targets(i) {
+			m, ok := position[target]
+			if !ok || m <= uint32(n) {
+				continue
+			}
+			d.succs[n] = append(d.succs[n], m)
+			d.preds[m] = append(d.preds[m], uint32(n))
+		}
+	}
+	return d
+}
+
+// sub returns the dag induced by the given vertices, in increasing order.
+func (d *dag) sub(nodes []uint32) *dag {
+	s := &dag{
+		index:  make([]int, len(nodes)),
+		weight: make([]uint64, len(nodes)),
+		preds:  make([][]uint32, len(nodes)),
+		succs:  make([][]uint32, len(nodes)),
+	}
+	position := make(map[uint32]uint32, len(nodes))
+	for m, n := range nodes {
+		position[n] = uint32(m)
+		s.index[m], s.weight[m] = d.index[n], d.weight[n]
+	}
+	for m, n := range nodes {
+		for _, succ := range d.succs[n] {
+			if k, ok := position[succ]; ok {
+				s.succs[m] = append(s.succs[m], k)
+				s.preds[k] = append(s.preds[k], uint32(m))
+			}
+		}
+	}
+	return s
+}
+
+func (d *dag) len() int {
+	return len(d.index)
+}
+
+// levels returns the topological level of every vertex: 0 for the vertices
+// depending on nothing, one more than the highest level of its predecessors
+// for the others.
+func (d *dag) levels() []int {
+	levels := make([]int, d.len())
+	for n, preds := range d.preds {
+		for _, p := range preds {
+			if levels[p]+1 > levels[n] {
+				levels[n] = levels[p] + 1
+			}
+		}
+	}
+	return levels
+}
+
+// criticalPath returns the heaviest path through the dag, and its weight.
+func (d *dag) criticalPath() ([]uint32, uint64) {
+	if d.len() == 0 {
+		return nil, 0
+	}
+	var (
+		dist = make([]uint64, d.len()) // weight of the heaviest path ending at the vertex
+		prev = make([]int64, d.len())  // previous vertex on that path, -1 for none
+		last uint32
+	)
+	for n, preds := range d.preds {
+		prev[n] = -1
+		for _, p := range preds {
+			if prev[n] < 0 || dist[p] > dist[prev[n]] {
+				prev[n] = int64(p)
+			}
+		}
+		dist[n] = d.weight[n]
+		if prev[n] >= 0 {
+			dist[n] += dist[prev[n]]
+		}
+		if dist[n] > dist[last] {
+			last = uint32(n)
+		}
+	}
+	var path []uint32
+	for n := int64(last); n >= 0; n = prev[n] {
+		path = append(path, uint32(n))
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, dist[last]
+}
+
+// bottomLevels returns the weight of the heaviest path starting at every
+// vertex, which list scheduling ranks the ready vertices by.
+func (d *dag) bottomLevels() []uint64 {
+	bottom := make([]uint64, d.len())
+	for n := d.len() - 1; n >= 0; n-- {
+		var max uint64
+		for _, s := range d.succs[n] {
+			if bottom[s] > max {
+				max = bottom[s]
+			}
+		}
+		bottom[n] = d.weight[n] + max
+	}
+	return bottom
+}
+
+func (d *dag) work() uint64 {
+	var work uint64
+	for _, w := range d.weight {
+		work += w
+	}
+	return work
+}
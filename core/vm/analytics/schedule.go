@@ -0,0 +1,100 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package analytics
+
+import "container/heap"
+
+// readyQueue holds the vertices whose predecessors are all done, the one with
+// the heaviest path ahead first, then the earliest one.
+type readyQueue struct {
+	nodes  []uint32
+	bottom []uint64
+}
+
+func (q *readyQueue) Len() int { return len(q.nodes) }
+func (q *readyQueue) Less(i, j int) bool {
+	a, b := q.nodes[i], q.nodes[j]
+	if q.bottom[a] != q.bottom[b] {
+		return q.bottom[a] > q.bottom[b]
+	}
+	return a < b
+}
+func (q *readyQueue) Swap(i, j int) { q.nodes[i], q.nodes[j] = q.nodes[j], q.nodes[i] }
+func (q *readyQueue) Push(x any)    { q.nodes = append(q.nodes, x.(uint32)) }
+func (q *readyQueue) Pop() any {
+	n := q.nodes[len(q.nodes)-1]
+	q.nodes = q.nodes[:len(q.nodes)-1]
+	return n
+}
+
+// running holds the vertices being executed, the first to finish first.
+type running []task
+
+type task struct {
+	node   uint32
+	finish uint64
+}
+
+func (r running) Len() int { return len(r) }
+func (r running) Less(i, j int) bool {
+	if r[i].finish != r[j].finish {
+		return r[i].finish < r[j].finish
+	}
+	return r[i].node < r[j].node
+}
+func (r running) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r *running) Push(x any)   { *r = append(*r, x.(task)) }
+func (r *running) Pop() any {
+	t := (*r)[len(*r)-1]
+	*r = (*r)[:len(*r)-1]
+	return t
+}
+
+// makespan returns the time list scheduling takes to execute the dag with the
+// given number of workers, every vertex taking its weight. Whenever a worker
+// is free, it starts the ready vertex with the heaviest path ahead.
+func (d *dag) makespan(workers int) uint64 {
+	if workers < 1 {
+		workers = 1
+	}
+	var (
+		pending = make([]int, d.len()) // predecessors not done yet
+		ready   = &readyQueue{bottom: d.bottomLevels()}
+		busy    running
+		now     uint64
+	)
+	for n, preds := range d.preds {
+		if pending[n] = len(preds); pending[n] == 0 {
+			ready.nodes = append(ready.nodes, uint32(n))
+		}
+	}
+	heap.Init(ready)
+	for ready.Len() > 0 || busy.Len() > 0 {
+		for busy.Len() < workers && ready.Len() > 0 {
+			n := heap.Pop(ready).(uint32)
+			heap.Push(&busy, task{n, now + d.weight[n]})
+		}
+		done := heap.Pop(&busy).(task)
+		now = done.finish
+		for _, s := range d.succs[done.node] {
+			if pending[s]--; pending[s] == 0 {
+				heap.Push(ready, s)
+			}
+		}
+	}
+	return now
+}
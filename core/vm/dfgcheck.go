@@ -0,0 +1,7 @@
+//go:build dfgcheck
+
+package vm
+
+// dfgInvariantChecks makes the interpreter verify after every step that the
+// meta stack mirrors the stack. Build with -tags dfgcheck to turn it on.
+const dfgInvariantChecks = true
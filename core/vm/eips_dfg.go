@@ -0,0 +1,176 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// 从statedb拿数据了
+func opSelfBalanceDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, SELFBALANCE)
+
+	balance, _ := uint256.FromBig(interpreter.evm.StateDB.GetBalance(scope.Contract.Address()))
+	scope.Stack.push(balance)
+
+	metaBalance := scope.metaBalance.Get(scope.Contract.Address())
+	scope.metaStack.push(newMetaRes)
+
+	source := handleOf(scope.Contract.SourceIndex)
+	interpreter.evm.Graph.addEdge(metaBalance, newMetaRes, Edge{Kind: BalanceEdge, Address: scope.Contract.Address()})
+	interpreter.evm.Graph.addEdge(source, newMetaRes, Edge{Kind: CallEdge})
+	return nil, nil
+}
+
+// opChainIDDFG implements CHAINID opcode
+func opChainIDDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, CHAINID)
+
+	chainId, _ := uint256.FromBig(interpreter.evm.chainConfig.ChainID)
+	scope.Stack.push(chainId)
+
+	scope.metaStack.push(newMetaRes)
+
+	interpreter.evm.Graph.addEdge(ContextSourceMeta.handle(), newMetaRes, Edge{Kind: ContextEdge})
+	return nil, nil
+}
+
+// opTloadDFG implements TLOAD opcode
+func opTloadDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, TLOAD)
+
+	loc := scope.Stack.peek()
+	hash := common.Hash(loc.Bytes32())
+	val := interpreter.evm.StateDB.GetTransientState(scope.Contract.Address(), hash)
+	loc.SetBytes(val.Bytes())
+
+	metaLoc := scope.metaStack.pop()
+	metaVal := scope.metaTStorage.Get(scope.Contract.Address(), hash)
+	scope.metaStack.push(newMetaRes)
+
+	source := handleOf(scope.Contract.SourceIndex)
+	interpreter.evm.Graph.addDependency([]VertexHandle{metaLoc}, newMetaRes)
+	interpreter.evm.Graph.addEdge(metaVal, newMetaRes, Edge{Kind: TransientStorageEdge, Address: scope.Contract.Address(), Slot: hash})
+	interpreter.evm.Graph.addEdge(source, newMetaRes, Edge{Kind: CallEdge})
+	return nil, nil
+}
+
+// opTstoreDFG implements TSTORE opcode
+func opTstoreDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	if interpreter.readOnly {
+		return nil, ErrWriteProtection
+	}
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, TSTORE)
+
+	loc := scope.Stack.pop()
+	val := scope.Stack.pop()
+	interpreter.evm.StateDB.SetTransientState(scope.Contract.Address(), loc.Bytes32(), val.Bytes32())
+
+	metaLoc := scope.metaStack.pop()
+	metaVal := scope.metaStack.pop()
+	scope.metaTStorage.Set(scope.Contract.Address(), loc.Bytes32(), newMetaRes)
+
+	source := handleOf(scope.Contract.SourceIndex)
+	interpreter.evm.Graph.addDependency([]VertexHandle{metaLoc, metaVal}, newMetaRes)
+	interpreter.evm.Graph.addEdge(source, newMetaRes, Edge{Kind: CallEdge})
+	return nil, nil
+}
+
+// opBaseFeeDFG implements BASEFEE opcode
+func opBaseFeeDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, BASEFEE)
+
+	baseFee, _ := uint256.FromBig(interpreter.evm.Context.BaseFee)
+	scope.Stack.push(baseFee)
+
+	scope.metaStack.push(newMetaRes)
+
+	interpreter.evm.Graph.addEdge(ContextSourceMeta.handle(), newMetaRes, Edge{Kind: ContextEdge})
+	return nil, nil
+}
+
+// opPush0DFG implements the PUSH0 opcode
+// 常量只依赖于合约代码
+func opPush0DFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, PUSH0)
+
+	scope.Stack.push(new(uint256.Int))
+
+	scope.metaStack.push(newMetaRes)
+
+	source := handleOf(scope.Contract.SourceIndex)
+	interpreter.evm.Graph.addEdge(source, newMetaRes, Edge{Kind: CallEdge})
+	return nil, nil
+}
+
+// opMcopyDFG implements the MCOPY opcode (https://eips.ethereum.org/EIPS/eip-5656)
+// 拷贝的字节保留原本的写入者，MCOPY本身依赖于操作数和被拷贝的字节
+func opMcopyDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, MCOPY)
+
+	var (
+		dst    = scope.Stack.pop()
+		src    = scope.Stack.pop()
+		length = scope.Stack.pop()
+	)
+	// These values are checked for overflow during memory expansion calculation
+	// (the memorySize function on the opcode).
+	scope.Memory.Copy(dst.Uint64(), src.Uint64(), length.Uint64())
+
+	metaDst := scope.metaStack.pop()
+	metaSrc := scope.metaStack.pop()
+	metaLength := scope.metaStack.pop()
+
+	interpreter.evm.Graph.addDependency([]VertexHandle{metaDst, metaSrc, metaLength}, newMetaRes)
+	interpreter.evm.Graph.addRangeDependency(MemoryEdge, scope.metaMemory, src.Uint64(), length.Uint64(), newMetaRes)
+	scope.metaMemory.Copy(dst.Uint64(), src.Uint64(), length.Uint64())
+	return nil, nil
+}
+
+// opBlobHashDFG implements the BLOBHASH opcode
+func opBlobHashDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, BLOBHASH)
+
+	index := scope.Stack.peek()
+	if index.LtUint64(uint64(len(interpreter.evm.TxContext.BlobHashes))) {
+		blobHash := interpreter.evm.TxContext.BlobHashes[index.Uint64()]
+		index.SetBytes32(blobHash[:])
+	} else {
+		index.Clear()
+	}
+
+	metaIndex := scope.metaStack.pop()
+	scope.metaStack.push(newMetaRes)
+
+	interpreter.evm.Graph.addDependency([]VertexHandle{metaIndex}, newMetaRes)
+	interpreter.evm.Graph.addEdge(TxInputSourceMeta.handle(), newMetaRes, Edge{Kind: ContextEdge})
+	return nil, nil
+}
+
+// opBlobBaseFeeDFG implements BLOBBASEFEE opcode
+func opBlobBaseFeeDFG(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	newMetaRes := interpreter.evm.Graph.newVertex(interpreter.evm.StateDB.GetTxId(), scope.index, *scope.Contract.CodeAddr, *pc, BLOBBASEFEE)
+
+	blobBaseFee, _ := uint256.FromBig(interpreter.evm.Context.BlobBaseFee)
+	scope.Stack.push(blobBaseFee)
+
+	scope.metaStack.push(newMetaRes)
+
+	interpreter.evm.Graph.addEdge(ContextSourceMeta.handle(), newMetaRes, Edge{Kind: ContextEdge})
+	return nil, nil
+}
@@ -18,7 +18,6 @@ package vm
 
 import (
 	"math/big"
-	"sync"
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -26,7 +25,6 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/holiman/uint256"
-	"github.com/panjf2000/ants"
 )
 
 type (
@@ -124,15 +122,17 @@ type EVM struct {
 	// applied in opCall*.
 	callGasTemp uint64
 
-	// Thread Pool instance and waitGroups
-	pool *ants.Pool
-	wg   *sync.WaitGroup
+	// parallel holds the calls spawned through ParallelSpawnAddress, only
+	// allocated when Config.ParallelCallWorkers is set
+	parallel *parallelCalls
 
-	// For DFG Generation
-	metaStack     *MetaStack
-	metaMemory    *MetaMemory
+	// For DFG Generation, only allocated when Config.EnableDFG is set
+	metaJournal   *metaJournal
 	metaStorage   *MetaStorage
+	metaTStorage  *MetaTransientStorage
 	opCodeCounter int
+	frameCounter  int
+	txFrameBase   int // first frame of the current transaction
 	Graph         *DependencyGraph
 
 	metaBalance *MetaAccount
@@ -153,20 +153,35 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 			blockCtx.BlobBaseFee = new(big.Int)
 		}
 	}
+	// Tracers may read the graph, even if it was not requested
+	if tracer, ok := config.Tracer.(GraphLogger); ok && tracer.NeedsGraph() {
+		config.EnableDFG = true
+	}
 	evm := &EVM{
-		Context:       blockCtx,
-		TxContext:     txCtx,
-		StateDB:       statedb,
-		Config:        config,
-		chainConfig:   chainConfig,
-		chainRules:    chainConfig.Rules(blockCtx.BlockNumber, blockCtx.Random != nil, blockCtx.Time),
-		metaStack:     newMetaStack(),
-		metaMemory:    newMetaMemory(),
-		metaStorage:   newMetaStorage(),
-		metaBalance:   newMetaAccount(),
-		metaCode:      newMetaAccount(),
-		opCodeCounter: 0,
-		Graph:         NewDependencyGraph(),
+		Context:     blockCtx,
+		TxContext:   txCtx,
+		StateDB:     statedb,
+		Config:      config,
+		chainConfig: chainConfig,
+		chainRules:  chainConfig.Rules(blockCtx.BlockNumber, blockCtx.Random != nil, blockCtx.Time),
+	}
+	// The shadow state is only paid for when the dependency graph is requested,
+	// the stock interpreter path never touches it.
+	if config.EnableDFG {
+		evm.metaJournal = newMetaJournal()
+		evm.metaStorage = newMetaStorage(evm.metaJournal)
+		evm.metaTStorage = newMetaTransientStorage(evm.metaJournal)
+		evm.metaBalance = newMetaAccount(evm.metaJournal, BalanceSourceMeta)
+		evm.metaCode = newMetaAccount(evm.metaJournal, CodeSourceMeta)
+		// The graph is built in full even if the tracer follows it online,
+		// the shadow state refers to its vertices
+		evm.Graph = NewDependencyGraph()
+		if tracer, ok := config.Tracer.(DependencyLogger); ok && tracer.NeedsGraph() {
+			evm.Graph.onDependency = tracer.CaptureDependency
+		}
+	}
+	if config.ParallelCallWorkers > 0 {
+		evm.parallel = newParallelCalls(config.ParallelCallWorkers)
 	}
 	evm.interpreter = NewEVMInterpreter(evm)
 	return evm
@@ -177,6 +192,18 @@ func NewEVM(blockCtx BlockContext, txCtx TxContext, statedb StateDB, chainConfig
 func (evm *EVM) Reset(txCtx TxContext, statedb StateDB) {
 	evm.TxContext = txCtx
 	evm.StateDB = statedb
+	if evm.Config.EnableDFG {
+		// The shadow storage, balances and code carry over to the next
+		// transaction, so that the graph links the transactions of a block.
+		// Transient storage does not outlive the transaction, and neither do
+		// its revert snapshots.
+		evm.metaJournal.reset()
+		evm.metaTStorage = newMetaTransientStorage(evm.metaJournal)
+		evm.txFrameBase = evm.frameCounter
+	}
+	if evm.parallel != nil {
+		evm.parallel = newParallelCalls(evm.Config.ParallelCallWorkers)
+	}
 }
 
 // Cancel cancels any running EVM operation. This may be called concurrently and
@@ -200,28 +227,20 @@ func (evm *EVM) Interpreter() *EVMInterpreter {
 // the necessary steps to create accounts and reverses the state in case of an
 // execution error or failed value transfer.
 func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int, sourceIndex int) (ret []byte, leftOverGas uint64, err error) {
-
-	if addr == common.BytesToAddress([]byte{0x19}) {
-		evm.newPool(input)
-		return nil, gas, nil
-	}
-	if addr == common.BytesToAddress([]byte{0x20}) {
-		return evm.submitInterpreterRun(caller, addr, input, gas, value)
-	}
-	if addr == common.BytesToAddress([]byte{0x21}) {
-		evm.waitWg()
-		return nil, gas, nil
-	}
-
 	// Fail if we're trying to execute above the call depth limit
 	if evm.depth > int(params.CallCreateDepth) {
 		return nil, gas, ErrDepth
 	}
+	if evm.parallel != nil && (addr == ParallelSpawnAddress || addr == ParallelJoinAddress) {
+		return evm.callParallel(caller, addr, input, gas, value, sourceIndex)
+	}
 	// Fail if we're trying to transfer more than the available balance
 	if value.Sign() != 0 && !evm.Context.CanTransfer(evm.StateDB, caller.Address(), value) {
 		return nil, gas, ErrInsufficientBalance
 	}
+	evm.metaTransferReads(caller.Address(), addr, value, sourceIndex)
 	snapshot := evm.StateDB.Snapshot()
+	metaSnapshot := evm.metaSnapshot()
 	p, isPrecompile := evm.precompile(addr)
 	debug := evm.Config.Tracer != nil
 
@@ -242,6 +261,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 		evm.StateDB.CreateAccount(addr)
 	}
 	evm.Context.Transfer(evm.StateDB, caller.Address(), addr, value)
+	evm.metaTransfer(caller.Address(), addr, value, sourceIndex)
 
 	// Capture the tracer start/end events in debug mode
 	if debug {
@@ -260,7 +280,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	}
 
 	if isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompile(p, addr, input, gas, sourceIndex)
 	} else {
 		// Initialise a new contract and set the code that is to be used by the EVM.
 		// The contract is a scoped environment for this execution context only.
@@ -282,6 +302,7 @@ func (evm *EVM) Call(caller ContractRef, addr common.Address, input []byte, gas
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.metaRevertToSnapshot(metaSnapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
 		}
@@ -312,6 +333,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 		return nil, gas, ErrInsufficientBalance
 	}
 	var snapshot = evm.StateDB.Snapshot()
+	var metaSnapshot = evm.metaSnapshot()
 
 	// Invoke tracer hooks that signal entering/exiting a call frame
 	if evm.Config.Tracer != nil {
@@ -323,7 +345,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompile(p, addr, input, gas, sourceIndex)
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and set the code that is to be used by the EVM.
@@ -335,6 +357,7 @@ func (evm *EVM) CallCode(caller ContractRef, addr common.Address, input []byte,
 	}
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.metaRevertToSnapshot(metaSnapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
 		}
@@ -353,6 +376,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 		return nil, gas, ErrDepth
 	}
 	var snapshot = evm.StateDB.Snapshot()
+	var metaSnapshot = evm.metaSnapshot()
 
 	// Invoke tracer hooks that signal entering/exiting a call frame
 	if evm.Config.Tracer != nil {
@@ -368,7 +392,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 
 	// It is allowed to call precompiles, even via delegatecall
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompile(p, addr, input, gas, sourceIndex)
 	} else {
 		addrCopy := addr
 		// Initialise a new contract and make initialise the delegate values
@@ -379,6 +403,7 @@ func (evm *EVM) DelegateCall(caller ContractRef, addr common.Address, input []by
 	}
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.metaRevertToSnapshot(metaSnapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
 		}
@@ -401,6 +426,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	// then certain tests start failing; stRevertTest/RevertPrecompiledTouchExactOOG.json.
 	// We could change this, but for now it's left for legacy reasons
 	var snapshot = evm.StateDB.Snapshot()
+	var metaSnapshot = evm.metaSnapshot()
 
 	// We do an AddBalance of zero here, just in order to trigger a touch.
 	// This doesn't matter on Mainnet, where all empties are gone at the time of Byzantium,
@@ -417,7 +443,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	}
 
 	if p, isPrecompile := evm.precompile(addr); isPrecompile {
-		ret, gas, err = RunPrecompiledContract(p, input, gas)
+		ret, gas, err = evm.runPrecompile(p, addr, input, gas, sourceIndex)
 	} else {
 		// At this point, we use a copy of address. If we don't, the go compiler will
 		// leak the 'contract' to the outer scope, and make allocation for 'contract'
@@ -435,6 +461,7 @@ func (evm *EVM) StaticCall(caller ContractRef, addr common.Address, input []byte
 	}
 	if err != nil {
 		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.metaRevertToSnapshot(metaSnapshot)
 		if err != ErrExecutionReverted {
 			gas = 0
 		}
@@ -480,12 +507,20 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		return nil, common.Address{}, 0, ErrContractAddressCollision
 	}
 	// Create a new account on the state
+	evm.metaTransferReads(caller.Address(), address, value, sourceIndex)
 	snapshot := evm.StateDB.Snapshot()
+	metaSnapshot := evm.metaSnapshot()
 	evm.StateDB.CreateAccount(address)
 	if evm.chainRules.IsEIP158 {
 		evm.StateDB.SetNonce(address, 1)
 	}
 	evm.Context.Transfer(evm.StateDB, caller.Address(), address, value)
+	evm.metaTransfer(caller.Address(), address, value, sourceIndex)
+	if evm.Config.EnableDFG {
+		// The init code runs as the code of the new account, it comes from
+		// the create vertex (or the transaction input)
+		evm.metaCode.Set(address, handleOf(sourceIndex))
+	}
 
 	// Initialise a new contract and set the code that is to be used by the EVM.
 	// The contract is a scoped environment for this execution context only.
@@ -520,6 +555,10 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 		createDataGas := uint64(len(ret)) * params.CreateDataGas
 		if contract.UseGas(createDataGas) {
 			evm.StateDB.SetCode(address, ret)
+			if evm.Config.EnableDFG {
+				// The deployed code is what the init code returned
+				evm.metaCode.Set(address, evm.interpreter.returnVertex)
+			}
 		} else {
 			err = ErrCodeStoreOutOfGas
 		}
@@ -530,6 +569,7 @@ func (evm *EVM) create(caller ContractRef, codeAndHash *codeAndHash, gas uint64,
 	// when we're in homestead this also counts for code storage gas errors.
 	if err != nil && (evm.chainRules.IsHomestead || err != ErrCodeStoreOutOfGas) {
 		evm.StateDB.RevertToSnapshot(snapshot)
+		evm.metaRevertToSnapshot(metaSnapshot)
 		if err != ErrExecutionReverted {
 			contract.UseGas(contract.Gas)
 		}
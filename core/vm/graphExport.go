@@ -0,0 +1,247 @@
+package vm
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// GraphExporter writes a dependency graph to w in some format.
+type GraphExporter interface {
+	Export(w io.Writer, g *DependencyGraph) error
+}
+
+// VertexLabel names a vertex in the exported graph.
+type VertexLabel func(v Metadata) string
+
+// DefaultVertexLabel labels a vertex with its index and opcode.
+func DefaultVertexLabel(v Metadata) string {
+	if v.IsSource() {
+		return "source " + v.SourceKind().String()
+	}
+	return fmt.Sprintf("%d %s", v.Index, v.OpCode)
+}
+
+// GraphFormats lists the formats NewGraphExporter knows.
+var GraphFormats = []string{"dot", "graphml", "json", "jsonl", "html"}
+
+// NewGraphExporter returns the exporter of a format of GraphFormats, with its
+// default settings.
+func NewGraphExporter(format string) (GraphExporter, error) {
+	switch format {
+	case "dot":
+		return new(DOTExporter), nil
+	case "graphml":
+		return new(GraphMLExporter), nil
+	case "json":
+		return new(JSONExporter), nil
+	case "jsonl":
+		return new(JSONLExporter), nil
+	case "html":
+		return new(EChartsExporter), nil
+	}
+	return nil, fmt.Errorf("unknown graph format %q, want one of %s", format, strings.Join(GraphFormats, ", "))
+}
+
+// ExportGraphFile writes the graph to the file at path.
+func ExportGraphFile(path string, g *DependencyGraph, exporter GraphExporter) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := exporter.Export(f, g); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DOTExporter writes the graph in the Graphviz DOT language. Reverted vertices
+// are dashed, control dependencies dotted.
+type DOTExporter struct {
+	Label VertexLabel // DefaultVertexLabel if nil
+}
+
+func (e *DOTExporter) Export(w io.Writer, g *DependencyGraph) error {
+	label := e.Label
+	if label == nil {
+		label = DefaultVertexLabel
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph dfg {")
+	g.ForEachVertex(func(v Metadata) error {
+		style := ""
+		if v.Reverted {
+			style = ", style=dashed"
+		}
+		_, err := fmt.Fprintf(bw, "\t%d [label=%s%s];\n", v.Index, dotQuote(label(v)), style)
+		return err
+	})
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		style := ""
+		if edge.Kind == ControlEdge {
+			style = ", style=dotted"
+		}
+		_, err := fmt.Fprintf(bw, "\t%d -> %d [label=%q%s];\n", source, target, edge.Kind, style)
+		return err
+	})
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// GraphMLExporter writes the graph as GraphML, with the attributes of the
+// vertices and edges as data.
+type GraphMLExporter struct {
+	Label VertexLabel // DefaultVertexLabel if nil
+}
+
+var graphMLKeys = []struct{ id, target, typ string }{
+	{"label", "node", "string"},
+	{"tx", "node", "int"},
+	{"address", "node", "string"},
+	{"pc", "node", "long"},
+	{"opcode", "node", "string"},
+	{"reverted", "node", "boolean"},
+	{"depth", "node", "int"},
+	{"frame", "node", "int"},
+	{"gas", "node", "long"},
+	{"value", "node", "string"},
+	{"kind", "edge", "string"},
+	{"operand", "edge", "int"},
+	{"address", "edge", "string"},
+	{"slot", "edge", "string"},
+	{"offset", "edge", "long"},
+	{"size", "edge", "long"},
+}
+
+func (e *GraphMLExporter) Export(w io.Writer, g *DependencyGraph) error {
+	label := e.Label
+	if label == nil {
+		label = DefaultVertexLabel
+	}
+	bw := bufio.NewWriter(w)
+	data := func(key string, value interface{}) {
+		fmt.Fprintf(bw, `<data key="%s">`, key)
+		xml.EscapeText(bw, []byte(fmt.Sprint(value)))
+		fmt.Fprint(bw, "</data>")
+	}
+	fmt.Fprintln(bw, xml.Header+`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	for _, key := range graphMLKeys {
+		fmt.Fprintf(bw, "  <key id=\"%s.%s\" for=\"%s\" attr.name=\"%s\" attr.type=\"%s\"/>\n", key.target, key.id, key.target, key.id, key.typ)
+	}
+	fmt.Fprintln(bw, `  <graph id="dfg" edgedefault="directed">`)
+	g.ForEachVertex(func(v Metadata) error {
+		fmt.Fprintf(bw, `    <node id="n%d">`, v.Index)
+		data("node.label", label(v))
+		data("node.tx", v.TxId)
+		data("node.address", v.Addr.Hex())
+		data("node.pc", v.Pc)
+		data("node.opcode", v.OpCode)
+		data("node.reverted", v.Reverted)
+		data("node.depth", v.Depth)
+		data("node.frame", v.Frame)
+		data("node.gas", v.Gas)
+		if v.Value != nil {
+			data("node.value", v.Value.Hex())
+		}
+		_, err := fmt.Fprintln(bw, "</node>")
+		return err
+	})
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		fmt.Fprintf(bw, `    <edge source="n%d" target="n%d">`, source, target)
+		data("edge.kind", edge.Kind)
+		data("edge.operand", edge.Operand)
+		data("edge.address", edge.Address.Hex())
+		data("edge.slot", edge.Slot.Hex())
+		data("edge.offset", edge.Offset)
+		data("edge.size", edge.Size)
+		_, err := fmt.Fprintln(bw, "</edge>")
+		return err
+	})
+	fmt.Fprintln(bw, "  </graph>\n</graphml>")
+	return bw.Flush()
+}
+
+// graphEdgeJSON is an edge of the graph along with its ends.
+type graphEdgeJSON struct {
+	Source int `json:"source"`
+	Target int `json:"target"`
+	Edge
+}
+
+// JSONExporter writes the graph as a single JSON document, holding the
+// vertices ordered by index and the edges ordered by source then target, so
+// that the same graph always gives the same output.
+type JSONExporter struct {
+	Indent bool // Whether to indent the document
+}
+
+func (e *JSONExporter) Export(w io.Writer, g *DependencyGraph) error {
+	doc := struct {
+		Vertices  []Metadata         `json:"vertices"`
+		Edges     []graphEdgeJSON    `json:"edges"`
+		Constants map[int][]Constant `json:"constants,omitempty"`
+	}{
+		Vertices: make([]Metadata, 0, g.NumVertices()),
+		Edges:    make([]graphEdgeJSON, 0, g.NumEdges()),
+	}
+	g.ForEachVertex(func(v Metadata) error {
+		doc.Vertices = append(doc.Vertices, v)
+		if constants := g.Constants(v.Index); constants != nil {
+			if doc.Constants == nil {
+				doc.Constants = make(map[int][]Constant)
+			}
+			doc.Constants[v.Index] = constants
+		}
+		return nil
+	})
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		doc.Edges = append(doc.Edges, graphEdgeJSON{source, target, edge})
+		return nil
+	})
+	enc := json.NewEncoder(w)
+	if e.Indent {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(doc)
+}
+
+// JSONLExporter writes the graph as JSON lines, the vertices first and then
+// the edges, in the order of JSONExporter. The constants of a vertex are part
+// of its line. Every line is written as soon as it is encoded, so that graphs
+// of millions of vertices are never held in memory twice.
+type JSONLExporter struct{}
+
+func (e *JSONLExporter) Export(w io.Writer, g *DependencyGraph) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := g.ForEachVertex(func(v Metadata) error {
+		return enc.Encode(struct {
+			Type string `json:"type"`
+			Metadata
+			Constants []Constant `json:"constants,omitempty"`
+		}{"vertex", v, g.Constants(v.Index)})
+	})
+	if err != nil {
+		return err
+	}
+	err = g.ForEachEdge(func(source, target int, edge Edge) error {
+		return enc.Encode(struct {
+			Type string `json:"type"`
+			graphEdgeJSON
+		}{"edge", graphEdgeJSON{source, target, edge}})
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
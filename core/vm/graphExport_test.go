@@ -0,0 +1,188 @@
+package vm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// exportTestGraph returns a graph with an edge of most kinds, a reverted
+// vertex and two edges between the same vertices.
+func exportTestGraph() *DependencyGraph {
+	var (
+		g      = NewDependencyGraph()
+		push   = Metadata{Index: 0, OpCode: "PUSH1", Value: uint256.NewInt(1)}
+		mstore = Metadata{Index: 1, Pc: 2, OpCode: "MSTORE"}
+		jumpi  = Metadata{Index: 2, Pc: 3, OpCode: "JUMPI"}
+		sstore = Metadata{Index: 3, Pc: 5, OpCode: `SSTORE "x"`, Reverted: true}
+	)
+	g.AddDependency([]Metadata{push, push}, mstore)
+	g.AddDependency([]Metadata{TxInputSourceMeta}, jumpi)
+	g.AddEdge(mstore, sstore, Edge{Kind: MemoryEdge, Offset: 0, Size: 32})
+	g.AddControlDependency(jumpi, sstore)
+	g.AddEdge(StorageSourceMeta, sstore, Edge{Kind: StorageEdge, Address: common.Address{1}, Slot: common.Hash{2}})
+	return g
+}
+
+func TestDOTExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := new(DOTExporter).Export(&buf, exportTestGraph()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	for _, want := range []string{
+		"digraph dfg {\n",
+		"\t-1 [label=\"source input\"];\n",
+		"\t3 [label=\"3 SSTORE \\\"x\\\"\", style=dashed];\n",
+		"\t0 -> 1 [label=\"stack\"];\n",
+		"\t2 -> 3 [label=\"control\", style=dotted];\n",
+		"\t-2 -> 3 [label=\"storage\"];\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %q in\n%s", want, buf.String())
+		}
+	}
+	if have := strings.Count(buf.String(), "0 -> 1 "); have != 2 {
+		t.Errorf("have %d edges from the push to the mstore, want 2", have)
+	}
+}
+
+func TestGraphMLExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := new(GraphMLExporter).Export(&buf, exportTestGraph()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	var doc struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Nodes []struct {
+			ID   string `xml:"id,attr"`
+			Data []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Target string `xml:"target,attr"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid GraphML: %v\n%s", err, buf.String())
+	}
+	if len(doc.Keys) != len(graphMLKeys) || len(doc.Nodes) != 9 || len(doc.Edges) != 6 {
+		t.Fatalf("have %d keys, %d nodes and %d edges, want %d, 9 and 6", len(doc.Keys), len(doc.Nodes), len(doc.Edges), len(graphMLKeys))
+	}
+	if label := doc.Nodes[8].Data[0]; label.Key != "node.label" || label.Value != `3 SSTORE "x"` {
+		t.Errorf("have label %+v", label)
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	var first, second bytes.Buffer
+	if err := new(JSONExporter).Export(&first, exportTestGraph()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	new(JSONExporter).Export(&second, exportTestGraph())
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("export not deterministic")
+	}
+	var doc struct {
+		Vertices []Metadata `json:"vertices"`
+		Edges    []struct {
+			Source int    `json:"source"`
+			Target int    `json:"target"`
+			Slot   string `json:"slot"`
+		} `json:"edges"`
+	}
+	if err := json.Unmarshal(first.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(doc.Vertices) != 9 || len(doc.Edges) != 6 {
+		t.Fatalf("have %d vertices and %d edges, want 9 and 6", len(doc.Vertices), len(doc.Edges))
+	}
+	for i, v := range doc.Vertices {
+		if v.Index != i-len(sourceVertices) {
+			t.Errorf("vertex %d has index %d", i, v.Index)
+		}
+	}
+	if doc.Vertices[len(sourceVertices)].Value.Uint64() != 1 {
+		t.Errorf("vertex value lost")
+	}
+	if doc.Vertices[0].ID != ContextSourceMeta.ID {
+		t.Errorf("have source id %v, want %v", doc.Vertices[0].ID, ContextSourceMeta.ID)
+	}
+	if e := doc.Edges[0]; e.Source != -2 || e.Target != 3 {
+		t.Errorf("first edge %d -> %d, want -2 -> 3", e.Source, e.Target)
+	}
+}
+
+func TestJSONLExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := new(JSONLExporter).Export(&buf, exportTestGraph()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	var (
+		scanner = bufio.NewScanner(&buf)
+		counts  = make(map[string]int)
+	)
+	for scanner.Scan() {
+		var line struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		counts[line.Type]++
+	}
+	if counts["vertex"] != 9 || counts["edge"] != 6 || len(counts) != 2 {
+		t.Errorf("have %v lines", counts)
+	}
+}
+
+func TestEChartsExporter(t *testing.T) {
+	var (
+		buf      bytes.Buffer
+		exporter = &EChartsExporter{Layout: "circular", Label: func(Metadata) string { return "op" }}
+	)
+	if err := exporter.Export(&buf, exportTestGraph()); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	for _, want := range []string{"echarts", `"circular"`, `"op"`, `"op (#3)"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	nodes, links := exporter.getNodesAndLinks(exportTestGraph())
+	if len(nodes) != 9 || len(links) != 5 {
+		t.Errorf("have %d nodes and %d links, want 9 and 5", len(nodes), len(links))
+	}
+}
+
+func TestExportGraphFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "graph.dot")
+	exporter, err := NewGraphExporter("dot")
+	if err != nil {
+		t.Fatalf("failed to create exporter: %v", err)
+	}
+	if err := ExportGraphFile(path, exportTestGraph(), exporter); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if blob, _ := os.ReadFile(path); !bytes.HasPrefix(blob, []byte("digraph")) {
+		t.Errorf("have file %q", blob)
+	}
+	if err := ExportGraphFile(filepath.Join(path, "missing"), exportTestGraph(), exporter); err == nil {
+		t.Errorf("no error writing to a missing directory")
+	}
+	if _, err := NewGraphExporter("svg"); err == nil {
+		t.Errorf("unknown format accepted")
+	}
+}
@@ -0,0 +1,298 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VertexID identifies a vertex across graphs, unlike its Index which is only
+// its position in the graph it was recorded in. An instruction is the step of
+// a frame of a transaction of a block: frames are numbered from zero in every
+// transaction, and steps count every instruction the frame executed, whether
+// it made a vertex or not. Source vertices have no block, transaction or frame
+// and their step is their kind.
+type VertexID struct {
+	Block uint64 `json:"block"`
+	Tx    int    `json:"tx"`
+	Frame int    `json:"frame"`
+	Step  int    `json:"step"`
+}
+
+func (id VertexID) String() string {
+	if id.Frame < 0 {
+		return fmt.Sprintf("source/%v", SourceKind(id.Step))
+	}
+	return fmt.Sprintf("%d/%d/%d/%d", id.Block, id.Tx, id.Frame, id.Step)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id VertexID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (id *VertexID) UnmarshalText(input []byte) error {
+	text := string(input)
+	if name, ok := strings.CutPrefix(text, "source/"); ok {
+		for kind, kindName := range sourceKindNames {
+			if kindName == name {
+				*id = sourceVertex(SourceKind(kind)).ID
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown source vertex %q", text)
+	}
+	var parsed VertexID
+	if _, err := fmt.Sscanf(text, "%d/%d/%d/%d", &parsed.Block, &parsed.Tx, &parsed.Frame, &parsed.Step); err != nil {
+		return fmt.Errorf("invalid vertex id %q: %v", text, err)
+	}
+	*id = parsed
+	return nil
+}
+
+// SourceKind tells which part of the world a source vertex stands for. The
+// source vertices are what an execution reads that no instruction wrote.
+type SourceKind uint8
+
+const (
+	TxInputSource SourceKind = iota // the transaction: call data, value, origin, gas price and blob hashes
+	StorageSource                   // the storage before the first transaction
+	BalanceSource                   // the balances before the first transaction
+	CodeSource                      // the code before the first transaction
+	ContextSource                   // the block context
+)
+
+var sourceKindNames = [...]string{
+	TxInputSource: "input",
+	StorageSource: "storage",
+	BalanceSource: "balance",
+	CodeSource:    "code",
+	ContextSource: "context",
+}
+
+func (k SourceKind) String() string {
+	if int(k) < len(sourceKindNames) {
+		return sourceKindNames[k]
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k SourceKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// sourceVertex returns the source vertex of the given kind. Source vertices
+// have negative indexes, the transaction input taking -1 as it is the source
+// of the calls entered from outside the EVM.
+func sourceVertex(kind SourceKind) Metadata {
+	return Metadata{
+		TxId:   -1,
+		Index:  -1 - int(kind),
+		OpCode: kind.String(),
+		ID:     VertexID{Tx: -1, Frame: -1, Step: int(kind)},
+	}
+}
+
+// The source vertices every graph starts with.
+var (
+	TxInputSourceMeta = sourceVertex(TxInputSource)
+	StorageSourceMeta = sourceVertex(StorageSource)
+	BalanceSourceMeta = sourceVertex(BalanceSource)
+	CodeSourceMeta    = sourceVertex(CodeSource)
+	ContextSourceMeta = sourceVertex(ContextSource)
+)
+
+// sourceVertices lists the source vertices in the order of their kinds.
+var sourceVertices = []Metadata{TxInputSourceMeta, StorageSourceMeta, BalanceSourceMeta, CodeSourceMeta, ContextSourceMeta}
+
+// IsSource reports whether the vertex is a source vertex.
+func (m Metadata) IsSource() bool {
+	return m.Index < 0 && !m.Constant
+}
+
+// SourceKind returns the kind of a source vertex.
+func (m Metadata) SourceKind() SourceKind {
+	return SourceKind(-1 - m.Index)
+}
+
+// sourceOfEdge returns the source vertex standing for what an edge of the
+// given kind reads, when its writer is not part of the graph.
+func sourceOfEdge(kind EdgeKind) Metadata {
+	switch kind {
+	case StorageEdge:
+		return StorageSourceMeta
+	case BalanceEdge:
+		return BalanceSourceMeta
+	case CodeEdge:
+		return CodeSourceMeta
+	case ContextEdge:
+		return ContextSourceMeta
+	}
+	return TxInputSourceMeta
+}
+
+// IDs maps the ids of the vertices of the graph to their indexes.
+func (g *DependencyGraph) IDs() map[VertexID]int {
+	ids := make(map[VertexID]int, g.NumVertices())
+	g.ForEachVertex(func(v Metadata) error {
+		ids[v.ID] = v.Index
+		return nil
+	})
+	return ids
+}
+
+// vertexID returns the id of the vertex of the given index.
+func (g *DependencyGraph) vertexID(index int) VertexID {
+	v, _ := g.Vertex(index)
+	return v.ID
+}
+
+// MergeGraphs returns the union of the given graphs, say those of the
+// transactions of a block recorded one by one. Vertices are told apart by
+// their ids, so a vertex found in several graphs is merged into one. The
+// vertices are indexed in the order of their ids.
+func MergeGraphs(graphs ...*DependencyGraph) *DependencyGraph {
+	var (
+		merged = NewDependencyGraph()
+		seen   = make(map[VertexID]Metadata)
+		ids    []VertexID
+	)
+	for _, g := range graphs {
+		g.ForEachVertex(func(v Metadata) error {
+			if _, ok := seen[v.ID]; !ok && !v.IsSource() {
+				seen[v.ID] = v
+				ids = append(ids, v.ID)
+			}
+			return nil
+		})
+	}
+	sortVertexIDs(ids)
+
+	remapped := make(map[VertexID]Metadata, len(ids))
+	for _, v := range sourceVertices {
+		remapped[v.ID] = v
+	}
+	for i, id := range ids {
+		v := seen[id]
+		v.Index = i
+		remapped[id] = v
+		merged.AddVertex(v)
+	}
+	for _, g := range graphs {
+		g.ForEachEdge(func(source, target int, edge Edge) error {
+			merged.AddEdge(remapped[g.vertexID(source)], remapped[g.vertexID(target)], edge)
+			return nil
+		})
+		for h := range g.operands {
+			j := remapped[g.vertexID(h.Index())].Index
+			if _, ok := merged.operands[handleOf(j)]; !ok {
+				merged.copyConstants(g, h.Index(), j)
+			}
+		}
+	}
+	return merged
+}
+
+// less orders the ids by block, transaction, frame and step.
+func (id VertexID) less(other VertexID) bool {
+	if id.Block != other.Block {
+		return id.Block < other.Block
+	}
+	if id.Tx != other.Tx {
+		return id.Tx < other.Tx
+	}
+	if id.Frame != other.Frame {
+		return id.Frame < other.Frame
+	}
+	return id.Step < other.Step
+}
+
+// EdgeID is an edge identified by the ids of its ends.
+type EdgeID struct {
+	Source VertexID `json:"source"`
+	Target VertexID `json:"target"`
+	Edge
+}
+
+// GraphDiff is what tells two graphs apart, the vertices and edges being
+// compared by id.
+type GraphDiff struct {
+	AddedVertices   []VertexID `json:"addedVertices"`   // vertices only in the second graph
+	RemovedVertices []VertexID `json:"removedVertices"` // vertices only in the first graph
+	AddedEdges      []EdgeID   `json:"addedEdges"`      // edges only in the second graph
+	RemovedEdges    []EdgeID   `json:"removedEdges"`    // edges only in the first graph
+}
+
+// Empty reports whether the graphs are the same.
+func (d *GraphDiff) Empty() bool {
+	return len(d.AddedVertices) == 0 && len(d.RemovedVertices) == 0 && len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0
+}
+
+// DiffGraphs compares graph a to graph b, say the graphs of a transaction run
+// before and after a change. The differences are ordered by id.
+func DiffGraphs(a, b *DependencyGraph) *GraphDiff {
+	var (
+		diff           = new(GraphDiff)
+		aIDs, bIDs     = a.IDs(), b.IDs()
+		aEdges, bEdges = a.edgeIDs(), b.edgeIDs()
+	)
+	for id := range bIDs {
+		if _, ok := aIDs[id]; !ok {
+			diff.AddedVertices = append(diff.AddedVertices, id)
+		}
+	}
+	for id := range aIDs {
+		if _, ok := bIDs[id]; !ok {
+			diff.RemovedVertices = append(diff.RemovedVertices, id)
+		}
+	}
+	for e := range bEdges {
+		if !aEdges[e] {
+			diff.AddedEdges = append(diff.AddedEdges, e)
+		}
+	}
+	for e := range aEdges {
+		if !bEdges[e] {
+			diff.RemovedEdges = append(diff.RemovedEdges, e)
+		}
+	}
+	sortVertexIDs(diff.AddedVertices)
+	sortVertexIDs(diff.RemovedVertices)
+	sortEdgeIDs(diff.AddedEdges)
+	sortEdgeIDs(diff.RemovedEdges)
+	return diff
+}
+
+// edgeIDs returns the edges of the graph by the ids of their ends.
+func (g *DependencyGraph) edgeIDs() map[EdgeID]bool {
+	edges := make(map[EdgeID]bool)
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		edges[EdgeID{g.vertexID(source), g.vertexID(target), edge}] = true
+		return nil
+	})
+	return edges
+}
+
+func sortVertexIDs(ids []VertexID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+}
+
+// sortEdgeIDs orders the edges by source, target, kind, operand and offset.
+func sortEdgeIDs(edges []EdgeID) {
+	sort.Slice(edges, func(i, j int) bool {
+		a, b := edges[i], edges[j]
+		switch {
+		case a.Source != b.Source:
+			return a.Source.less(b.Source)
+		case a.Target != b.Target:
+			return a.Target.less(b.Target)
+		case a.Kind != b.Kind:
+			return a.Kind < b.Kind
+		case a.Operand != b.Operand:
+			return a.Operand < b.Operand
+		}
+		return a.Offset < b.Offset
+	})
+}
@@ -0,0 +1,82 @@
+package vm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// txTestGraph returns the graph of a transaction recorded on its own, an
+// SLOAD of the initial storage feeding an SSTORE.
+func txTestGraph(tx int) *DependencyGraph {
+	var (
+		g      = NewDependencyGraph()
+		sload  = Metadata{TxId: tx, Index: 0, OpCode: "SLOAD", ID: VertexID{Block: 1, Tx: tx, Step: 1}}
+		sstore = Metadata{TxId: tx, Index: 1, OpCode: "SSTORE", ID: VertexID{Block: 1, Tx: tx, Step: 3}}
+	)
+	g.AddEdge(StorageSourceMeta, sload, Edge{Kind: StorageEdge, Slot: common.Hash{1}})
+	g.AddDependency([]Metadata{sload}, sstore)
+	g.addConstant(g.newConstant(sload.handle(), 2, uint256.NewInt(1)), 0, sstore.handle())
+	return g
+}
+
+func TestMergeGraphs(t *testing.T) {
+	merged := MergeGraphs(txTestGraph(1), txTestGraph(0), txTestGraph(1))
+	if merged.NumVertices() != 4+len(sourceVertices) {
+		t.Fatalf("have %d vertices, want 4 and the sources", merged.NumVertices())
+	}
+	for i, tx := range []int{0, 0, 1, 1} {
+		if v, _ := merged.Vertex(i); v.Index != i || v.TxId != tx {
+			t.Errorf("have vertex %d %+v", i, v)
+		}
+	}
+	if have := merged.Kinds(StorageSourceMeta.Index, 2); len(have) != 1 || have[0] != StorageEdge {
+		t.Errorf("have source edges %v", have)
+	}
+	if have := merged.Kinds(2, 3); !reflect.DeepEqual(have, []EdgeKind{StackEdge, CallEdge}) {
+		t.Errorf("have edges %v", have)
+	}
+	if have := merged.Constants(3); len(have) != 1 || have[0].Value.Uint64() != 1 {
+		t.Errorf("have constants %v", have)
+	}
+	if ids := merged.IDs(); ids[VertexID{Block: 1, Tx: 1, Step: 3}] != 3 || ids[CodeSourceMeta.ID] != CodeSourceMeta.Index {
+		t.Errorf("have ids %v", ids)
+	}
+}
+
+func TestDiffGraphs(t *testing.T) {
+	var (
+		a = txTestGraph(0)
+		b = txTestGraph(0)
+	)
+	if diff := DiffGraphs(a, b); !diff.Empty() {
+		t.Errorf("have diff %+v of the same graphs", diff)
+	}
+	// The SSTORE now takes the value from the call data instead
+	b = NewDependencyGraph()
+	sload, _ := a.Vertex(0)
+	sstore, _ := a.Vertex(1)
+	add := Metadata{Index: 2, OpCode: "ADD", ID: VertexID{Block: 1, Step: 2}}
+	b.AddEdge(StorageSourceMeta, sload, Edge{Kind: StorageEdge, Slot: common.Hash{1}})
+	b.AddEdge(TxInputSourceMeta, add, Edge{Kind: CallEdge})
+	b.AddDependency([]Metadata{add}, sstore)
+
+	diff := DiffGraphs(a, b)
+	if !reflect.DeepEqual(diff.AddedVertices, []VertexID{add.ID}) || len(diff.RemovedVertices) != 0 {
+		t.Errorf("have vertex diff +%v -%v", diff.AddedVertices, diff.RemovedVertices)
+	}
+	if len(diff.AddedEdges) != 2 || diff.AddedEdges[0].Source != TxInputSourceMeta.ID || diff.AddedEdges[1].Target != sstore.ID {
+		t.Errorf("have added edges %v", diff.AddedEdges)
+	}
+	if len(diff.RemovedEdges) != 2 || diff.RemovedEdges[0].Kind != StackEdge || diff.RemovedEdges[1].Kind != CallEdge {
+		t.Errorf("have removed edges %v", diff.RemovedEdges)
+	}
+	if have := (VertexID{Block: 1, Tx: 2, Frame: 3, Step: 4}).String(); have != "1/2/3/4" {
+		t.Errorf("have id %s", have)
+	}
+	if have := BalanceSourceMeta.ID.String(); have != "source/balance" {
+		t.Errorf("have source id %s", have)
+	}
+}
@@ -0,0 +1,140 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GraphLevel is the granularity of the vertices of a dependency graph.
+type GraphLevel uint8
+
+const (
+	InstructionLevel GraphLevel = iota // a vertex per executed instruction
+	CallLevel                          // a vertex per call frame
+	TxLevel                            // a vertex per transaction
+)
+
+var graphLevelNames = [...]string{
+	InstructionLevel: "instruction",
+	CallLevel:        "call",
+	TxLevel:          "tx",
+}
+
+func (l GraphLevel) String() string {
+	if int(l) < len(graphLevelNames) {
+		return graphLevelNames[l]
+	}
+	return "unknown"
+}
+
+// ParseGraphLevel returns the level of the given name, the empty name being
+// InstructionLevel.
+func ParseGraphLevel(name string) (GraphLevel, error) {
+	if name == "" {
+		return InstructionLevel, nil
+	}
+	for l, n := range graphLevelNames {
+		if n == name {
+			return GraphLevel(l), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown graph level %q, want one of %s", name, strings.Join(graphLevelNames[:], ", "))
+}
+
+// ParseEdgeKind returns the edge kind of the given name.
+func ParseEdgeKind(name string) (EdgeKind, error) {
+	for k, n := range edgeKindNames {
+		if n == name {
+			return EdgeKind(k), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown edge kind %q, want one of %s", name, strings.Join(edgeKindNames[:], ", "))
+}
+
+// FilterEdges returns a copy of the graph holding only the edges of the given
+// kinds. Every vertex is kept.
+func (g *DependencyGraph) FilterEdges(kinds ...EdgeKind) *DependencyGraph {
+	keep := make(map[EdgeKind]bool, len(kinds))
+	for _, kind := range kinds {
+		keep[kind] = true
+	}
+	filtered := NewDependencyGraph()
+	g.ForEachVertex(func(v Metadata) error {
+		filtered.AddVertex(v)
+		filtered.copyConstants(g, v.Index, v.Index)
+		return nil
+	})
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		if keep[edge.Kind] {
+			from, _ := filtered.Vertex(source)
+			to, _ := filtered.Vertex(target)
+			filtered.AddEdge(from, to, edge)
+		}
+		return nil
+	})
+	return filtered
+}
+
+// Condense returns the graph at the given level. Every call frame, or every
+// transaction, becomes a vertex indexed by its frame id or transaction id, and
+// the edges between the instructions of different groups become edges between
+// the groups. Those keep their kind, address and slot: the operand and byte
+// ranges only make sense for instructions. The source vertices stay as they
+// are.
+//
+// A group vertex takes the address, transaction, frame and id of its first
+// instruction, the lowest depth and the gas of all its instructions. It is
+// reverted if all of them are.
+func (g *DependencyGraph) Condense(level GraphLevel) *DependencyGraph {
+	if level == InstructionLevel {
+		return g
+	}
+	var (
+		condensed = NewDependencyGraph()
+		groups    = make(map[int]int, g.NumVertices()) // instruction index -> group index
+	)
+	g.ForEachVertex(func(v Metadata) error {
+		i, group := v.Index, v.Frame
+		if level == TxLevel {
+			group = v.TxId
+		}
+		if v.IsSource() {
+			groups[i] = i
+			return nil
+		}
+		if group < 0 {
+			// System calls ahead of the first transaction are part of the
+			// block context it starts from
+			groups[i] = ContextSourceMeta.Index
+			return nil
+		}
+		groups[i] = group
+
+		vertex, ok := condensed.Vertex(group)
+		if !ok {
+			vertex = Metadata{TxId: v.TxId, Index: group, Addr: v.Addr, OpCode: level.String(), Reverted: true, Depth: v.Depth, Frame: v.Frame}
+			vertex.ID = VertexID{Block: v.ID.Block, Tx: v.ID.Tx, Frame: v.ID.Frame}
+			if level == TxLevel {
+				vertex.ID.Frame = 0
+			}
+		}
+		if v.Depth < vertex.Depth {
+			vertex.Depth = v.Depth
+		}
+		vertex.Gas += v.Gas
+		vertex.Reverted = vertex.Reverted && v.Reverted
+		condensed.AddVertex(vertex)
+		return nil
+	})
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		from, to := groups[source], groups[target]
+		if from != to {
+			edge = Edge{Kind: edge.Kind, Address: edge.Address, Slot: edge.Slot}
+			fromVertex, _ := condensed.Vertex(from)
+			toVertex, _ := condensed.Vertex(to)
+			condensed.AddEdge(fromVertex, toVertex, edge)
+		}
+		return nil
+	})
+	return condensed
+}
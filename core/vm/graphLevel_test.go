@@ -0,0 +1,91 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCondenseGraph(t *testing.T) {
+	var (
+		g = NewDependencyGraph()
+		// Frame 0 of tx 0 calls frame 1, frame 2 is tx 1
+		push   = Metadata{Index: 0, OpCode: "PUSH1", Gas: 3}
+		call   = Metadata{Index: 1, OpCode: "CALL", Gas: 100}
+		sstore = Metadata{Index: 2, OpCode: "SSTORE", Frame: 1, Depth: 1, Gas: 20000, Reverted: true}
+		sload  = Metadata{TxId: 1, Index: 3, OpCode: "SLOAD", Frame: 2, Gas: 2100}
+	)
+	g.AddDependency([]Metadata{push}, call)
+	g.AddEdge(call, sstore, Edge{Kind: CallEdge, Offset: 0, Size: 32})
+	g.AddEdge(call, sstore, Edge{Kind: CallEdge, Offset: 32, Size: 32})
+	g.AddEdge(sstore, sload, Edge{Kind: StorageEdge, Slot: common.Hash{1}})
+	g.AddEdge(ContextSourceMeta, push, Edge{Kind: ContextEdge})
+
+	if g.Condense(InstructionLevel) != g {
+		t.Errorf("instruction level graph not returned as is")
+	}
+	calls := g.Condense(CallLevel)
+	if calls.NumVertices() != 3+len(sourceVertices) {
+		t.Fatalf("have %d call vertices, want 3 and the sources", calls.NumVertices())
+	}
+	if v, _ := calls.Vertex(0); v.Gas != 103 || v.OpCode != "call" || v.Reverted {
+		t.Errorf("have frame vertex %+v", v)
+	}
+	if v, _ := calls.Vertex(1); v.Depth != 1 || !v.Reverted {
+		t.Errorf("have frame vertex %+v", v)
+	}
+	if have := calls.Kinds(0, 1); len(have) != 1 || have[0] != CallEdge {
+		t.Errorf("have edges %v between the frames, want a single call edge", have)
+	}
+	if len(calls.Edges(0, 0)) != 0 {
+		t.Errorf("edges within a frame kept")
+	}
+	if have := calls.Kinds(ContextSourceMeta.Index, 0); len(have) != 1 {
+		t.Errorf("source edge lost")
+	}
+	txs := g.Condense(TxLevel)
+	if v, _ := txs.Vertex(1); txs.NumVertices() != 2+len(sourceVertices) || v.Gas != 2100 {
+		t.Fatalf("have %d tx vertices, tx 1 %+v", txs.NumVertices(), v)
+	}
+	if have := txs.Kinds(0, 1); len(have) != 1 || have[0] != StorageEdge {
+		t.Errorf("have edges %v between the transactions, want a storage edge", have)
+	}
+	if e := txs.Edges(0, 1)[0]; e.Slot != (common.Hash{1}) {
+		t.Errorf("storage slot lost")
+	}
+
+	storage := g.FilterEdges(StorageEdge)
+	if storage.NumVertices() != g.NumVertices() || storage.NumEdges() != 1 || len(storage.Edges(2, 3)) != 1 {
+		t.Errorf("have %d filtered edges", storage.NumEdges())
+	}
+	if _, err := ParseGraphLevel("block"); err == nil {
+		t.Errorf("unknown level accepted")
+	}
+	if kind, err := ParseEdgeKind("transient"); err != nil || kind != TransientStorageEdge {
+		t.Errorf("have kind %v, %v", kind, err)
+	}
+}
+
+func TestTxGraph(t *testing.T) {
+	var (
+		g      = NewDependencyGraph()
+		sstore = Metadata{Index: 0, OpCode: "SSTORE"}
+		sload  = Metadata{TxId: 1, Index: 1, OpCode: "SLOAD"}
+		add    = Metadata{TxId: 1, Index: 2, OpCode: "ADD"}
+	)
+	g.AddEdge(sstore, sload, Edge{Kind: StorageEdge, Slot: common.Hash{1}})
+	g.AddEdge(StorageSourceMeta, sload, Edge{Kind: StorageEdge, Slot: common.Hash{2}})
+	g.AddDependency([]Metadata{sload}, add)
+
+	sub := g.TxGraph(1)
+	if sub.NumVertices() != 2+len(sourceVertices) {
+		t.Fatalf("have %d vertices, want 2 and the sources", sub.NumVertices())
+	}
+	// The write of the earlier transaction is part of the state it starts from
+	if have := sub.Edges(StorageSourceMeta.Index, 1); len(have) != 2 || have[1].Slot != (common.Hash{1}) {
+		t.Errorf("have source edges %v", have)
+	}
+	if have := sub.Kinds(1, 2); len(have) != 1 || have[0] != StackEdge {
+		t.Errorf("have edges %v within the transaction", have)
+	}
+}
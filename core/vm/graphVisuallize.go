@@ -1,9 +1,8 @@
 package vm
 
 import (
-	"encoding/json"
+	"fmt"
 	"io"
-	"os"
 
 	"github.com/go-echarts/go-echarts/v2/charts"
 	"github.com/go-echarts/go-echarts/v2/components"
@@ -12,40 +11,55 @@ import (
 
 var graphPath = "./graph.html"
 
-func getNodesAndLinks(g *DependencyGraph) (nodes []opts.GraphNode, links []opts.GraphLink) {
-	nodes = make([]opts.GraphNode, 0)
-	links = make([]opts.GraphLink, 0)
+// EChartsExporter renders the graph as an HTML page drawing it with echarts.
+type EChartsExporter struct {
+	Layout string      // "force" if empty, "circular" or "none"
+	Label  VertexLabel // DefaultVertexLabel if nil
+}
 
-	for _, vertex := range g.Vertexes {
-		byt, _ := json.Marshal(vertex)
-		// vid := vertex.Index
-		nodes = append(nodes, opts.GraphNode{
-			Name: string(byt),
-		})
+// getNodesAndLinks names the nodes with their labels, which echarts needs to
+// be unique: the index is appended to the labels given to several vertices.
+func (e *EChartsExporter) getNodesAndLinks(g *DependencyGraph) (nodes []opts.GraphNode, links []opts.GraphLink) {
+	label := e.Label
+	if label == nil {
+		label = DefaultVertexLabel
 	}
-
-	for source, targets := range g.Edges {
-		vsource := g.Vertexes[source]
-		vsbyt, _ := json.Marshal(vsource)
-		for target := range targets {
-			vtarget := g.Vertexes[target]
-			vtbyt, _ := json.Marshal(vtarget)
-			links = append(links, opts.GraphLink{
-				Source: string(vsbyt),
-				Target: string(vtbyt),
-			})
+	var (
+		names = make(map[int]string, g.NumVertices())
+		seen  = make(map[string]bool, g.NumVertices())
+	)
+	nodes = make([]opts.GraphNode, 0, g.NumVertices())
+	g.ForEachVertex(func(v Metadata) error {
+		name := label(v)
+		if seen[name] {
+			name = fmt.Sprintf("%s (#%d)", name, v.Index)
 		}
-	}
-
+		seen[name], names[v.Index] = true, name
+		nodes = append(nodes, opts.GraphNode{Name: name})
+		return nil
+	})
+	links = make([]opts.GraphLink, 0)
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		// Several edges between the same vertices are drawn as one
+		if n := len(links); n > 0 && links[n-1].Source == names[source] && links[n-1].Target == names[target] {
+			return nil
+		}
+		links = append(links, opts.GraphLink{Source: names[source], Target: names[target]})
+		return nil
+	})
 	return
 }
 
-func newChart(g *DependencyGraph) *charts.Graph {
+func (e *EChartsExporter) newChart(g *DependencyGraph) *charts.Graph {
+	layout := e.Layout
+	if layout == "" {
+		layout = "force"
+	}
 	graph := charts.NewGraph()
-	nodes, links := getNodesAndLinks(g)
+	nodes, links := e.getNodesAndLinks(g)
 	graph.AddSeries("", nodes, links).SetSeriesOptions(
 		charts.WithGraphChartOpts(opts.GraphChart{
-			Layout:             "force",
+			Layout:             layout,
 			Force:              &opts.GraphForce{Repulsion: 100},
 			Roam:               true,
 			FocusNodeAdjacency: true,
@@ -62,9 +76,13 @@ func newChart(g *DependencyGraph) *charts.Graph {
 	return graph
 }
 
-func VisualizeGraph(g *DependencyGraph) {
+func (e *EChartsExporter) Export(w io.Writer, g *DependencyGraph) error {
 	page := components.NewPage()
-	page.AddCharts(newChart(g))
-	f, _ := os.Create(graphPath)
-	page.Render(io.MultiWriter(f))
+	page.AddCharts(e.newChart(g))
+	return page.Render(w)
+}
+
+// VisualizeGraph renders the graph with echarts to ./graph.html.
+func VisualizeGraph(g *DependencyGraph) error {
+	return ExportGraphFile(graphPath, g, new(EChartsExporter))
 }
@@ -0,0 +1,116 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package incremental
+
+import (
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+// arity is the number of stack operands of the instructions computing their
+// result from the operands alone.
+var arity = map[vm.OpCode]int{
+	vm.ISZERO: 1, vm.NOT: 1,
+	vm.ADD: 2, vm.MUL: 2, vm.SUB: 2, vm.DIV: 2, vm.SDIV: 2, vm.MOD: 2, vm.SMOD: 2,
+	vm.EXP: 2, vm.SIGNEXTEND: 2,
+	vm.LT: 2, vm.GT: 2, vm.SLT: 2, vm.SGT: 2, vm.EQ: 2,
+	vm.AND: 2, vm.OR: 2, vm.XOR: 2, vm.BYTE: 2, vm.SHL: 2, vm.SHR: 2, vm.SAR: 2,
+	vm.ADDMOD: 3, vm.MULMOD: 3,
+}
+
+// eval returns the result of op on the given operands, the top of the stack
+// first, as the instructions in instructions.go compute it.
+func eval(op vm.OpCode, x []*uint256.Int) *uint256.Int {
+	z := new(uint256.Int)
+	switch op {
+	case vm.ISZERO:
+		if x[0].IsZero() {
+			z.SetOne()
+		}
+	case vm.NOT:
+		z.Not(x[0])
+	case vm.ADD:
+		z.Add(x[0], x[1])
+	case vm.MUL:
+		z.Mul(x[0], x[1])
+	case vm.SUB:
+		z.Sub(x[0], x[1])
+	case vm.DIV:
+		z.Div(x[0], x[1])
+	case vm.SDIV:
+		z.SDiv(x[0], x[1])
+	case vm.MOD:
+		z.Mod(x[0], x[1])
+	case vm.SMOD:
+		z.SMod(x[0], x[1])
+	case vm.EXP:
+		z.Exp(x[0], x[1])
+	case vm.SIGNEXTEND:
+		z.ExtendSign(x[1], x[0])
+	case vm.LT:
+		if x[0].Lt(x[1]) {
+			z.SetOne()
+		}
+	case vm.GT:
+		if x[0].Gt(x[1]) {
+			z.SetOne()
+		}
+	case vm.SLT:
+		if x[0].Slt(x[1]) {
+			z.SetOne()
+		}
+	case vm.SGT:
+		if x[0].Sgt(x[1]) {
+			z.SetOne()
+		}
+	case vm.EQ:
+		if x[0].Eq(x[1]) {
+			z.SetOne()
+		}
+	case vm.AND:
+		z.And(x[0], x[1])
+	case vm.OR:
+		z.Or(x[0], x[1])
+	case vm.XOR:
+		z.Xor(x[0], x[1])
+	case vm.BYTE:
+		z.Set(x[1]).Byte(x[0])
+	case vm.SHL:
+		if x[0].LtUint64(256) {
+			z.Lsh(x[1], uint(x[0].Uint64()))
+		}
+	case vm.SHR:
+		if x[0].LtUint64(256) {
+			z.Rsh(x[1], uint(x[0].Uint64()))
+		}
+	case vm.SAR:
+		if x[0].GtUint64(256) {
+			if x[1].Sign() < 0 {
+				z.SetAllOne()
+			}
+		} else {
+			z.SRsh(x[1], uint(x[0].Uint64()))
+		}
+	case vm.ADDMOD:
+		if !x[2].IsZero() {
+			z.AddMod(x[0], x[1], x[2])
+		}
+	case vm.MULMOD:
+		z.MulMod(x[0], x[1], x[2])
+	}
+	return z
+}
@@ -0,0 +1,637 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package incremental re-executes a transaction from its dependency graph
+// after some of its inputs changed: the value of a storage slot before the
+// transaction, a word of the call data or a field of the block. Only the
+// vertices downstream of the changes are evaluated again, from the values the
+// graph recorded for the others, and the propagation stops wherever a value
+// comes out unchanged.
+//
+// This only holds as long as the same instructions run. The changes cannot be
+// applied incrementally, and the transaction has to be re-executed in full,
+// when they reach
+//
+//   - a control-flow decision: the condition or destination of a jump,
+//   - a call or create, which might succeed differently,
+//   - a location: the offset of a memory access or the slot of a storage one,
+//   - memory read otherwise than as the 32-byte word of an MSTORE, like by
+//     KECCAK256, RETURN or a call,
+//   - the gas left, read by GAS after a change of the gas used,
+//   - any other instruction than the arithmetic, comparison and bitwise ones,
+//     MLOAD, MSTORE, SLOAD and SSTORE.
+//
+// The graph should be recorded in value-flow mode (vm.Config.DFGValueFlow),
+// where every stack operand is the value of its producer or a constant. In
+// the full graph a SWAP vertex stands for two values, the changes falling
+// back whenever they reach one.
+package incremental
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/holiman/uint256"
+)
+
+type changeKind uint8
+
+const (
+	storageChange  changeKind = iota // the value of a slot before the transaction
+	callDataChange                   // a word of the call data of the transaction
+	contextChange                    // a field of the block
+)
+
+// Change is a new value for an input of the transaction.
+type Change struct {
+	kind   changeKind
+	addr   common.Address
+	slot   common.Hash
+	offset uint64
+	op     vm.OpCode
+	value  uint256.Int
+}
+
+// StorageChange sets the value of a storage slot before the transaction.
+func StorageChange(addr common.Address, slot common.Hash, value *uint256.Int) Change {
+	return Change{kind: storageChange, addr: addr, slot: slot, value: *value}
+}
+
+// CallDataChange sets the 32 bytes of the call data of the transaction from
+// the given offset on. The call data grows to hold them if it is shorter.
+func CallDataChange(offset uint64, word common.Hash) Change {
+	return Change{kind: callDataChange, offset: offset, value: *new(uint256.Int).SetBytes32(word[:])}
+}
+
+// ContextChange sets the field of the block read by op, which is one of
+// COINBASE, TIMESTAMP, NUMBER, PREVRANDAO, GASLIMIT and CHAINID. The new value
+// is assumed to keep the fork rules of the block.
+func ContextChange(op vm.OpCode, value *uint256.Int) Change {
+	return Change{kind: contextChange, op: op, value: *value}
+}
+
+// contextFields are the block fields a ContextChange can set. The gas price
+// and base fee are left out as they change the balance of the sender too.
+var contextFields = map[vm.OpCode]bool{
+	vm.COINBASE:   true,
+	vm.TIMESTAMP:  true,
+	vm.NUMBER:     true,
+	vm.PREVRANDAO: true,
+	vm.GASLIMIT:   true,
+	vm.CHAINID:    true,
+}
+
+func (c *Change) String() string {
+	switch c.kind {
+	case storageChange:
+		return fmt.Sprintf("storage %x:%x = %v", c.addr, c.slot, c.value.Hex())
+	case callDataChange:
+		return fmt.Sprintf("calldata[%d:%d] = %v", c.offset, c.offset+32, c.value.Hex())
+	}
+	return fmt.Sprintf("%v = %v", c.op, c.value.Hex())
+}
+
+// Fallback tells why changes could not be applied incrementally. It is the
+// vertex the changes reached last.
+type Fallback struct {
+	Index  int            `json:"index"`
+	ID     vm.VertexID    `json:"id"`
+	Addr   common.Address `json:"addr"`
+	Pc     uint64         `json:"pc"`
+	OpCode string         `json:"opcode"`
+	Reason string         `json:"reason"`
+}
+
+func (f *Fallback) Error() string {
+	return fmt.Sprintf("%s at vertex %d (%s, pc %d of %x)", f.Reason, f.Index, f.OpCode, f.Pc, f.Addr)
+}
+
+// StorageWrite is an SSTORE which now writes another value. The SSTOREs of
+// frames which reverted are left out.
+type StorageWrite struct {
+	Index int         `json:"index"` // Vertex of the SSTORE
+	Slot  common.Hash `json:"slot"`
+	Value common.Hash `json:"value"`
+}
+
+// Result is what changed in the execution.
+type Result struct {
+	// Values are the new values pushed by the vertices, for those which
+	// changed.
+	Values map[int]*uint256.Int `json:"values"`
+
+	// Storage lists the storage writes which changed, by vertex.
+	Storage []StorageWrite `json:"storage"`
+
+	// Recomputed is the number of vertices evaluated again.
+	Recomputed int `json:"recomputed"`
+
+	// GasChanged tells that the gas used by the transaction may differ, as
+	// the cost of an SSTORE or EXP, or the intrinsic gas of the call data,
+	// changed. It is not recomputed.
+	GasChanged bool `json:"gasChanged"`
+
+	// Fallback is why the changes could not be applied incrementally, nil
+	// if they were. The other fields are then incomplete.
+	Fallback *Fallback `json:"fallback,omitempty"`
+}
+
+// ErrGraph is returned for graphs which are no graph of a single transaction.
+var ErrGraph = errors.New("graph of several transactions")
+
+// operand is a stack operand of a vertex, produced by a vertex or constant.
+type operand struct {
+	source int // index of the producer, or -1 for a constant
+	value  *uint256.Int
+}
+
+// inEdge is a data edge into a vertex.
+type inEdge struct {
+	source int
+	edge   vm.Edge
+}
+
+type node struct {
+	meta     vm.Metadata
+	op       vm.OpCode
+	operands []operand // by position, 0 being the top of the stack
+	in       []inEdge  // data edges other than stack ones
+	out      []int     // targets of the data edges, in increasing order
+}
+
+type slotKey struct {
+	addr common.Address
+	slot common.Hash
+}
+
+// Engine applies changes to the recorded execution of a transaction. It
+// indexes the graph once, for any number of change sets.
+type Engine struct {
+	nodes []*node // by index from the first source on, absent ones being nil
+	first int
+
+	reads map[slotKey][]int     // SLOADs of the values before the transaction
+	byOp  map[vm.OpCode][]int   // vertices of the instructions reading inputs, in increasing order
+	slots map[common.Hash][]int // SSTOREs by slot written
+}
+
+// New returns an engine re-executing the transaction of the given graph,
+// see vm.DependencyGraph.TxGraph.
+func New(g *vm.DependencyGraph) (*Engine, error) {
+	e := &Engine{
+		first: vm.ContextSourceMeta.Index,
+		reads: make(map[slotKey][]int),
+		byOp:  make(map[vm.OpCode][]int),
+		slots: make(map[common.Hash][]int),
+	}
+	tx := -1
+	err := g.ForEachVertex(func(v vm.Metadata) error {
+		for len(e.nodes) <= v.Index-e.first {
+			e.nodes = append(e.nodes, nil)
+		}
+		n := &node{meta: v, op: vm.StringToOp(v.OpCode)}
+		if v.IsSource() {
+			n.op = vm.INVALID
+		} else if tx != v.TxId && tx >= 0 {
+			return ErrGraph
+		} else {
+			tx = v.TxId
+		}
+		for _, c := range g.Constants(v.Index) {
+			n.setOperand(c.Operand, operand{source: -1, value: c.Value})
+		}
+		switch n.op {
+		case vm.CALLDATALOAD, vm.CALLDATASIZE, vm.GAS, vm.SSTORE:
+			e.byOp[n.op] = append(e.byOp[n.op], v.Index)
+		default:
+			if contextFields[n.op] {
+				e.byOp[n.op] = append(e.byOp[n.op], v.Index)
+			}
+		}
+		e.nodes[v.Index-e.first] = n
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.ForEachEdge(func(source, target int, edge vm.Edge) error {
+		s, t := e.node(source), e.node(target)
+		if s == nil || t == nil {
+			return nil
+		}
+		switch edge.Kind {
+		case vm.ControlEdge, vm.ContextEdge:
+			// Decisions are checked where they are made, and the context
+			// fields are read by their opcodes
+			return nil
+		case vm.CallEdge:
+			if edge.Size == 0 {
+				// The frame source, or what the constants depend on
+				return nil
+			}
+		case vm.StackEdge:
+			t.setOperand(edge.Operand, operand{source: source, value: s.meta.Value})
+			s.out = append(s.out, target)
+			return nil
+		case vm.StorageEdge:
+			if source == vm.StorageSourceMeta.Index {
+				key := slotKey{edge.Address, edge.Slot}
+				e.reads[key] = append(e.reads[key], target)
+			}
+		}
+		t.in = append(t.in, inEdge{source, edge})
+		s.out = append(s.out, target)
+		return nil
+	})
+	for _, n := range e.nodes {
+		if n != nil && n.op == vm.SSTORE {
+			if slot, ok := n.operandValue(0); ok {
+				key := common.Hash(slot.Bytes32())
+				e.slots[key] = append(e.slots[key], n.meta.Index)
+			}
+		}
+	}
+	return e, nil
+}
+
+func (e *Engine) node(index int) *node {
+	if index-e.first < 0 || index-e.first >= len(e.nodes) {
+		return nil
+	}
+	return e.nodes[index-e.first]
+}
+
+func (n *node) setOperand(i int, op operand) {
+	for len(n.operands) <= i {
+		n.operands = append(n.operands, operand{source: -1})
+	}
+	n.operands[i] = op
+}
+
+// operandValue returns the recorded value of the operand.
+func (n *node) operandValue(i int) (*uint256.Int, bool) {
+	if i >= len(n.operands) || n.operands[i].value == nil {
+		return nil, false
+	}
+	return n.operands[i].value, true
+}
+
+// run is the application of a change set.
+type run struct {
+	*Engine
+	changes []Change
+	result  *Result
+
+	// values are the new values of the vertices reached, or the new words
+	// written by the MSTOREs and SSTOREs
+	values  map[int]*uint256.Int
+	pending indexHeap
+	queued  map[int]bool
+	gasFrom int // first vertex the gas left may differ at, if the gas changed
+}
+
+// Apply re-executes the transaction with the given changes. Unless the result
+// tells a fallback, it lists everything the changes altered.
+func (e *Engine) Apply(changes ...Change) (*Result, error) {
+	r := &run{
+		Engine:  e,
+		changes: changes,
+		result:  &Result{Values: make(map[int]*uint256.Int), Storage: make([]StorageWrite, 0)},
+		values:  make(map[int]*uint256.Int),
+		queued:  make(map[int]bool),
+	}
+	for _, c := range changes {
+		switch c.kind {
+		case storageChange:
+			r.queue(e.reads[slotKey{c.addr, c.slot}]...)
+			if len(e.slots[c.slot]) > 0 {
+				// The cost of an SSTORE depends on the original value
+				r.gasChanged(e.slots[c.slot][0])
+			}
+		case callDataChange:
+			r.queue(e.byOp[vm.CALLDATALOAD]...)
+			r.queue(e.byOp[vm.CALLDATASIZE]...)
+			r.gasChanged(e.first) // The intrinsic gas of the call data
+		case contextChange:
+			if !contextFields[c.op] {
+				return nil, fmt.Errorf("unsupported change of %v", c.op)
+			}
+			r.queue(e.byOp[c.op]...)
+		}
+	}
+	for r.pending.Len() > 0 {
+		index := heap.Pop(&r.pending).(int)
+		if fallback := r.visit(e.node(index)); fallback != "" {
+			n := e.node(index)
+			r.result.Fallback = &Fallback{
+				Index:  index,
+				ID:     n.meta.ID,
+				Addr:   n.meta.Addr,
+				Pc:     n.meta.Pc,
+				OpCode: n.meta.OpCode,
+				Reason: fallback,
+			}
+			break
+		}
+	}
+	sort.Slice(r.result.Storage, func(i, j int) bool {
+		return r.result.Storage[i].Index < r.result.Storage[j].Index
+	})
+	return r.result, nil
+}
+
+// Reexecute applies the changes, and calls full to re-execute the transaction
+// when they cannot be applied incrementally. The result tells why then.
+func (e *Engine) Reexecute(full func() error, changes ...Change) (*Result, error) {
+	result, err := e.Apply(changes...)
+	if err != nil {
+		return nil, err
+	}
+	if result.Fallback != nil {
+		return result, full()
+	}
+	return result, nil
+}
+
+func (r *run) queue(indexes ...int) {
+	for _, i := range indexes {
+		if !r.queued[i] {
+			r.queued[i] = true
+			heap.Push(&r.pending, i)
+		}
+	}
+}
+
+// gasChanged records that the gas left may differ from the given vertex on,
+// which the GAS instructions after it read.
+func (r *run) gasChanged(from int) {
+	if r.result.GasChanged && r.gasFrom <= from {
+		return
+	}
+	r.result.GasChanged, r.gasFrom = true, from
+	for _, i := range r.byOp[vm.GAS] {
+		if i > from {
+			r.queue(i)
+		}
+	}
+}
+
+// operand returns the current value of an operand of n.
+func (r *run) operand(n *node, i int) (*uint256.Int, bool) {
+	if i >= len(n.operands) {
+		return nil, false
+	}
+	op := n.operands[i]
+	if v, ok := r.values[op.source]; ok && op.source >= 0 {
+		return v, true
+	}
+	return op.value, op.value != nil
+}
+
+// changed returns whether an operand of n has a new value.
+func (r *run) changed(n *node, i int) bool {
+	if i >= len(n.operands) || n.operands[i].source < 0 {
+		return false
+	}
+	_, ok := r.values[n.operands[i].source]
+	return ok
+}
+
+// visit evaluates a vertex again, returning why not if it can't.
+func (r *run) visit(n *node) string {
+	r.result.Recomputed++
+	var (
+		value *uint256.Int
+		old   = n.meta.Value
+	)
+	// Values of the data edges other than the stack ones
+	for _, in := range n.in {
+		if _, ok := r.values[in.source]; !ok {
+			continue
+		}
+		switch {
+		case in.edge.Kind == vm.MemoryEdge && n.op == vm.MLOAD:
+		case in.edge.Kind == vm.StorageEdge && n.op == vm.SLOAD:
+		default:
+			return fmt.Sprintf("%v reads changed %v", n.op, in.edge.Kind)
+		}
+	}
+	switch n.op {
+	case vm.JUMP:
+		if r.changed(n, 0) {
+			return "jump destination changed"
+		}
+		return ""
+
+	case vm.JUMPI:
+		pos, _ := r.operand(n, 0)
+		cond, ok := r.operand(n, 1)
+		oldCond, _ := n.operandValue(1)
+		if !ok || oldCond == nil || cond.IsZero() != oldCond.IsZero() {
+			return "jump condition changed"
+		}
+		if oldPos, _ := n.operandValue(0); !cond.IsZero() && (pos == nil || oldPos == nil || !pos.Eq(oldPos)) {
+			return "jump destination changed"
+		}
+		return ""
+
+	case vm.GAS:
+		if r.result.GasChanged && n.meta.Index > r.gasFrom {
+			return "gas left changed"
+		}
+		return ""
+
+	case vm.SLOAD:
+		if r.changed(n, 0) {
+			return "storage slot read changed"
+		}
+		value = old
+		for _, in := range n.in {
+			if in.edge.Kind != vm.StorageEdge {
+				continue
+			}
+			if v, ok := r.values[in.source]; ok {
+				value = v
+			} else if in.source == vm.StorageSourceMeta.Index {
+				for i := range r.changes {
+					if c := &r.changes[i]; c.kind == storageChange && c.addr == in.edge.Address && c.slot == in.edge.Slot {
+						value = &c.value
+					}
+				}
+			}
+		}
+
+	case vm.SSTORE:
+		if r.changed(n, 0) {
+			return "storage slot written changed"
+		}
+		slot, _ := r.operand(n, 0)
+		word, ok := r.operand(n, 1)
+		oldWord, _ := n.operandValue(1)
+		if !ok || oldWord == nil {
+			return "SSTORE value unknown"
+		}
+		if word.Eq(oldWord) {
+			return ""
+		}
+		r.values[n.meta.Index] = word
+		r.gasChanged(n.meta.Index)
+		if !n.meta.Reverted {
+			r.result.Storage = append(r.result.Storage, StorageWrite{Index: n.meta.Index, Slot: slot.Bytes32(), Value: word.Bytes32()})
+		}
+		r.queue(n.out...)
+		return ""
+
+	case vm.MSTORE:
+		if r.changed(n, 0) {
+			return "memory offset written changed"
+		}
+		word, ok := r.operand(n, 1)
+		oldWord, _ := n.operandValue(1)
+		if !ok || oldWord == nil {
+			return "MSTORE value unknown"
+		}
+		if !word.Eq(oldWord) {
+			r.values[n.meta.Index] = word
+			r.queue(n.out...)
+		}
+		return ""
+
+	case vm.MLOAD:
+		if r.changed(n, 0) {
+			return "memory offset read changed"
+		}
+		offset, _ := r.operand(n, 0)
+		value = old
+		for _, in := range n.in {
+			v, ok := r.values[in.source]
+			if !ok {
+				continue
+			}
+			// Only the word of an MSTORE at the same offset is known
+			s := r.node(in.source)
+			at, _ := s.operandValue(0)
+			if s.op != vm.MSTORE || at == nil || offset == nil || !at.Eq(offset) || in.edge.Size != 32 {
+				return "MLOAD reads part of a changed word"
+			}
+			value = v
+		}
+
+	case vm.CALLDATALOAD:
+		if r.changed(n, 0) {
+			return "call data offset read changed"
+		}
+		if n.meta.Depth > 1 || old == nil {
+			return ""
+		}
+		offset, ok := r.operand(n, 0)
+		if !ok {
+			return "call data offset unknown"
+		}
+		if !offset.IsUint64() {
+			return ""
+		}
+		from := offset.Uint64()
+		word := old.Bytes32()
+		for _, c := range r.changes {
+			if c.kind != callDataChange {
+				continue
+			}
+			patch := c.value.Bytes32()
+			for i := uint64(0); i < 32; i++ {
+				if p := from + i; p >= c.offset && p-c.offset < 32 {
+					word[i] = patch[p-c.offset]
+				}
+			}
+		}
+		value = new(uint256.Int).SetBytes32(word[:])
+
+	case vm.CALLDATASIZE:
+		if n.meta.Depth > 1 || old == nil {
+			return ""
+		}
+		value = old
+		for _, c := range r.changes {
+			if c.kind == callDataChange && c.offset+32 > value.Uint64() {
+				value = new(uint256.Int).SetUint64(c.offset + 32)
+			}
+		}
+
+	case vm.POP:
+		return ""
+
+	default:
+		if contextFields[n.op] && len(n.operands) == 0 {
+			value = old
+			for i := range r.changes {
+				if c := &r.changes[i]; c.kind == contextChange && c.op == n.op {
+					value = &c.value
+				}
+			}
+			break
+		}
+		if n.op >= vm.DUP1 && n.op <= vm.DUP16 {
+			v, ok := r.operand(n, int(n.op-vm.DUP1))
+			if !ok {
+				return "DUP value unknown"
+			}
+			value = v
+			break
+		}
+		args, ok := arity[n.op]
+		if !ok {
+			return fmt.Sprintf("%s cannot be recomputed", n.meta.OpCode)
+		}
+		x := make([]*uint256.Int, args)
+		for i := range x {
+			if x[i], ok = r.operand(n, i); !ok {
+				return fmt.Sprintf("operand %d of %v unknown", i, n.op)
+			}
+		}
+		value = eval(n.op, x)
+		if n.op == vm.EXP {
+			// The cost of EXP grows with the bytes of the exponent
+			if oldExp, _ := n.operandValue(1); oldExp == nil || oldExp.ByteLen() != x[1].ByteLen() {
+				r.gasChanged(n.meta.Index)
+			}
+		}
+	}
+	if value == nil || (old != nil && value.Eq(old)) {
+		return ""
+	}
+	r.values[n.meta.Index] = value
+	r.result.Values[n.meta.Index] = value
+	r.queue(n.out...)
+	return ""
+}
+
+// indexHeap pops the vertices in execution order, after all their sources.
+type indexHeap []int
+
+func (h indexHeap) Len() int           { return len(h) }
+func (h indexHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h indexHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *indexHeap) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *indexHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
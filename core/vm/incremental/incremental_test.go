@@ -0,0 +1,209 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package incremental
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/holiman/uint256"
+)
+
+var contract = common.HexToAddress("0x000000000000000000000000000000000000c0de")
+
+// code adds the call data word to slot 0 and stores the sum in slot 1, then
+// passes it through memory and adds the timestamp, stored in slot 2. Unless
+// the sum is below 100, it also stores 7 in slot 3.
+var code = []byte{
+	byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.ADD),
+	byte(vm.DUP1), byte(vm.PUSH1), 0x01, byte(vm.SSTORE), // sstore(1, sum)
+	byte(vm.DUP1), byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, sum)
+	byte(vm.PUSH1), 0x00, byte(vm.MLOAD), byte(vm.TIMESTAMP), byte(vm.ADD),
+	byte(vm.PUSH1), 0x02, byte(vm.SSTORE), // sstore(2, mload(0) + timestamp)
+	byte(vm.PUSH1), 0x64, byte(vm.GT), byte(vm.PUSH1), 0x23, byte(vm.JUMPI),
+	byte(vm.PUSH1), 0x07, byte(vm.PUSH1), 0x03, byte(vm.SSTORE), byte(vm.STOP), // sstore(3, 7)
+	byte(vm.JUMPDEST), byte(vm.STOP),
+}
+
+// graphLogger keeps the EVM of the call, to read its graph back.
+type graphLogger struct {
+	*logger.StructLogger
+	env *vm.EVM
+}
+
+func (l *graphLogger) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	l.env = env
+	l.StructLogger.CaptureStart(env, from, to, create, input, gas, value)
+}
+
+type inputs struct {
+	input     uint64
+	slot      uint64
+	timestamp uint64
+}
+
+// record runs the code and returns its graph, along with the storage it
+// leaves.
+func record(t *testing.T, in inputs) (*vm.DependencyGraph, *state.StateDB) {
+	t.Helper()
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	tracer := &graphLogger{StructLogger: logger.NewStructLogger(nil)}
+	cfg := &runtime.Config{State: statedb, Time: in.timestamp}
+	cfg.EVMConfig.Tracer = tracer
+	cfg.EVMConfig.EnableDFG = true
+	cfg.EVMConfig.DFGValueFlow = true
+	statedb.CreateAccount(contract)
+	statedb.SetCode(contract, code)
+	statedb.SetState(contract, common.Hash{}, common.BigToHash(new(big.Int).SetUint64(in.slot)))
+
+	input := common.BigToHash(new(big.Int).SetUint64(in.input))
+	if _, _, err := runtime.Call(contract, input[:], cfg); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	return tracer.env.Graph, statedb
+}
+
+// changedValues returns the values of the vertices of have which differ in
+// want.
+func changedValues(have, want *vm.DependencyGraph) map[int]*uint256.Int {
+	changed := make(map[int]*uint256.Int)
+	want.ForEachVertex(func(v vm.Metadata) error {
+		if old, _ := have.Vertex(v.Index); v.Value != nil && !v.Value.Eq(old.Value) {
+			changed[v.Index] = v.Value
+		}
+		return nil
+	})
+	return changed
+}
+
+func TestApply(t *testing.T) {
+	base := inputs{input: 10, slot: 5, timestamp: 1000}
+	graph, _ := record(t, base)
+	engine, err := New(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name       string
+		changes    []Change
+		inputs     inputs
+		recomputed int
+		gasChanged bool
+	}{
+		{
+			name:       "calldata",
+			changes:    []Change{CallDataChange(0, common.BigToHash(big.NewInt(20)))},
+			inputs:     inputs{input: 20, slot: 5, timestamp: 1000},
+			recomputed: 8, // CALLDATALOAD, ADD, SSTORE, MSTORE, MLOAD, ADD, SSTORE, GT
+			gasChanged: true,
+		},
+		{
+			name:       "storage",
+			changes:    []Change{StorageChange(contract, common.Hash{}, uint256.NewInt(50))},
+			inputs:     inputs{input: 10, slot: 50, timestamp: 1000},
+			recomputed: 8,
+			gasChanged: true, // The SSTOREs write other values
+		},
+		{
+			name:       "timestamp",
+			changes:    []Change{ContextChange(vm.TIMESTAMP, uint256.NewInt(2000))},
+			inputs:     inputs{input: 10, slot: 5, timestamp: 2000},
+			recomputed: 3, // TIMESTAMP, ADD, SSTORE
+			gasChanged: true,
+		},
+		{
+			name:       "unchanged",
+			changes:    []Change{StorageChange(contract, common.Hash{}, uint256.NewInt(5))},
+			inputs:     base,
+			recomputed: 1,
+		},
+	} {
+		result, err := engine.Apply(test.changes...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if result.Fallback != nil {
+			t.Fatalf("%s: unexpected fallback: %v", test.name, result.Fallback)
+		}
+		want, statedb := record(t, test.inputs)
+		changed := changedValues(graph, want)
+		if len(result.Values) != len(changed) {
+			t.Errorf("%s: %d values changed, want %d", test.name, len(result.Values), len(changed))
+		}
+		for index, value := range changed {
+			if have := result.Values[index]; have == nil || !have.Eq(value) {
+				t.Errorf("%s: vertex %d value %v, want %v", test.name, index, have, value)
+			}
+		}
+		for _, w := range result.Storage {
+			if have := statedb.GetState(contract, w.Slot); have != w.Value {
+				t.Errorf("%s: slot %x written %x, want %x", test.name, w.Slot, w.Value, have)
+			}
+		}
+		if result.Recomputed != test.recomputed {
+			t.Errorf("%s: %d vertices recomputed, want %d", test.name, result.Recomputed, test.recomputed)
+		}
+		if result.GasChanged != test.gasChanged {
+			t.Errorf("%s: gas changed %v, want %v", test.name, result.GasChanged, test.gasChanged)
+		}
+	}
+}
+
+func TestApplyFallback(t *testing.T) {
+	graph, _ := record(t, inputs{input: 10, slot: 5, timestamp: 1000})
+	engine, err := New(graph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A sum of 200 no longer jumps over the last SSTORE
+	change := StorageChange(contract, common.Hash{}, uint256.NewInt(200))
+	result, err := engine.Apply(change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Fallback == nil {
+		t.Fatal("no fallback")
+	}
+	if have := result.Fallback; have.OpCode != vm.JUMPI.String() || have.Pc != 28 || have.Addr != contract {
+		t.Errorf("fallback at %s pc %d of %x, want JUMPI pc 28", have.OpCode, have.Pc, have.Addr)
+	}
+
+	var ran bool
+	errFull := errors.New("full")
+	full := func() error {
+		ran = true
+		return errFull
+	}
+	if _, err := engine.Reexecute(full, change); !errors.Is(err, errFull) || !ran {
+		t.Errorf("full re-execution not run on fallback: %v", err)
+	}
+	ran = false
+	if _, err := engine.Reexecute(full, ContextChange(vm.TIMESTAMP, uint256.NewInt(2000))); err != nil || ran {
+		t.Errorf("full re-execution run without fallback: %v", err)
+	}
+	if _, err := engine.Apply(ContextChange(vm.BALANCE, uint256.NewInt(1))); err == nil {
+		t.Error("change of BALANCE accepted")
+	}
+}
@@ -17,8 +17,6 @@
 package vm
 
 import (
-	"fmt"
-
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -27,338 +25,162 @@ import (
 )
 
 func opAdd(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, ADD)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Add(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opSub(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SUB)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Sub(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opMul(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MUL)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Mul(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opDiv(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, DIV)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Div(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opSdiv(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SDIV)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.SDiv(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opMod(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MOD)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Mod(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opSmod(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaY := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SMOD)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.SMod(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaY)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaY)
 	return nil, nil
 }
 
 func opExp(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, EXP)
-
 	base, exponent := scope.Stack.pop(), scope.Stack.peek()
 	exponent.Exp(&base, exponent)
-
-	metaBase := scope.metaStack.pop()
-	metaExponent := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaBase, metaExponent}, *newMetaRes)
 	return nil, nil
 }
 
 func opSignExtend(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SIGNEXTEND)
-
 	back, num := scope.Stack.pop(), scope.Stack.peek()
 	num.ExtendSign(num, &back)
-
-	metaBack := scope.metaStack.pop()
-	metaNum := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaBack, metaNum}, *newMetaRes)
 	return nil, nil
 }
 
 func opNot(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, NOT)
-
 	x := scope.Stack.peek()
 	x.Not(x)
-
-	metaX := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX}, *newMetaRes)
 	return nil, nil
 }
 
 func opLt(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, LT)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	if x.Lt(y) {
 		y.SetOne()
 	} else {
 		y.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opGt(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, GT)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	if x.Gt(y) {
 		y.SetOne()
 	} else {
 		y.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opSlt(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SLT)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	if x.Slt(y) {
 		y.SetOne()
 	} else {
 		y.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opSgt(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SGT)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	if x.Sgt(y) {
 		y.SetOne()
 	} else {
 		y.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opEq(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, EQ)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	if x.Eq(y) {
 		y.SetOne()
 	} else {
 		y.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opIszero(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, ISZERO)
-
 	x := scope.Stack.peek()
 	if x.IsZero() {
 		x.SetOne()
 	} else {
 		x.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX}, *newMetaRes)
 	return nil, nil
 }
 
 func opAnd(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, AND)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.And(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opOr(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, OR)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Or(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opXor(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, XOR)
-
 	x, y := scope.Stack.pop(), scope.Stack.peek()
 	y.Xor(&x, y)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY}, *newMetaRes)
 	return nil, nil
 }
 
 func opByte(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, BYTE)
-
 	th, val := scope.Stack.pop(), scope.Stack.peek()
 	val.Byte(&th)
-
-	metaTh := scope.metaStack.pop()
-	metaVal := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaTh, metaVal}, *newMetaRes)
 	return nil, nil
 }
 
 func opAddmod(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, ADDMOD)
-
 	x, y, z := scope.Stack.pop(), scope.Stack.pop(), scope.Stack.peek()
 	if z.IsZero() {
 		z.Clear()
 	} else {
 		z.AddMod(&x, &y, z)
 	}
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	metaZ := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY, metaZ}, *newMetaRes)
 	return nil, nil
 }
 
 func opMulmod(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MULMOD)
-
 	x, y, z := scope.Stack.pop(), scope.Stack.pop(), scope.Stack.peek()
 	z.MulMod(&x, &y, z)
-
-	metaX := scope.metaStack.pop()
-	metaY := scope.metaStack.pop()
-	metaZ := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, metaY, metaZ}, *newMetaRes)
 	return nil, nil
 }
 
@@ -367,20 +189,12 @@ func opMulmod(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 // and pushes on the stack arg2 shifted to the left by arg1 number of bits.
 func opSHL(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
 	// Note, second operand is left in the stack; accumulate result into it, and no need to push it afterwards
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SHL)
-
 	shift, value := scope.Stack.pop(), scope.Stack.peek()
 	if shift.LtUint64(256) {
 		value.Lsh(value, uint(shift.Uint64()))
 	} else {
 		value.Clear()
 	}
-
-	metaShift := scope.metaStack.pop()
-	metaValue := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaShift, metaValue}, *newMetaRes)
 	return nil, nil
 }
 
@@ -389,20 +203,12 @@ func opSHL(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte
 // and pushes on the stack arg2 shifted to the right by arg1 number of bits with zero fill.
 func opSHR(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
 	// Note, second operand is left in the stack; accumulate result into it, and no need to push it afterwards
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SHR)
-
 	shift, value := scope.Stack.pop(), scope.Stack.peek()
 	if shift.LtUint64(256) {
 		value.Rsh(value, uint(shift.Uint64()))
 	} else {
 		value.Clear()
 	}
-
-	metaShift := scope.metaStack.pop()
-	metaValue := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaShift, metaValue}, *newMetaRes)
 	return nil, nil
 }
 
@@ -410,8 +216,6 @@ func opSHR(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte
 // The SAR instruction (arithmetic shift right) pops 2 values from the stack, first arg1 and then arg2,
 // and pushes on the stack arg2 shifted to the right by arg1 number of bits with sign extension.
 func opSAR(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SAR)
-
 	shift, value := scope.Stack.pop(), scope.Stack.peek()
 	if shift.GtUint64(256) {
 		if value.Sign() >= 0 {
@@ -420,28 +224,14 @@ func opSAR(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte
 			// Max negative shift: all bits set
 			value.SetAllOne()
 		}
-
-		metaShift := scope.metaStack.pop()
-		metaValue := scope.metaStack.pop()
-		scope.metaStack.push(newMetaRes)
-
-		interpreter.evm.Graph.AddDependency([]Metadata{metaShift, metaValue}, *newMetaRes)
 		return nil, nil
 	}
 	n := uint(shift.Uint64())
 	value.SRsh(value, n)
-
-	metaShift := scope.metaStack.pop()
-	metaValue := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaShift, metaValue}, *newMetaRes)
 	return nil, nil
 }
 
 func opKeccak256(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, KECCAK256)
-
 	offset, size := scope.Stack.pop(), scope.Stack.peek()
 	data := scope.Memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
 
@@ -452,127 +242,60 @@ func opKeccak256(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) (
 	}
 	interpreter.hasher.Write(data)
 	interpreter.hasher.Read(interpreter.hasherBuf[:])
+
 	evm := interpreter.evm
 	if evm.Config.EnablePreimageRecording {
 		evm.StateDB.AddPreimage(interpreter.hasherBuf, data)
 	}
 	size.SetBytes(interpreter.hasherBuf[:])
-
-	metaOffset := scope.metaStack.pop()
-	metaSize := scope.metaStack.pop()
-	metaData := scope.metaMemory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaOffset, metaSize, metaData}, *newMetaRes)
 	return nil, nil
 }
 
-func opOrigin(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, ORIGIN)
-
-	scope.Stack.push(new(uint256.Int).SetBytes(interpreter.evm.Origin.Bytes()))
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
+func opAddress(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.push(new(uint256.Int).SetBytes(scope.Contract.Address().Bytes()))
 	return nil, nil
 }
 
 func opBalance(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, BALANCE)
-
 	slot := scope.Stack.peek()
 	address := common.Address(slot.Bytes20())
-	balance := interpreter.evm.StateDB.GetBalance(address)
-	slot.SetFromBig(balance)
-
-	metaSlot := scope.metaStack.pop()
-	metaBalance := scope.metaBalance.Get(address)
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaSlot, metaBalance}, *newMetaRes)
+	slot.SetFromBig(interpreter.evm.StateDB.GetBalance(address))
 	return nil, nil
 }
 
-// 只要从scope.Contract里面拿数据，都要对sourceIndex产生依赖
-func opAddress(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, ADDRESS)
-
-	scope.Stack.push(new(uint256.Int).SetBytes(scope.Contract.Address().Bytes()))
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
+func opOrigin(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.push(new(uint256.Int).SetBytes(interpreter.evm.Origin.Bytes()))
 	return nil, nil
 }
 
-// 只要从scope.Contract里面拿数据，都要对sourceIndex产生依赖
 func opCaller(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLER)
-
 	scope.Stack.push(new(uint256.Int).SetBytes(scope.Contract.Caller().Bytes()))
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, nil
 }
 
-// 只要从scope.Contract里面拿数据，都要对sourceIndex产生依赖
 func opCallValue(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLVALUE)
-
 	v, _ := uint256.FromBig(scope.Contract.value)
 	scope.Stack.push(v)
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, nil
 }
 
-// 只要从scope.Contract里面拿数据，都要对sourceIndex产生依赖
 func opCallDataLoad(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLDATALOAD)
-
-	// 从栈顶拿一个64位数据，然后作为offset去取数据，数据长度32字节
 	x := scope.Stack.peek()
-	// 这个get data可能是一个opcode产生的，因为有合约调合约的存在
 	if offset, overflow := x.Uint64WithOverflow(); !overflow {
 		data := getData(scope.Contract.Input, offset, 32)
 		x.SetBytes(data)
 	} else {
-		// 否则相当于压进去一个0
 		x.Clear()
 	}
-
-	metaX := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{metaX, source}, *newMetaRes)
 	return nil, nil
 }
 
-// 只要从scope.Contract里面拿数据，都要对sourceIndex产生依赖
 func opCallDataSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLDATASIZE)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(uint64(len(scope.Contract.Input))))
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, nil
 }
 
 func opCallDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLDATACOPY)
-
 	var (
 		memOffset  = scope.Stack.pop()
 		dataOffset = scope.Stack.pop()
@@ -587,32 +310,15 @@ func opCallDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	length64 := length.Uint64()
 	scope.Memory.Set(memOffset64, length64, getData(scope.Contract.Input, dataOffset64, length64))
 
-	metaMemOffset := scope.metaStack.pop()
-	metaDataOffset := scope.metaStack.pop()
-	metaLength := scope.metaStack.pop()
-	scope.metaMemory.Set(memOffset64, length64, *newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{metaMemOffset, metaDataOffset, metaLength, source}, *newMetaRes)
 	return nil, nil
 }
 
-// 从interpreter.returnData里拿数据，也需要依赖它的SourceIndex
 func opReturnDataSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, RETURNDATASIZE)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(uint64(len(interpreter.returnData))))
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[interpreter.sourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, nil
 }
 
 func opReturnDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLDATACOPY)
-
 	var (
 		memOffset  = scope.Stack.pop()
 		dataOffset = scope.Stack.pop()
@@ -631,51 +337,23 @@ func opReturnDataCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeConte
 		return nil, ErrReturnDataOutOfBounds
 	}
 	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), interpreter.returnData[offset64:end64])
-
-	metaMemOffset := scope.metaStack.pop()
-	metaDataOffset := scope.metaStack.pop()
-	metaLength := scope.metaStack.pop()
-	scope.metaMemory.Set(memOffset.Uint64(), length.Uint64(), *newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[interpreter.sourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{metaMemOffset, metaDataOffset, metaLength, source}, *newMetaRes)
 	return nil, nil
 }
 
 func opExtCodeSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, EXTCODESIZE)
-
 	slot := scope.Stack.peek()
-	codeSize := uint64(interpreter.evm.StateDB.GetCodeSize(slot.Bytes20()))
-	slot.SetUint64(codeSize)
-
-	metaSlot := scope.metaStack.pop()
-	metaCode := scope.metaCode.Get(slot.Bytes20())
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaSlot, metaCode}, *newMetaRes)
+	slot.SetUint64(uint64(interpreter.evm.StateDB.GetCodeSize(slot.Bytes20())))
 	return nil, nil
 }
 
-// 从scope contract拿数据了
 func opCodeSize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CODESIZE)
-
 	l := new(uint256.Int)
 	l.SetUint64(uint64(len(scope.Contract.Code)))
 	scope.Stack.push(l)
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, nil
 }
 
-// 从scope contract拿数据了
 func opCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CODECOPY)
-
 	var (
 		memOffset  = scope.Stack.pop()
 		codeOffset = scope.Stack.pop()
@@ -688,20 +366,10 @@ func opCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 	codeCopy := getData(scope.Contract.Code, uint64CodeOffset, length.Uint64())
 	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
 
-	metaMemOffset := scope.metaStack.pop()
-	metaDataOffset := scope.metaStack.pop()
-	metaLength := scope.metaStack.pop()
-	scope.metaMemory.Set(memOffset.Uint64(), length.Uint64(), *newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{metaMemOffset, metaDataOffset, metaLength, source}, *newMetaRes)
 	return nil, nil
 }
 
-// 从statedb拿数据了
 func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, EXTCODECOPY)
-
 	var (
 		stack      = scope.Stack
 		a          = stack.pop()
@@ -714,19 +382,9 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 		uint64CodeOffset = 0xffffffffffffffff
 	}
 	addr := common.Address(a.Bytes20())
-	code := interpreter.evm.StateDB.GetCode(addr)
-	codeCopy := getData(code, uint64CodeOffset, length.Uint64())
+	codeCopy := getData(interpreter.evm.StateDB.GetCode(addr), uint64CodeOffset, length.Uint64())
 	scope.Memory.Set(memOffset.Uint64(), length.Uint64(), codeCopy)
 
-	metaA := scope.metaStack.pop()
-	metaMemOffset := scope.metaStack.pop()
-	metaDataOffset := scope.metaStack.pop()
-	metaLength := scope.metaStack.pop()
-	metaCode := scope.metaCode.Get(addr)
-	scope.metaMemory.Set(memOffset.Uint64(), length.Uint64(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaA, metaMemOffset, metaDataOffset, metaLength, metaCode}, *newMetaRes)
-
 	return nil, nil
 }
 
@@ -756,10 +414,7 @@ func opExtCodeCopy(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 //
 //  6. Caller tries to get the code hash for an account which is marked as deleted, this
 //     account should be regarded as a non-existent account and zero should be returned.
-
 func opExtCodeHash(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, EXTCODEHASH)
-
 	slot := scope.Stack.peek()
 	address := common.Address(slot.Bytes20())
 	if interpreter.evm.StateDB.Empty(address) {
@@ -767,32 +422,17 @@ func opExtCodeHash(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	} else {
 		slot.SetBytes(interpreter.evm.StateDB.GetCodeHash(address).Bytes())
 	}
-
-	metaSlot := scope.metaStack.pop()
-	metaCode := scope.metaCode.Get(address)
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaSlot, metaCode}, *newMetaRes)
 	return nil, nil
 }
 
 func opGasprice(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, GASPRICE)
-
 	v, _ := uint256.FromBig(interpreter.evm.GasPrice)
 	scope.Stack.push(v)
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
 func opBlockhash(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, BLOCKHASH)
-
 	num := scope.Stack.peek()
-
 	num64, overflow := num.Uint64WithOverflow()
 	if overflow {
 		num.Clear()
@@ -810,178 +450,86 @@ func opBlockhash(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) (
 	} else {
 		num.Clear()
 	}
-
-	metaNum := scope.metaStack.pop()
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaNum}, *newMetaRes)
 	return nil, nil
 }
 
 func opCoinbase(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, COINBASE)
-
 	scope.Stack.push(new(uint256.Int).SetBytes(interpreter.evm.Context.Coinbase.Bytes()))
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
 func opTimestamp(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, TIMESTAMP)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(interpreter.evm.Context.Time))
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
 func opNumber(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, NUMBER)
-
 	v, _ := uint256.FromBig(interpreter.evm.Context.BlockNumber)
 	scope.Stack.push(v)
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
 func opDifficulty(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, DIFFICULTY)
-
 	v, _ := uint256.FromBig(interpreter.evm.Context.Difficulty)
 	scope.Stack.push(v)
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
 func opRandom(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, RANDOM)
-
 	v := new(uint256.Int).SetBytes(interpreter.evm.Context.Random.Bytes())
 	scope.Stack.push(v)
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
 func opGasLimit(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, GASLIMIT)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(interpreter.evm.Context.GasLimit))
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{SourceMeta}, *newMetaRes)
 	return nil, nil
 }
 
-// 我想这个指令会产生一个汇点，也许有了数据流图之后它可以被抛弃
-// !! 后续考虑这个的优化
-// !! 标记数据的同时应该还需要标记需要什么样的数据……可能需要规定opCode之间的数据传递模式
 func opPop(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, GASLIMIT)
-
 	scope.Stack.pop()
-
-	meta := scope.metaStack.pop()
-
-	interpreter.evm.Graph.AddDependency([]Metadata{meta}, *newMetaRes)
 	return nil, nil
 }
 
 func opMload(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MLOAD)
-
 	v := scope.Stack.peek()
 	offset := int64(v.Uint64())
 	v.SetBytes(scope.Memory.GetPtr(offset, 32))
-
-	metaV := scope.metaStack.pop()
-	metaMem := scope.metaMemory.GetPtr(offset, 32)
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaV, metaMem}, *newMetaRes)
 	return nil, nil
 }
 
 func opMstore(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MSTORE)
-
 	// pop value of the stack
 	mStart, val := scope.Stack.pop(), scope.Stack.pop()
 	scope.Memory.Set32(mStart.Uint64(), &val)
-
-	metaStart, metaVal := scope.metaStack.pop(), scope.metaStack.pop()
-	scope.metaMemory.Set32(mStart.Uint64(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaStart, metaVal}, *newMetaRes)
 	return nil, nil
 }
 
 func opMstore8(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MSTORE8)
-
 	off, val := scope.Stack.pop(), scope.Stack.pop()
 	scope.Memory.store[off.Uint64()] = byte(val.Uint64())
-
-	metaOff, metaVal := scope.metaStack.pop(), scope.metaStack.pop()
-	scope.metaMemory.store[off.Uint64()] = *newMetaRes
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaOff, metaVal}, *newMetaRes)
 	return nil, nil
 }
 
 func opSload(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SLOAD)
-
 	loc := scope.Stack.peek()
 	hash := common.Hash(loc.Bytes32())
 	val := interpreter.evm.StateDB.GetState(scope.Contract.Address(), hash)
 	loc.SetBytes(val.Bytes())
-
-	metaLoc := scope.metaStack.pop()
-	metaVal := scope.metaStorage.Get(scope.Contract.Address(), hash)
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{metaLoc, metaVal, source}, *newMetaRes)
 	return nil, nil
 }
 
 func opSstore(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SSTORE)
-
 	if interpreter.readOnly {
 		return nil, ErrWriteProtection
 	}
 	loc := scope.Stack.pop()
 	val := scope.Stack.pop()
 	interpreter.evm.StateDB.SetState(scope.Contract.Address(), loc.Bytes32(), val.Bytes32())
-
-	metaLoc := scope.metaStack.pop()
-	metaVal := scope.metaStack.pop()
-	scope.metaStorage.Set(scope.Contract.Address(), loc.Bytes32(), *newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{metaLoc, metaVal, source}, *newMetaRes)
 	return nil, nil
 }
 
 func opJump(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, JUMP)
-
 	if interpreter.evm.abort.Load() {
 		return nil, errStopToken
 	}
@@ -990,16 +538,10 @@ func opJump(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 		return nil, ErrInvalidJump
 	}
 	*pc = pos.Uint64() - 1 // pc will be increased by the interpreter loop
-
-	metaPos := scope.metaStack.pop()
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaPos}, *newMetaRes)
 	return nil, nil
 }
 
 func opJumpi(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, JUMPI)
-
 	if interpreter.evm.abort.Load() {
 		return nil, errStopToken
 	}
@@ -1010,60 +552,29 @@ func opJumpi(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]by
 		}
 		*pc = pos.Uint64() - 1 // pc will be increased by the interpreter loop
 	}
-	metaPos := scope.metaStack.pop()
-	metaCond := scope.metaStack.pop()
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaPos, metaCond}, *newMetaRes)
 	return nil, nil
 }
 
 func opJumpdest(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	// 他不算一个正常的opcode
-	*scope.opCodeCounter--
 	return nil, nil
 }
 
 func opPc(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, PC)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(*pc))
-
-	scope.metaStack.push(newMetaRes)
-
-	// pc_last_modify 一定是当前index - 1
-	pc_last_modify := interpreter.evm.Graph.Vertexes[newMetaRes.Index-1]
-	interpreter.evm.Graph.AddDependency([]Metadata{pc_last_modify}, *newMetaRes)
 	return nil, nil
 }
 
 func opMsize(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, MSIZE)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(uint64(scope.Memory.Len())))
-
-	scope.metaStack.push(newMetaRes)
-
-	memoryLenLastModify := interpreter.evm.Graph.Vertexes[scope.memory_len_last_modify]
-	interpreter.evm.Graph.AddDependency([]Metadata{memoryLenLastModify}, *newMetaRes)
 	return nil, nil
 }
 
-// 从scope.Contract里面拿数据了
 func opGas(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, GAS)
-
 	scope.Stack.push(new(uint256.Int).SetUint64(scope.Contract.Gas))
-
-	scope.metaStack.push(newMetaRes)
-
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, nil
 }
 
-// 从他拿数据的地方构建依赖，但要更新interpreter和contract
 func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CREATE)
 	if interpreter.readOnly {
 		return nil, ErrWriteProtection
 	}
@@ -1086,11 +597,7 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 		bigVal = value.ToBig()
 	}
 
-	interpreter.evm.Graph.Vertexes[newMetaRes.Index] = *newMetaRes
-	*scope.opCodeCounter++
-	res, addr, returnGas, suberr := interpreter.evm.Create(scope.Contract, input, gas, bigVal, newMetaRes.Index)
-	// *scope.opCodeCounter--
-
+	res, addr, returnGas, suberr := interpreter.evm.Create(scope.Contract, input, gas, bigVal, TxInputSourceMeta.Index)
 	// Push item on the stack based on the returned error. If the ruleset is
 	// homestead we must check for CodeStoreOutOfGasError (homestead only
 	// rule) and treat as an error, if the ruleset is frontier we must
@@ -1103,35 +610,17 @@ func opCreate(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]b
 		stackvalue.SetBytes(addr.Bytes())
 	}
 	scope.Stack.push(&stackvalue)
-
-	metaValue := scope.metaStack.pop()
-	metaOffset := scope.metaStack.pop()
-	metaSize := scope.metaStack.pop()
-	metaInput := scope.metaMemory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaValue, metaOffset, metaSize, metaInput}, *newMetaRes)
-
-	// 改了scope.Contract中的东西，sourceIndex就会改变
 	scope.Contract.Gas += returnGas
-	scope.Contract.SourceIndex = newMetaRes.Index
 
 	if suberr == ErrExecutionReverted {
-		// 改了Interpreter.returnData，sourceIndex就会改变
 		interpreter.returnData = res // set REVERT data to return data buffer
-		interpreter.sourceIndex = newMetaRes.Index
 		return res, nil
 	}
-	// 改了Interpreter.returnData，sourceIndex就会改变
 	interpreter.returnData = nil // clear dirty return data buffer
-	interpreter.sourceIndex = newMetaRes.Index
 	return nil, nil
 }
 
 func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CREATE2)
-
 	if interpreter.readOnly {
 		return nil, ErrWriteProtection
 	}
@@ -1142,6 +631,7 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 		input        = scope.Memory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
 		gas          = scope.Contract.Gas
 	)
+
 	// Apply EIP150
 	gas -= gas / 64
 	scope.Contract.UseGas(gas)
@@ -1152,12 +642,8 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 	if !endowment.IsZero() {
 		bigEndowment = endowment.ToBig()
 	}
-	interpreter.evm.Graph.Vertexes[newMetaRes.Index] = *newMetaRes
-	*scope.opCodeCounter++
 	res, addr, returnGas, suberr := interpreter.evm.Create2(scope.Contract, input, gas,
-		bigEndowment, &salt, newMetaRes.Index)
-	// *scope.opCodeCounter--
-
+		bigEndowment, &salt, TxInputSourceMeta.Index)
 	// Push item on the stack based on the returned error.
 	if suberr != nil {
 		stackvalue.Clear()
@@ -1165,38 +651,17 @@ func opCreate2(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]
 		stackvalue.SetBytes(addr.Bytes())
 	}
 	scope.Stack.push(&stackvalue)
-
-	metaEndowment := scope.metaStack.pop()
-	metaOffset := scope.metaStack.pop()
-	metaSize := scope.metaStack.pop()
-	metaSalt := scope.metaStack.pop()
-	metaInput := scope.metaMemory.GetCopy(int64(offset.Uint64()), int64(size.Uint64()))
-
-	scope.metaStack.push(newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaEndowment, metaOffset, metaSize, metaSalt, metaInput}, *newMetaRes)
-
 	scope.Contract.Gas += returnGas
-	scope.Contract.SourceIndex = newMetaRes.Index
 
 	if suberr == ErrExecutionReverted {
 		interpreter.returnData = res // set REVERT data to return data buffer
-		interpreter.sourceIndex = newMetaRes.Index
 		return res, nil
 	}
 	interpreter.returnData = nil // clear dirty return data buffer
-	interpreter.sourceIndex = newMetaRes.Index
 	return nil, nil
 }
 
-// call 相关的依赖图比较难画
-// 可以考虑不直接加依赖，因为参数会被传入Call函数，依赖关系由Call函数来衍生
-// 即基础OpCode会把依赖加上
-// 这是否意味着Call相关从Contract上下文里获取的数据，都需要补充meta数据？
-
 func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALL)
-
 	stack := scope.Stack
 	// Pop gas. The actual gas in interpreter.evm.callGasTemp.
 	// We can use this as a temporary value
@@ -1220,10 +685,7 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 		bigVal = value.ToBig()
 	}
 
-	interpreter.evm.Graph.Vertexes[newMetaRes.Index] = *newMetaRes
-	*scope.opCodeCounter++
-	ret, returnGas, err := interpreter.evm.Call(scope.Contract, toAddr, args, gas, bigVal, newMetaRes.Index)
-	// *scope.opCodeCounter--
+	ret, returnGas, err := interpreter.evm.Call(scope.Contract, toAddr, args, gas, bigVal, TxInputSourceMeta.Index)
 
 	if err != nil {
 		temp.Clear()
@@ -1234,33 +696,13 @@ func opCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byt
 	if err == nil || err == ErrExecutionReverted {
 		scope.Memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
-
-	metaTemp := scope.metaStack.pop()
-	metaAddr := scope.metaStack.pop()
-	metaValue := scope.metaStack.pop()
-	metaInOffset := scope.metaStack.pop()
-	metaInSize := scope.metaStack.pop()
-	metaRetOffset := scope.metaStack.pop()
-	metaRetSize := scope.metaStack.pop()
-	metaArgs := scope.metaMemory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
-
-	// two results
-	scope.metaStack.push(newMetaRes)
-	scope.metaMemory.Set(retOffset.Uint64(), retSize.Uint64(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaTemp, metaAddr, metaValue, metaInOffset, metaInSize, metaRetOffset, metaRetSize, metaArgs}, *newMetaRes)
-
 	scope.Contract.Gas += returnGas
-	scope.Contract.SourceIndex = newMetaRes.Index
 
 	interpreter.returnData = ret
-	interpreter.sourceIndex = newMetaRes.Index
 	return ret, nil
 }
 
 func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, CALLCODE)
-
 	// Pop gas. The actual gas is in interpreter.evm.callGasTemp.
 	stack := scope.Stack
 	// We use it as a temporary value
@@ -1278,11 +720,8 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 		gas += params.CallStipend
 		bigVal = value.ToBig()
 	}
-	interpreter.evm.Graph.Vertexes[newMetaRes.Index] = *newMetaRes
-	*scope.opCodeCounter++
-	ret, returnGas, err := interpreter.evm.CallCode(scope.Contract, toAddr, args, gas, bigVal, newMetaRes.Index)
-	// *scope.opCodeCounter--
 
+	ret, returnGas, err := interpreter.evm.CallCode(scope.Contract, toAddr, args, gas, bigVal, TxInputSourceMeta.Index)
 	if err != nil {
 		temp.Clear()
 	} else {
@@ -1292,32 +731,13 @@ func opCallCode(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([
 	if err == nil || err == ErrExecutionReverted {
 		scope.Memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
-
-	metaTemp := scope.metaStack.pop()
-	metaAddr := scope.metaStack.pop()
-	metaValue := scope.metaStack.pop()
-	metaInOffset := scope.metaStack.pop()
-	metaInSize := scope.metaStack.pop()
-	metaRetOffset := scope.metaStack.pop()
-	metaRetSize := scope.metaStack.pop()
-	metaArgs := scope.metaMemory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
-
-	// two results
-	scope.metaStack.push(newMetaRes)
-	scope.metaMemory.Set(retOffset.Uint64(), retSize.Uint64(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaTemp, metaAddr, metaValue, metaInOffset, metaInSize, metaRetOffset, metaRetSize, metaArgs}, *newMetaRes)
-
 	scope.Contract.Gas += returnGas
-	scope.Contract.SourceIndex = newMetaRes.Index
 
 	interpreter.returnData = ret
-	interpreter.sourceIndex = newMetaRes.Index
 	return ret, nil
 }
 
 func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, DELEGATECALL)
 	stack := scope.Stack
 	// Pop gas. The actual gas is in interpreter.evm.callGasTemp.
 	// We use it as a temporary value
@@ -1329,11 +749,7 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	// Get arguments from the memory.
 	args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
-	interpreter.evm.Graph.Vertexes[newMetaRes.Index] = *newMetaRes
-	*scope.opCodeCounter++
-	ret, returnGas, err := interpreter.evm.DelegateCall(scope.Contract, toAddr, args, gas, newMetaRes.Index)
-	// *scope.opCodeCounter--
-
+	ret, returnGas, err := interpreter.evm.DelegateCall(scope.Contract, toAddr, args, gas, TxInputSourceMeta.Index)
 	if err != nil {
 		temp.Clear()
 	} else {
@@ -1343,31 +759,13 @@ func opDelegateCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 	if err == nil || err == ErrExecutionReverted {
 		scope.Memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
-
-	metaTemp := scope.metaStack.pop()
-	metaAddr := scope.metaStack.pop()
-	metaInOffset := scope.metaStack.pop()
-	metaInSize := scope.metaStack.pop()
-	metaRetOffset := scope.metaStack.pop()
-	metaRetSize := scope.metaStack.pop()
-	metaArgs := scope.metaMemory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
-
-	// two results
-	scope.metaStack.push(newMetaRes)
-	scope.metaMemory.Set(retOffset.Uint64(), retSize.Uint64(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaTemp, metaAddr, metaInOffset, metaInSize, metaRetOffset, metaRetSize, metaArgs}, *newMetaRes)
-
 	scope.Contract.Gas += returnGas
-	scope.Contract.SourceIndex = newMetaRes.Index
 
 	interpreter.returnData = ret
-	interpreter.sourceIndex = newMetaRes.Index
 	return ret, nil
 }
 
 func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, STATICCALL)
 	// Pop gas. The actual gas is in interpreter.evm.callGasTemp.
 	stack := scope.Stack
 	// We use it as a temporary value
@@ -1379,11 +777,7 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	// Get arguments from the memory.
 	args := scope.Memory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
 
-	interpreter.evm.Graph.Vertexes[newMetaRes.Index] = *newMetaRes
-	*scope.opCodeCounter++
-	ret, returnGas, err := interpreter.evm.StaticCall(scope.Contract, toAddr, args, gas, newMetaRes.Index)
-	// *scope.opCodeCounter--
-
+	ret, returnGas, err := interpreter.evm.StaticCall(scope.Contract, toAddr, args, gas, TxInputSourceMeta.Index)
 	if err != nil {
 		temp.Clear()
 	} else {
@@ -1393,78 +787,36 @@ func opStaticCall(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext)
 	if err == nil || err == ErrExecutionReverted {
 		scope.Memory.Set(retOffset.Uint64(), retSize.Uint64(), ret)
 	}
-
-	metaTemp := scope.metaStack.pop()
-	metaAddr := scope.metaStack.pop()
-	metaInOffset := scope.metaStack.pop()
-	metaInSize := scope.metaStack.pop()
-	metaRetOffset := scope.metaStack.pop()
-	metaRetSize := scope.metaStack.pop()
-	metaArgs := scope.metaMemory.GetPtr(int64(inOffset.Uint64()), int64(inSize.Uint64()))
-
-	scope.metaStack.push(newMetaRes)
-	scope.metaMemory.Set(retOffset.Uint64(), retSize.Uint64(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaTemp, metaAddr, metaInOffset, metaInSize, metaRetOffset, metaRetSize, metaArgs}, *newMetaRes)
-
 	scope.Contract.Gas += returnGas
-	scope.Contract.SourceIndex = newMetaRes.Index
 
 	interpreter.returnData = ret
-	interpreter.sourceIndex = newMetaRes.Index
 	return ret, nil
 }
 
 func opReturn(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, RETURN)
-
 	offset, size := scope.Stack.pop(), scope.Stack.pop()
 	ret := scope.Memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
 
-	metaOffset := scope.metaStack.pop()
-	metaSize := scope.metaStack.pop()
-	metaRet := scope.metaMemory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaOffset, metaSize, metaRet}, *newMetaRes)
 	return ret, errStopToken
 }
 
 func opRevert(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, REVERT)
-
 	offset, size := scope.Stack.pop(), scope.Stack.pop()
 	ret := scope.Memory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
 
-	metaOffset := scope.metaStack.pop()
-	metaSize := scope.metaStack.pop()
-	metaRet := scope.metaMemory.GetPtr(int64(offset.Uint64()), int64(size.Uint64()))
-
 	interpreter.returnData = ret
-	interpreter.sourceIndex = newMetaRes.Index
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaOffset, metaSize, metaRet}, *newMetaRes)
 	return ret, ErrExecutionReverted
 }
 
-// 没有对应的opcode，我们先跳过吧
 func opUndefined(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	*scope.opCodeCounter--
 	return nil, &ErrInvalidOpCode{opcode: OpCode(scope.Contract.Code[*pc])}
 }
 
-// 个人理解，stop需要依赖上一个index的指令（我猜是一个jump）
 func opStop(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, STOP)
-	source := interpreter.evm.Graph.Vertexes[newMetaRes.Index-1]
-	interpreter.evm.Graph.AddDependency([]Metadata{source}, *newMetaRes)
 	return nil, errStopToken
 }
 
-// 从stack、balance、contract拿数据
-// 改写了balance
 func opSelfdestruct(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SELFDESTRUCT)
-
 	if interpreter.readOnly {
 		return nil, ErrWriteProtection
 	}
@@ -1476,22 +828,10 @@ func opSelfdestruct(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext
 		tracer.CaptureEnter(SELFDESTRUCT, scope.Contract.Address(), beneficiary.Bytes20(), []byte{}, 0, balance)
 		tracer.CaptureExit([]byte{}, 0, nil)
 	}
-
-	metaBeneficiary := scope.metaStack.pop()
-	metaBalance := scope.metaStorage.Get(scope.Contract.Address(), common.Hash{})
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-
-	scope.metaBalance.Set(beneficiary.Bytes20(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaBeneficiary, metaBalance, source}, *newMetaRes)
 	return nil, errStopToken
 }
 
-// 从stack、balance、contract拿数据
-// 改写了contractAddr\beneficairy的balance
 func opSelfdestruct6780(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, SELFDESTRUCT)
-
 	if interpreter.readOnly {
 		return nil, ErrWriteProtection
 	}
@@ -1504,15 +844,6 @@ func opSelfdestruct6780(pc *uint64, interpreter *EVMInterpreter, scope *ScopeCon
 		tracer.CaptureEnter(SELFDESTRUCT, scope.Contract.Address(), beneficiary.Bytes20(), []byte{}, 0, balance)
 		tracer.CaptureExit([]byte{}, 0, nil)
 	}
-
-	metaBeneficiary := scope.metaStack.pop()
-	metaBalance := scope.metaStorage.Get(scope.Contract.Address(), common.Hash{})
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-
-	scope.metaBalance.Set(beneficiary.Bytes20(), *newMetaRes)
-	scope.metaBalance.Set(scope.Contract.Address(), *newMetaRes)
-
-	interpreter.evm.Graph.AddDependency([]Metadata{metaBeneficiary, metaBalance, source}, *newMetaRes)
 	return nil, errStopToken
 }
 
@@ -1546,12 +877,8 @@ func makeLog(size int) executionFunc {
 	}
 }
 
-// opPush1 is a specialized version of pushN，推入下一个操作码
-// 从contract、pc拿数据
-// 修改pc，stack
+// opPush1 is a specialized version of pushN
 func opPush1(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-	newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, PUSH1)
-
 	var (
 		codeLen = uint64(len(scope.Contract.Code))
 		integer = new(uint256.Int)
@@ -1562,26 +889,12 @@ func opPush1(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]by
 	} else {
 		scope.Stack.push(integer.Clear())
 	}
-
-	scope.metaStack.push(newMetaRes)
-
-	last_pc_modifer := interpreter.evm.Graph.Vertexes[newMetaRes.Index-1]
-	source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-	if newMetaRes.Index == 1400 {
-		fmt.Println("debug")
-	}
-	interpreter.evm.Graph.AddDependency([]Metadata{last_pc_modifer, source}, *newMetaRes)
 	return nil, nil
 }
 
-// make push instruction function, 压入下面size个opcode
-// 从contract、pc拿数据
-// 修改pc，stack
+// make push instruction function
 func makePush(size uint64, pushByteSize int) executionFunc {
 	return func(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-		opcode := PUSH2 + OpCode(size-2)
-		newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, opcode)
-
 		codeLen := len(scope.Contract.Code)
 
 		startMin := codeLen
@@ -1597,35 +910,16 @@ func makePush(size uint64, pushByteSize int) executionFunc {
 		integer := new(uint256.Int)
 		scope.Stack.push(integer.SetBytes(common.RightPadBytes(
 			scope.Contract.Code[startMin:endMin], pushByteSize)))
-		*pc += size
 
-		scope.metaStack.push(newMetaRes)
-
-		last_pc_modifer := interpreter.evm.Graph.Vertexes[newMetaRes.Index-1]
-		source := interpreter.evm.Graph.Vertexes[scope.Contract.SourceIndex]
-
-		interpreter.evm.Graph.AddDependency([]Metadata{last_pc_modifer, source}, *newMetaRes)
+		*pc += size
 		return nil, nil
 	}
 }
 
 // make dup instruction function
-// 把第size（？）个元素复制到栈顶
-// 涉及到从stack拿数据，修改stack
-// 同时这个size从哪里定的呢？我只能假定这种直接对stack的操作对前一个opcode很有依赖性，从运行结果上来看，没有，这个在编译上就确定了
 func makeDup(size int64) executionFunc {
 	return func(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-		opcode := DUP1 + OpCode(size-1)
-		newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, opcode)
-
 		scope.Stack.dup(int(size))
-
-		relatedMeta := scope.metaStack.data[scope.metaStack.len()-int(size)]
-
-		//!! dup只是push一个进去，明天从这里开始DEBUG起
-		scope.metaStack.push(newMetaRes)
-
-		interpreter.evm.Graph.AddDependency([]Metadata{relatedMeta}, *newMetaRes)
 		return nil, nil
 	}
 }
@@ -1635,19 +929,7 @@ func makeSwap(size int64) executionFunc {
 	// switch n + 1 otherwise n would be swapped with n
 	size++
 	return func(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
-		opcode := SWAP1 + OpCode(size-1)
-		newMetaRes := NewMetadata(interpreter.evm.StateDB.GetTxId(), *scope.opCodeCounter, *scope.Contract.CodeAddr, *pc, opcode)
-
 		scope.Stack.swap(int(size))
-
-		metaTarget := scope.metaStack.data[scope.metaStack.len()-int(size)]
-		metaPeek := scope.metaStack.data[scope.metaStack.len()-1]
-
-		// 因为修改了stack，所以需要更新metaStack
-		scope.metaStack.data[scope.metaStack.len()-int(size)] = *newMetaRes
-		scope.metaStack.data[scope.metaStack.len()-1] = *newMetaRes
-
-		interpreter.evm.Graph.AddDependency([]Metadata{metaTarget, metaPeek}, *newMetaRes)
 		return nil, nil
 	}
 }
@@ -0,0 +1,149 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// callDFG calls the contract at addr on a Cancun EVM of the given config, and
+// returns the EVM.
+func callDFG(statedb StateDB, config Config, addr common.Address) (*EVM, error) {
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.ShanghaiTime = new(uint64)
+	chainConfig.CancunTime = new(uint64)
+
+	context := BlockContext{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: new(big.Int),
+		Random:      &common.Hash{},
+	}
+	evm := NewEVM(context, TxContext{}, statedb, &chainConfig, config)
+	statedb.Prepare(evm.chainRules, common.Address{}, common.Address{}, &addr, ActivePrecompiles(evm.chainRules), nil)
+	_, _, err := evm.Call(AccountRef(common.Address{}), addr, nil, 1_000_000, new(big.Int), TxInputSourceMeta.Index)
+	return evm, err
+}
+
+// TestDFGWriteProtection checks that the state-writing instructions fail in a
+// static context before putting their vertex in the arena.
+func TestDFGWriteProtection(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		callee = common.BytesToAddress([]byte("callee"))
+	)
+	for _, test := range []struct {
+		op   OpCode
+		code []byte
+	}{
+		{SSTORE, []byte{byte(PUSH1), 1, byte(PUSH0), byte(SSTORE)}},
+		{TSTORE, []byte{byte(PUSH1), 1, byte(PUSH0), byte(TSTORE)}},
+		{LOG1, []byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(LOG1)}},
+		{CREATE, []byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(CREATE)}},
+		{CREATE2, []byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(CREATE2)}},
+		{CALL, []byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH1), 1, byte(CALLER), byte(GAS), byte(CALL)}},
+		{SELFDESTRUCT, []byte{byte(CALLER), byte(SELFDESTRUCT)}},
+	} {
+		// staticcall(gas, callee, 0, 0, 0, 0), its success stored in slot 0
+		code := []byte{byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH20)}
+		code = append(code, callee.Bytes()...)
+		code = append(code, byte(GAS), byte(STATICCALL), byte(PUSH0), byte(SSTORE))
+
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(caller, code)
+		statedb.SetCode(callee, test.code)
+		statedb.SetBalance(callee, big.NewInt(1))
+		evm, err := callDFG(statedb, Config{EnableDFG: true}, caller)
+		if err != nil {
+			t.Fatalf("%v: call failed: %v", test.op, err)
+		}
+		if statedb.GetState(caller, common.Hash{}) != (common.Hash{}) {
+			t.Errorf("%v: succeeded in a static context", test.op)
+		}
+		calleeAddr := evm.Graph.internAddr(callee)
+		for _, v := range evm.Graph.vertices {
+			if v.op == test.op && v.addr == calleeAddr {
+				t.Errorf("%v: vertex put in the arena", test.op)
+			}
+		}
+	}
+}
+
+// TestDFGStepIndexes checks that the vertices take consecutive indexes, the
+// ones of a called frame right after the call, and that JUMPDEST and, in
+// value-flow mode, the stack shuffling instructions take none. PC and STOP
+// depend on the vertex the context of their frame comes from, here the call.
+func TestDFGStepIndexes(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		callee = common.BytesToAddress([]byte("callee"))
+	)
+	// call(gas, callee, 0, 0, 0, 0, 0), then pc
+	code := []byte{byte(JUMPDEST), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH0), byte(PUSH20)}
+	code = append(code, callee.Bytes()...)
+	code = append(code, byte(GAS), byte(CALL), byte(POP), byte(PC), byte(STOP))
+	calleeCode := []byte{byte(JUMPDEST), byte(PC), byte(POP), byte(STOP)}
+
+	for _, test := range []struct {
+		valueFlow bool
+		ops       []string
+	}{
+		{false, []string{"PUSH0", "PUSH0", "PUSH0", "PUSH0", "PUSH0", "PUSH20", "GAS", "CALL", "PC", "POP", "STOP", "POP", "PC", "STOP"}},
+		{true, []string{"GAS", "CALL", "PC", "STOP", "PC", "STOP"}},
+	} {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(caller, code)
+		statedb.SetCode(callee, calleeCode)
+		evm, err := callDFG(statedb, Config{EnableDFG: true, DFGValueFlow: test.valueFlow}, caller)
+		if err != nil {
+			t.Fatalf("value flow %v: call failed: %v", test.valueFlow, err)
+		}
+		var (
+			ops  []string
+			call = -1
+		)
+		evm.Graph.ForEachVertex(func(v Metadata) error {
+			if v.IsSource() {
+				return nil
+			}
+			if v.Index != len(ops) {
+				t.Errorf("value flow %v: vertex %d has index %d", test.valueFlow, len(ops), v.Index)
+			}
+			ops = append(ops, v.OpCode)
+			switch v.OpCode {
+			case "CALL":
+				call = v.Index
+			case "PC", "STOP":
+				if kinds := evm.Graph.Kinds(call, v.Index); len(kinds) != 1 || kinds[0] != CallEdge {
+					t.Errorf("value flow %v: %v at %d has edges %v from the call", test.valueFlow, v.OpCode, v.Index, kinds)
+				}
+			}
+			return nil
+		})
+		if !reflect.DeepEqual(ops, test.ops) {
+			t.Errorf("value flow %v: have vertices %v, want %v", test.valueFlow, ops, test.ops)
+		}
+	}
+}
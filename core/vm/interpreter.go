@@ -17,6 +17,8 @@
 package vm
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -29,6 +31,10 @@ type Config struct {
 	NoBaseFee               bool      // Forces the EIP-1559 baseFee to 0 (needed for 0 price calls)
 	EnablePreimageRecording bool      // Enables recording of SHA3/keccak preimages
 	ExtraEips               []int     // Additional EIPS that are to be enabled
+	EnableDFG               bool      // Enables shadow-state tracking and dependency graph generation
+	DFGDropReverted         bool      // Drops the vertices of reverted frames instead of marking them reverted
+	DFGValueFlow            bool      // Leaves PUSH, DUP, SWAP and POP out of the dependency graph, see metaValueFlow.go
+	ParallelCallWorkers     int       // Number of workers running the calls spawned through ParallelSpawnAddress, 0 disables it
 }
 
 // ScopeContext contains the things that are per-call, such as stack and memory,
@@ -39,15 +45,62 @@ type ScopeContext struct {
 	Contract *Contract
 
 	// For DFG Generation
-	metaMemory  *MetaMemory
-	metaStack   *MetaStack
-	metaStorage *MetaStorage
+	metaMemory   *MetaMemory
+	metaStack    *MetaStack
+	metaStorage  *MetaStorage
+	metaTStorage *MetaTransientStorage
+
+	// metaInput is the provenance of Contract.Input, nil if the frame was
+	// entered from outside the EVM. metaReturn is the provenance of the data
+	// handed back by RETURN or REVERT, returnVertex the instruction itself.
+	metaInput    *MetaMemory
+	metaReturn   *MetaMemory
+	returnVertex VertexHandle
 
-	opCodeCounter          *int
+	// controlDeps holds the branches the frame is currently control dependent
+	// on, innermost last. postDoms are the post-dominators of the code.
+	controlDeps []controlDep
+	postDoms    map[uint64]uint64
+
+	index                  int // index of the vertex of the current step
 	memory_len_last_modify int
+	frame                  int // id of the frame the vertices are attributed to
+	txFrame                int // id of the frame within its transaction
+	step                   int // number of instructions the frame executed
 
 	metaBalance *MetaAccount
 	metaCode    *MetaAccount
+	graph       *DependencyGraph
+}
+
+// MetaStackData returns the vertices which pushed the values on the stack,
+// the top of the stack last like Stack.Data. In value-flow mode the values
+// pushed by a PUSH are constants. It is nil if the dependency graph is not
+// built.
+func (s *ScopeContext) MetaStackData() []Metadata {
+	if s.graph == nil {
+		return nil
+	}
+	data := make([]Metadata, len(s.metaStack.data))
+	for i, h := range s.metaStack.data {
+		data[i] = s.graph.handleMetadata(h)
+	}
+	return data
+}
+
+// MetaMemoryWriters returns the vertices which last wrote offset + size of the
+// memory, in the order they first appear in the range. Bytes never written
+// have no writer. It is nil if the dependency graph is not built.
+func (s *ScopeContext) MetaMemoryWriters(offset, size uint64) []Metadata {
+	if s.graph == nil {
+		return nil
+	}
+	handles := s.metaMemory.GetWriters(offset, size)
+	writers := make([]Metadata, len(handles))
+	for i, h := range handles {
+		writers[i] = s.graph.handleMetadata(h)
+	}
+	return writers
 }
 
 // EVMInterpreter represents an EVM interpreter
@@ -61,6 +114,12 @@ type EVMInterpreter struct {
 	readOnly    bool   // Whether to throw on stateful modifications
 	returnData  []byte // Last CALL's return data for subsequent reuse
 	sourceIndex int
+
+	callArgsMeta   *MetaMemory  // Provenance of the input of the frame being entered
+	returnDataMeta *MetaMemory  // Provenance of returnData
+	returnVertex   VertexHandle // RETURN or REVERT ending the last frame, its source if it stopped
+
+	postDoms map[common.Hash]map[uint64]uint64 // Post-dominators of the JUMPIs per code hash
 }
 
 // NewEVMInterpreter returns a new instance of the Interpreter.
@@ -107,6 +166,13 @@ func NewEVMInterpreter(evm *EVM) *EVMInterpreter {
 		}
 	}
 	evm.Config.ExtraEips = extraEips
+	if evm.Config.EnableDFG {
+		eip6780 := evm.chainRules.IsCancun
+		for _, eip := range extraEips {
+			eip6780 = eip6780 || eip == 6780
+		}
+		table = newDFGInstructionSet(table, evm.chainRules.IsMerge, eip6780, evm.Config.DFGValueFlow)
+	}
 	return &EVMInterpreter{evm: evm, table: table, sourceIndex: -1}
 }
 
@@ -131,6 +197,10 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 	// Reset the previous call's return data. It's unimportant to preserve the old buffer
 	// as every returning call will return new data anyway.
 	in.returnData = nil
+	if in.evm.Config.EnableDFG {
+		in.returnDataMeta = nil
+		in.returnVertex = handleOf(contract.SourceIndex)
+	}
 
 	// Don't bother with the execution if there's no code.
 	if len(contract.Code) == 0 {
@@ -153,25 +223,42 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 		logged  bool   // deferred EVMLogger should ignore already logged steps
 		res     []byte // result of the opcode execution function
 		debug   = in.evm.Config.Tracer != nil
+		dfg     = in.evm.Config.EnableDFG
+		opPc    uint64 // pc of the operation, before jumps move it
+		mark    int    // dependencies held for the tracer before the operation
 
 		callContext = &ScopeContext{
 			Memory:   mem,
 			Stack:    stack,
 			Contract: contract,
-
-			metaMemory:    in.evm.metaMemory,
-			metaStack:     in.evm.metaStack,
-			metaStorage:   in.evm.metaStorage,
-			metaBalance:   in.evm.metaBalance,
-			metaCode:      in.evm.metaCode,
-			opCodeCounter: &in.evm.opCodeCounter,
 		}
 	)
+	if dfg {
+		// Like the stack and memory, the shadow ones are owned by the frame
+		callContext.metaMemory = newMetaMemory()
+		callContext.metaStack = newMetaStack()
+		callContext.metaInput, in.callArgsMeta = in.callArgsMeta, nil
+		callContext.returnVertex = in.returnVertex
+		callContext.metaStorage = in.evm.metaStorage
+		callContext.metaTStorage = in.evm.metaTStorage
+		callContext.metaBalance = in.evm.metaBalance
+		callContext.metaCode = in.evm.metaCode
+		callContext.graph = in.evm.Graph
+		callContext.frame = in.evm.frameCounter
+		callContext.txFrame = in.evm.frameCounter - in.evm.txFrameBase
+		in.evm.frameCounter++
+	}
 	// Don't move this deferred function, it's placed before the capturestate-deferred method,
 	// so that it get's executed _after_: the capturestate needs the stacks before
 	// they are returned to the pools
 	defer func() {
 		returnStack(stack)
+		if dfg {
+			returnMetaStack(callContext.metaStack)
+			// Hand the provenance of the returned data over to the caller
+			in.returnDataMeta = callContext.metaReturn
+			in.returnVertex = callContext.returnVertex
+		}
 	}()
 	contract.Input = input
 
@@ -242,20 +329,45 @@ func (in *EVMInterpreter) Run(contract *Contract, input []byte, readOnly bool) (
 			}
 			if memorySize > 0 {
 				mem.Resize(memorySize)
-				callContext.metaMemory.Resize(memorySize)
-				callContext.memory_len_last_modify = *callContext.opCodeCounter
+				if dfg {
+					callContext.metaMemory.Resize(memorySize)
+					callContext.memory_len_last_modify = in.evm.opCodeCounter
+				}
 			}
 		} else if debug {
 			in.evm.Config.Tracer.CaptureState(pc, op, gasCopy, cost, callContext, in.returnData, in.evm.depth, err)
 			logged = true
 		}
 		// execute the operation
+		if dfg {
+			// The edges of the operation are reported to the tracer at once,
+			// when its vertex is complete
+			mark = in.evm.Graph.batch()
+			callContext.reachControl(pc)
+			opPc, callContext.index = pc, in.evm.opCodeCounter
+			// Only the operations making a vertex take an index. It is
+			// taken before they run, so the frames they enter number
+			// their vertices after it
+			if !operation.noVertex {
+				in.evm.opCodeCounter++
+			}
+		}
 		res, err = operation.execute(&pc, in, callContext)
+		if dfg {
+			in.annotateVertex(callContext, operation, callContext.index, cost, err)
+			in.linkControl(callContext, op, opPc, callContext.index)
+			in.evm.Graph.flush(mark)
+			callContext.step++
+		}
 		if err != nil {
 			break
 		}
+		if dfg && dfgInvariantChecks {
+			if have := callContext.metaStack.len(); have != stack.len() {
+				panic(fmt.Sprintf("meta stack out of sync after %v at pc %d: have %d, want %d", op, pc, have, stack.len()))
+			}
+		}
 		pc++
-		*callContext.opCodeCounter++ // the only index
 	}
 
 	if err == errStopToken {
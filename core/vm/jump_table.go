@@ -42,6 +42,10 @@ type operation struct {
 
 	// memorySize returns the memory size required for the operation
 	memorySize memorySizeFunc
+
+	// noVertex tells that the operation makes no vertex in the dependency
+	// graph, so it takes no index
+	noVertex bool
 }
 
 var (
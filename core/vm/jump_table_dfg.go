@@ -0,0 +1,129 @@
+package vm
+
+// dfgInstructions maps the opcodes to their dependency-tracking implementations.
+// Opcodes without an entry keep the stock implementation of the jump table.
+var dfgInstructions = map[OpCode]executionFunc{
+	STOP:           opStopDFG,
+	ADD:            opAddDFG,
+	MUL:            opMulDFG,
+	SUB:            opSubDFG,
+	DIV:            opDivDFG,
+	SDIV:           opSdivDFG,
+	MOD:            opModDFG,
+	SMOD:           opSmodDFG,
+	ADDMOD:         opAddmodDFG,
+	MULMOD:         opMulmodDFG,
+	EXP:            opExpDFG,
+	SIGNEXTEND:     opSignExtendDFG,
+	LT:             opLtDFG,
+	GT:             opGtDFG,
+	SLT:            opSltDFG,
+	SGT:            opSgtDFG,
+	EQ:             opEqDFG,
+	ISZERO:         opIszeroDFG,
+	AND:            opAndDFG,
+	OR:             opOrDFG,
+	XOR:            opXorDFG,
+	NOT:            opNotDFG,
+	BYTE:           opByteDFG,
+	SHL:            opSHLDFG,
+	SHR:            opSHRDFG,
+	SAR:            opSARDFG,
+	KECCAK256:      opKeccak256DFG,
+	ADDRESS:        opAddressDFG,
+	BALANCE:        opBalanceDFG,
+	ORIGIN:         opOriginDFG,
+	CALLER:         opCallerDFG,
+	CALLVALUE:      opCallValueDFG,
+	CALLDATALOAD:   opCallDataLoadDFG,
+	CALLDATASIZE:   opCallDataSizeDFG,
+	CALLDATACOPY:   opCallDataCopyDFG,
+	CODESIZE:       opCodeSizeDFG,
+	CODECOPY:       opCodeCopyDFG,
+	GASPRICE:       opGaspriceDFG,
+	EXTCODESIZE:    opExtCodeSizeDFG,
+	EXTCODECOPY:    opExtCodeCopyDFG,
+	RETURNDATASIZE: opReturnDataSizeDFG,
+	RETURNDATACOPY: opReturnDataCopyDFG,
+	EXTCODEHASH:    opExtCodeHashDFG,
+	BLOCKHASH:      opBlockhashDFG,
+	COINBASE:       opCoinbaseDFG,
+	TIMESTAMP:      opTimestampDFG,
+	NUMBER:         opNumberDFG,
+	GASLIMIT:       opGasLimitDFG,
+	POP:            opPopDFG,
+	MLOAD:          opMloadDFG,
+	MSTORE:         opMstoreDFG,
+	MSTORE8:        opMstore8DFG,
+	SLOAD:          opSloadDFG,
+	SSTORE:         opSstoreDFG,
+	JUMP:           opJumpDFG,
+	JUMPI:          opJumpiDFG,
+	PC:             opPcDFG,
+	MSIZE:          opMsizeDFG,
+	GAS:            opGasDFG,
+	PUSH1:          opPush1DFG,
+	CREATE:         opCreateDFG,
+	CALL:           opCallDFG,
+	CALLCODE:       opCallCodeDFG,
+	RETURN:         opReturnDFG,
+	DELEGATECALL:   opDelegateCallDFG,
+	CREATE2:        opCreate2DFG,
+	STATICCALL:     opStaticCallDFG,
+	REVERT:         opRevertDFG,
+
+	// Opcodes introduced by EIPs, see eips_dfg.go
+	SELFBALANCE: opSelfBalanceDFG,
+	CHAINID:     opChainIDDFG,
+	BASEFEE:     opBaseFeeDFG,
+	TLOAD:       opTloadDFG,
+	TSTORE:      opTstoreDFG,
+	PUSH0:       opPush0DFG,
+	MCOPY:       opMcopyDFG,
+	BLOBHASH:    opBlobHashDFG,
+	BLOBBASEFEE: opBlobBaseFeeDFG,
+}
+
+// newDFGInstructionSet returns a copy of the given jump table in which every
+// opcode that has a dependency-tracking implementation is replaced by it. Gas
+// and stack bounds are left untouched, so both tables charge exactly the same.
+//
+// DIFFICULTY and RANDOM share a slot, so it is resolved by the merge rules,
+// and SELFDESTRUCT depends on whether EIP-6780 is active for the table.
+// JUMPDEST makes no vertex. Neither do the stack shuffling instructions in
+// value-flow mode, where they are replaced once more.
+func newDFGInstructionSet(jt *JumpTable, isMerge, eip6780, valueFlow bool) *JumpTable {
+	tbl := copyJumpTable(jt)
+	for op, execute := range dfgInstructions {
+		// Undefined slots have no cost, only STOP is free and still valid.
+		if op != STOP && !tbl[op].HasCost() {
+			continue
+		}
+		tbl[op].execute = execute
+	}
+	tbl[JUMPDEST].noVertex = true
+	if isMerge {
+		tbl[RANDOM].execute = opRandomDFG
+	} else {
+		tbl[DIFFICULTY].execute = opDifficultyDFG
+	}
+	if eip6780 {
+		tbl[SELFDESTRUCT].execute = opSelfdestruct6780DFG
+	} else {
+		tbl[SELFDESTRUCT].execute = opSelfdestructDFG
+	}
+	for i := 2; i <= 32; i++ {
+		tbl[PUSH1+OpCode(i-1)].execute = makePushDFG(uint64(i), i)
+	}
+	for i := 0; i <= 4; i++ {
+		tbl[LOG0+OpCode(i)].execute = makeLogDFG(i)
+	}
+	for i := 1; i <= 16; i++ {
+		tbl[DUP1+OpCode(i-1)].execute = makeDupDFG(int64(i))
+		tbl[SWAP1+OpCode(i-1)].execute = makeSwapDFG(int64(i))
+	}
+	if valueFlow {
+		setValueFlowInstructions(tbl)
+	}
+	return tbl
+}
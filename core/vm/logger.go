@@ -41,3 +41,18 @@ type EVMLogger interface {
 	CaptureState(pc uint64, op OpCode, gas, cost uint64, scope *ScopeContext, rData []byte, depth int, err error)
 	CaptureFault(pc uint64, op OpCode, gas, cost uint64, scope *ScopeContext, depth int, err error)
 }
+
+// GraphLogger is an EVMLogger reading the dependency graph of the execution
+// from EVM.Graph. The EVM builds the graph for it, as with Config.EnableDFG,
+// if NeedsGraph returns true.
+type GraphLogger interface {
+	EVMLogger
+	NeedsGraph() bool
+}
+
+// DependencyLogger is a GraphLogger following the dependency graph while it is
+// recorded, CaptureDependency getting the sources of the edges into a vertex.
+type DependencyLogger interface {
+	GraphLogger
+	CaptureDependency(sources []Metadata, target Metadata)
+}
@@ -0,0 +1,356 @@
+package vm
+
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// The dependency graph is recorded for every instruction executed, so it keeps
+// its vertices and edges in flat arenas rather than maps of Metadata. The
+// shadow stack, memory and state only hold 32-bit handles into the arena, and
+// the Metadata of a vertex is only put together when it is asked for.
+
+// VertexHandle refers to a vertex of the graph being recorded. The vertex of
+// index i has handle i+numSources, the source vertices taking the handles
+// below. Handles with constantBit set refer to the PUSH constants of value-flow
+// mode instead, which are no vertices.
+type VertexHandle uint32
+
+const (
+	numSources               = int(ContextSource) + 1
+	constantBit VertexHandle = 1 << 31
+)
+
+// handleOf returns the handle of the vertex of the given index.
+func handleOf(index int) VertexHandle {
+	return VertexHandle(index + numSources)
+}
+
+// Index returns the index of the vertex.
+func (h VertexHandle) Index() int {
+	return int(h) - numSources
+}
+
+func (h VertexHandle) isConstant() bool {
+	return h&constantBit != 0
+}
+
+// handle returns the handle of the vertex.
+func (m Metadata) handle() VertexHandle {
+	return handleOf(m.Index)
+}
+
+type vertexFlags uint8
+
+const (
+	vertexPresent  vertexFlags = 1 << iota // the vertex is part of the graph
+	vertexReverted                         // see Metadata.Reverted
+	vertexHasID                            // the vertex has been given its id
+)
+
+// vertex is the arena record of a vertex. Addresses, labels and values are
+// kept in tables of the graph, see Metadata for the other fields.
+type vertex struct {
+	tx, frame     int32
+	txFrame, step int32  // frame and step of the id
+	addr, label   uint32 // positions in the address and label tables
+	pc            uint32
+	value         uint32 // position in the value table plus one, 0 if none
+	lastIn        uint32 // position of the last edge into the vertex plus one, 0 if none
+	depth         uint16
+	op            OpCode
+	flags         vertexFlags
+	block, gas    uint64
+}
+
+// edge is the arena record of an edge. The edges into a vertex are chained,
+// the last one first.
+type edge struct {
+	source, target VertexHandle
+	next           uint32 // position of the previous edge into the target plus one, 0 if none
+	addr, slot     uint32 // positions in the address and slot tables
+	operand        uint16
+	kind           EdgeKind
+	offset, size   uint64
+}
+
+// stackConstant is a PUSH constant of value-flow mode, on the meta stack.
+type stackConstant struct {
+	source VertexHandle // vertex the PUSH depends on
+	pc     uint32
+	value  uint256.Int
+}
+
+// operandConstant is a constant popped by a vertex.
+type operandConstant struct {
+	operand  uint16
+	constant uint32 // position in the constant table
+}
+
+// adjacency lists the edges out of every vertex, by source then target, in
+// compressed sparse row form. It is built when the edges are walked and
+// thrown away as soon as the graph changes.
+type adjacency struct {
+	start []uint32 // start[slot] is the position of the first edge out of the slot
+	edges []uint32 // positions in the edge arena
+}
+
+// newArena sets up the tables of an empty graph. The first entries of the
+// address and slot tables are the zero values.
+func (g *DependencyGraph) newArena() {
+	for i := range g.sources {
+		g.sources[i].flags = vertexPresent
+	}
+	g.addrs = []common.Address{{}}
+	g.addrIndex = map[common.Address]uint32{{}: 0}
+	g.slots = []common.Hash{{}}
+	g.slotIndex = map[common.Hash]uint32{{}: 0}
+	g.labels = []string{""}
+	g.labelIndex = map[string]uint32{"": 0}
+	g.count = numSources
+}
+
+// internAddr returns the position of addr in the address table. Consecutive
+// instructions mostly run the same code, the last address is looked up first.
+func (g *DependencyGraph) internAddr(addr common.Address) uint32 {
+	if addr == g.addrs[g.lastAddr] {
+		return g.lastAddr
+	}
+	i, ok := g.addrIndex[addr]
+	if !ok {
+		i = uint32(len(g.addrs))
+		g.addrs = append(g.addrs, addr)
+		g.addrIndex[addr] = i
+	}
+	g.lastAddr = i
+	return i
+}
+
+// internSlot returns the position of slot in the slot table.
+func (g *DependencyGraph) internSlot(slot common.Hash) uint32 {
+	if slot == (common.Hash{}) {
+		return 0
+	}
+	i, ok := g.slotIndex[slot]
+	if !ok {
+		i = uint32(len(g.slots))
+		g.slots = append(g.slots, slot)
+		g.slotIndex[slot] = i
+	}
+	return i
+}
+
+// internLabel returns the position of a vertex label that is no opcode name in
+// the label table, the instructions having label 0.
+func (g *DependencyGraph) internLabel(label string) uint32 {
+	if op := StringToOp(label); op.String() == label {
+		return 0
+	}
+	i, ok := g.labelIndex[label]
+	if !ok {
+		i = uint32(len(g.labels))
+		g.labels = append(g.labels, label)
+		g.labelIndex[label] = i
+	}
+	return i
+}
+
+// lookup returns the record of the handle, or nil if it is out of the arena.
+func (g *DependencyGraph) lookup(h VertexHandle) *vertex {
+	if int(h) < numSources {
+		return &g.sources[h]
+	}
+	if h < g.base || int(h-g.base) >= len(g.vertices) {
+		return nil
+	}
+	return &g.vertices[h-g.base]
+}
+
+// record returns the record of the handle, growing the arena to hold it.
+func (g *DependencyGraph) record(h VertexHandle) *vertex {
+	if int(h) < numSources {
+		return &g.sources[h]
+	}
+	if len(g.vertices) == 0 {
+		g.base = h
+	}
+	if h < g.base {
+		// Graphs put together by hand may add vertices in any order
+		grown := make([]vertex, int(g.base-h)+len(g.vertices))
+		copy(grown[g.base-h:], g.vertices)
+		g.vertices, g.base = grown, h
+	}
+	i := int(h - g.base)
+	for len(g.vertices) <= i {
+		g.vertices = append(g.vertices, vertex{})
+	}
+	return &g.vertices[i]
+}
+
+// present returns the record of the handle if the vertex is part of the graph.
+func (g *DependencyGraph) present(h VertexHandle) (*vertex, bool) {
+	v := g.lookup(h)
+	if v == nil || v.flags&vertexPresent == 0 {
+		return nil, false
+	}
+	return v, true
+}
+
+// newVertex puts an instruction in the arena. It only becomes part of the
+// graph once an edge touches it, or addVertex is called.
+func (g *DependencyGraph) newVertex(txId, index int, addr common.Address, pc uint64, op OpCode) VertexHandle {
+	h := handleOf(index)
+	v := g.record(h)
+	*v = vertex{tx: int32(txId), addr: g.internAddr(addr), pc: uint32(pc), op: op, lastIn: v.lastIn, flags: v.flags}
+	return h
+}
+
+// newConstant puts a PUSH constant of value-flow mode in the arena.
+func (g *DependencyGraph) newConstant(source VertexHandle, pc uint64, value *uint256.Int) VertexHandle {
+	g.constants = append(g.constants, stackConstant{source: source, pc: uint32(pc), value: *value})
+	return VertexHandle(len(g.constants)-1) | constantBit
+}
+
+// addVertex makes the vertex part of the graph.
+func (g *DependencyGraph) addVertex(h VertexHandle) *vertex {
+	v := g.record(h)
+	if v.flags&vertexPresent == 0 {
+		v.flags |= vertexPresent
+		g.count++
+	}
+	return v
+}
+
+// setValue records the value pushed by the vertex.
+func (g *DependencyGraph) setValue(v *vertex, value *uint256.Int) {
+	g.values = append(g.values, *value)
+	v.value = uint32(len(g.values))
+}
+
+// metadata puts the Metadata of a vertex together.
+func (g *DependencyGraph) metadata(h VertexHandle, v *vertex) Metadata {
+	if int(h) < numSources {
+		return sourceVertex(SourceKind(numSources - 1 - int(h)))
+	}
+	m := Metadata{
+		TxId:     int(v.tx),
+		Index:    h.Index(),
+		Addr:     g.addrs[v.addr],
+		Pc:       uint64(v.pc),
+		OpCode:   v.op.String(),
+		Reverted: v.flags&vertexReverted != 0,
+		Depth:    int(v.depth),
+		Frame:    int(v.frame),
+		Gas:      v.gas,
+	}
+	if v.label != 0 {
+		m.OpCode = g.labels[v.label]
+	}
+	if v.flags&vertexHasID != 0 {
+		m.ID = VertexID{Block: v.block, Tx: int(v.tx), Frame: int(v.txFrame), Step: int(v.step)}
+	}
+	if v.value != 0 {
+		m.Value = new(uint256.Int).Set(&g.values[v.value-1])
+	}
+	return m
+}
+
+// store puts the given Metadata in the record of its vertex. The edges into
+// the vertex are kept.
+func (g *DependencyGraph) store(meta Metadata) *vertex {
+	v := g.addVertex(meta.handle())
+	*v = vertex{
+		tx:      int32(meta.TxId),
+		frame:   int32(meta.Frame),
+		txFrame: int32(meta.ID.Frame),
+		step:    int32(meta.ID.Step),
+		addr:    g.internAddr(meta.Addr),
+		label:   g.internLabel(meta.OpCode),
+		pc:      uint32(meta.Pc),
+		lastIn:  v.lastIn,
+		depth:   uint16(meta.Depth),
+		op:      StringToOp(meta.OpCode),
+		flags:   vertexPresent,
+		block:   meta.ID.Block,
+		gas:     meta.Gas,
+	}
+	if meta.Reverted {
+		v.flags |= vertexReverted
+	}
+	if meta.ID != (VertexID{}) {
+		v.flags |= vertexHasID
+	}
+	if meta.Value != nil {
+		g.setValue(v, meta.Value)
+	}
+	return v
+}
+
+// slot returns the position of the handle among the sources and the vertices
+// of the arena.
+func (g *DependencyGraph) slot(h VertexHandle) int {
+	if int(h) < numSources {
+		return int(h)
+	}
+	return numSources + int(h-g.base)
+}
+
+// handleAt is the inverse of slot.
+func (g *DependencyGraph) handleAt(slot int) VertexHandle {
+	if slot < numSources {
+		return VertexHandle(slot)
+	}
+	return g.base + VertexHandle(slot-numSources)
+}
+
+// adjacency returns the edges out of every vertex, building them if the graph
+// changed since they were last asked for.
+func (g *DependencyGraph) adjacency() *adjacency {
+	if g.out != nil {
+		return g.out
+	}
+	slots := numSources + len(g.vertices)
+	out := &adjacency{start: make([]uint32, slots+1)}
+	for i := range g.edges {
+		out.start[g.slot(g.edges[i].source)+1]++
+	}
+	for i := 1; i <= slots; i++ {
+		out.start[i] += out.start[i-1]
+	}
+	out.edges = make([]uint32, out.start[slots])
+	fill := append([]uint32(nil), out.start[:slots]...)
+	for i := range g.edges {
+		s := g.slot(g.edges[i].source)
+		out.edges[fill[s]] = uint32(i)
+		fill[s]++
+	}
+	// The edges of a source are in recording order, bring those to the same
+	// target together
+	for s := 0; s < slots; s++ {
+		edges := out.edges[out.start[s]:out.start[s+1]]
+		for i := 1; i < len(edges); i++ {
+			if g.edges[edges[i]].target < g.edges[edges[i-1]].target {
+				sort.SliceStable(edges, func(i, j int) bool {
+					return g.edges[edges[i]].target < g.edges[edges[j]].target
+				})
+				break
+			}
+		}
+	}
+	g.out = out
+	return out
+}
+
+// toEdge puts the Edge of an edge record together.
+func (g *DependencyGraph) toEdge(e *edge) Edge {
+	return Edge{
+		Kind:    e.kind,
+		Operand: int(e.operand),
+		Address: g.addrs[e.addr],
+		Slot:    g.slots[e.slot],
+		Offset:  e.offset,
+		Size:    e.size,
+	}
+}
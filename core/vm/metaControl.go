@@ -0,0 +1,230 @@
+package vm
+
+import (
+	"math"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// noPostDom is the immediate post-dominator of a branch whose paths only meet
+// again when the frame exits.
+const noPostDom = math.MaxUint64
+
+// controlDep is a branch whose region the frame is executing in. The region
+// ends once the immediate post-dominator of the branch is reached.
+type controlDep struct {
+	branch VertexHandle
+	pc     uint64
+	ipdom  uint64
+}
+
+// reachControl pops the branches whose region ends at pc. It is called before
+// every step of a frame.
+func (s *ScopeContext) reachControl(pc uint64) {
+	for n := len(s.controlDeps); n > 0 && s.controlDeps[n-1].ipdom == pc; n-- {
+		s.controlDeps = s.controlDeps[:n-1]
+	}
+}
+
+// linkControl is called after every step of a frame. It makes the vertex the
+// step produced, if any, control dependent on the innermost open branch, and
+// opens a new region if the step was a JUMPI.
+func (in *EVMInterpreter) linkControl(scope *ScopeContext, op OpCode, pc uint64, index int) {
+	vertex := handleOf(index)
+	if _, ok := in.evm.Graph.present(vertex); !ok {
+		return
+	}
+	if n := len(scope.controlDeps); n > 0 {
+		in.evm.Graph.addEdge(scope.controlDeps[n-1].branch, vertex, Edge{Kind: ControlEdge})
+	}
+	if op != JUMPI {
+		return
+	}
+	if scope.postDoms == nil {
+		scope.postDoms = in.postDominators(scope.Contract)
+	}
+	ipdom, ok := scope.postDoms[pc]
+	if !ok {
+		ipdom = noPostDom
+	}
+	// A loop runs into its own branch again before leaving the region, the
+	// new instance takes the place of the old one.
+	if n := len(scope.controlDeps); n > 0 && scope.controlDeps[n-1].pc == pc {
+		scope.controlDeps = scope.controlDeps[:n-1]
+	}
+	scope.controlDeps = append(scope.controlDeps, controlDep{branch: vertex, pc: pc, ipdom: ipdom})
+}
+
+// postDominators returns the immediate post-dominators of the JUMPIs of the
+// contract code, cached by code hash.
+func (in *EVMInterpreter) postDominators(contract *Contract) map[uint64]uint64 {
+	if contract.CodeHash == (common.Hash{}) {
+		// Init code has no hash, don't bother caching it
+		return postDominators(contract.Code)
+	}
+	if doms, ok := in.postDoms[contract.CodeHash]; ok {
+		return doms
+	}
+	if in.postDoms == nil {
+		in.postDoms = make(map[common.Hash]map[uint64]uint64)
+	}
+	doms := postDominators(contract.Code)
+	in.postDoms[contract.CodeHash] = doms
+	return doms
+}
+
+// cfgBlock is a basic block of the control flow graph of a contract.
+type cfgBlock struct {
+	start uint64 // pc of the first instruction
+	last  uint64 // pc of the last instruction
+	succs []int  // successor blocks, the exit being len(blocks)
+}
+
+// postDominators computes the immediate post-dominator of every JUMPI in code,
+// as the pc of the first instruction of the post-dominating block. Branches
+// only joining at the exit of the frame are left out.
+//
+// Jump targets are resolved when the destination is pushed right before the
+// jump, which is how compilers emit them. Any other jump is taken to leave
+// the frame, so the result stays sound: the region of a branch may end later
+// than it would with a complete graph, but never earlier.
+func postDominators(code []byte) map[uint64]uint64 {
+	// Split the code into basic blocks
+	leaders := map[uint64]bool{0: true}
+	for pc := uint64(0); pc < uint64(len(code)); pc++ {
+		op := OpCode(code[pc])
+		if op == JUMPDEST {
+			leaders[pc] = true
+		}
+		if op.IsPush() {
+			pc += uint64(op - PUSH0)
+		}
+		switch op {
+		case JUMP, JUMPI, STOP, RETURN, REVERT, INVALID, SELFDESTRUCT:
+			leaders[pc+1] = true
+		}
+	}
+	var (
+		blocks  []cfgBlock
+		index   = make(map[uint64]int)
+		targets []*uint256.Int // jump destination of every block, if known
+	)
+	for pc, prev := uint64(0), (*uint256.Int)(nil); pc < uint64(len(code)); pc++ {
+		if leaders[pc] {
+			index[pc] = len(blocks)
+			blocks = append(blocks, cfgBlock{start: pc})
+			targets = append(targets, nil)
+			prev = nil
+		}
+		op := OpCode(code[pc])
+		blocks[len(blocks)-1].last = pc
+		if op == JUMP || op == JUMPI {
+			targets[len(targets)-1] = prev
+		}
+		prev = nil
+		if op == PUSH0 {
+			prev = new(uint256.Int)
+		}
+		if op.IsPush() {
+			size := uint64(op - PUSH0)
+			prev = new(uint256.Int).SetBytes(getData(code, pc+1, size))
+			pc += size
+		}
+	}
+	exit := len(blocks)
+	for i := range blocks {
+		var (
+			b    = &blocks[i]
+			op   = OpCode(code[b.last])
+			next = exit
+		)
+		if i+1 < len(blocks) {
+			next = i + 1
+		}
+		jump := exit
+		if t := targets[i]; t != nil && t.IsUint64() {
+			if j, ok := index[t.Uint64()]; ok && OpCode(code[t.Uint64()]) == JUMPDEST {
+				jump = j
+			}
+		}
+		switch op {
+		case JUMP:
+			b.succs = []int{jump}
+		case JUMPI:
+			b.succs = []int{next, jump}
+		case STOP, RETURN, REVERT, INVALID, SELFDESTRUCT:
+			b.succs = []int{exit}
+		default:
+			b.succs = []int{next}
+		}
+	}
+	// Post-dominators are the dominators of the reversed graph rooted at the
+	// exit, computed as in "A Simple, Fast Dominance Algorithm" (Cooper et al).
+	preds := make([][]int, exit+1)
+	for i, b := range blocks {
+		for _, s := range b.succs {
+			preds[s] = append(preds[s], i)
+		}
+	}
+	var (
+		order   = make([]int, exit+1) // postorder number, 0 for unvisited
+		visited []int                 // nodes in postorder
+		dfs     func(n int)
+	)
+	dfs = func(n int) {
+		order[n] = -1
+		for _, p := range preds[n] {
+			if order[p] == 0 {
+				dfs(p)
+			}
+		}
+		visited = append(visited, n)
+		order[n] = len(visited)
+	}
+	dfs(exit)
+
+	ipdom := make([]int, exit+1)
+	for i := range ipdom {
+		ipdom[i] = -1
+	}
+	ipdom[exit] = exit
+	intersect := func(a, b int) int {
+		for a != b {
+			for order[a] < order[b] {
+				a = ipdom[a]
+			}
+			for order[b] < order[a] {
+				b = ipdom[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for i := len(visited) - 2; i >= 0; i-- {
+			n, dom := visited[i], -1
+			for _, s := range blocks[n].succs {
+				if ipdom[s] == -1 {
+					continue
+				}
+				if dom == -1 {
+					dom = s
+				} else {
+					dom = intersect(s, dom)
+				}
+			}
+			if ipdom[n] != dom {
+				ipdom[n], changed = dom, true
+			}
+		}
+	}
+	doms := make(map[uint64]uint64)
+	for i, b := range blocks {
+		if OpCode(code[b.last]) != JUMPI || ipdom[i] == -1 || ipdom[i] == exit {
+			continue
+		}
+		doms[b.last] = blocks[ipdom[i]].start
+	}
+	return doms
+}
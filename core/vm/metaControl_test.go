@@ -0,0 +1,58 @@
+package vm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPostDominators(t *testing.T) {
+	for i, tc := range []struct {
+		code []byte
+		want map[uint64]uint64
+	}{
+		{ // if-then joining at a JUMPDEST
+			[]byte{
+				byte(CALLDATASIZE), byte(PUSH1), 0x07, byte(JUMPI),
+				byte(PUSH1), 0x02, byte(POP),
+				byte(JUMPDEST), byte(STOP),
+			},
+			map[uint64]uint64{3: 7},
+		},
+		{ // if-then-else joining at a JUMPDEST
+			[]byte{
+				byte(CALLDATASIZE), byte(PUSH1), 0x09, byte(JUMPI),
+				byte(PUSH1), 0x0b, byte(JUMP), byte(INVALID), byte(INVALID),
+				byte(JUMPDEST), byte(PUSH0),
+				byte(JUMPDEST), byte(STOP),
+			},
+			map[uint64]uint64{3: 11},
+		},
+		{ // loop, the branch is left at the instruction after it
+			[]byte{
+				byte(JUMPDEST), byte(CALLDATASIZE), byte(PUSH0), byte(JUMPI),
+				byte(PUSH1), 0x03, byte(POP), byte(STOP),
+			},
+			map[uint64]uint64{3: 4},
+		},
+		{ // paths only meet at the exit
+			[]byte{
+				byte(CALLDATASIZE), byte(PUSH1), 0x06, byte(JUMPI),
+				byte(PUSH0), byte(STOP),
+				byte(JUMPDEST), byte(STOP),
+			},
+			map[uint64]uint64{},
+		},
+		{ // unresolved jump target, taken to leave the frame
+			[]byte{
+				byte(CALLDATASIZE), byte(CALLDATASIZE), byte(JUMPI),
+				byte(PUSH1), 0x07, byte(JUMP),
+				byte(JUMPDEST), byte(JUMPDEST), byte(STOP),
+			},
+			map[uint64]uint64{},
+		},
+	} {
+		if have := postDominators(tc.code); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("test %d: have %v, want %v", i, have, tc.want)
+		}
+	}
+}
@@ -1,39 +1,54 @@
 package vm
 
 import (
+	"math"
+	"sort"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
 )
 
 // Try to support inter-transaction concurrency
 // now we could only considering the intra-transaction concurrency
 type Metadata struct {
-	TxId  int `json:"-" `    // used for inter-transaction concurrency
-	Index int `json:"index"` // used for intra-transaction concurrency
+	TxId  int `json:"tx"`    // used for inter-transaction concurrency
+	Index int `json:"index"` // position of the vertex in its graph, see ID
+
+	// ID identifies the vertex across graphs. Like Depth, it is only set on
+	// the graph vertices once the instruction has been executed.
+	ID VertexID `json:"id"`
 
 	// Addr, Pc, OpCode are extra information for the concrete instruction
-	Addr   common.Address `json:"-"`
+	Addr   common.Address `json:"address"`
 	Pc     uint64         `json:"pc"`
 	OpCode string         `json:"opcode"`
-}
 
-var SourceMeta = Metadata{
-	Index: -1,
-}
+	// Reverted is set on the graph vertices of instructions whose frame
+	// was reverted afterwards
+	Reverted bool `json:"reverted,omitempty"`
+
+	// Constant is set on the PUSH constants of value-flow mode, which are
+	// no vertices. Their Index is the one of the vertex they depend on.
+	Constant bool `json:"constant,omitempty"`
 
-func NewMetadata(txId, index int, addr common.Address, pc uint64, opcode OpCode) *Metadata {
-	return &Metadata{TxId: txId, Index: index, Addr: addr, Pc: pc, OpCode: opcode.String()}
+	// Depth, Frame, Gas and Value are only set on the graph vertices, once
+	// the instruction has been executed, and Value on constants
+	Depth int          `json:"depth"`           // call depth of the frame
+	Frame int          `json:"frame"`           // id of the frame, unique within the EVM
+	Gas   uint64       `json:"gas"`             // gas charged for the instruction
+	Value *uint256.Int `json:"value,omitempty"` // value pushed by the instruction, if any
 }
 
 var metaStackPool = sync.Pool{
 	New: func() interface{} {
-		return &MetaStack{data: make([]Metadata, 0, 16)}
+		return &MetaStack{data: make([]VertexHandle, 0, 16)}
 	},
 }
 
+// MetaStack is the shadow of the stack, holding the producer of every item.
 type MetaStack struct {
-	data []Metadata
+	data []VertexHandle
 }
 
 func newMetaStack() *MetaStack {
@@ -42,20 +57,20 @@ func newMetaStack() *MetaStack {
 
 func returnMetaStack(s *MetaStack) {
 	s.data = s.data[:0]
-	stackPool.Put(s)
+	metaStackPool.Put(s)
 }
 
-// Data returns the underlying Metadata array.
-func (st *MetaStack) Data() []Metadata {
+// Data returns the underlying handle array.
+func (st *MetaStack) Data() []VertexHandle {
 	return st.data
 }
 
-func (st *MetaStack) push(d *Metadata) {
+func (st *MetaStack) push(d VertexHandle) {
 	// NOTE push limit (1024) is checked in baseCheck
-	st.data = append(st.data, *d)
+	st.data = append(st.data, d)
 }
 
-func (st *MetaStack) pop() (ret Metadata) {
+func (st *MetaStack) pop() (ret VertexHandle) {
 	ret = st.data[len(st.data)-1]
 	st.data = st.data[:len(st.data)-1]
 	return
@@ -70,20 +85,31 @@ func (st *MetaStack) swap(n int) {
 }
 
 func (st *MetaStack) dup(n int) {
-	st.push(&st.data[st.len()-n])
+	st.push(st.data[st.len()-n])
 }
 
-func (st *MetaStack) peek() *Metadata {
-	return &st.data[st.len()-1]
+func (st *MetaStack) peek() VertexHandle {
+	return st.data[st.len()-1]
 }
 
 // Back returns the n'th item in stack
-func (st *MetaStack) Back(n int) *Metadata {
-	return &st.data[st.len()-n-1]
+func (st *MetaStack) Back(n int) VertexHandle {
+	return st.data[st.len()-n-1]
 }
 
+// metaSpan records that every byte of [start, end) was last written by meta.
+type metaSpan struct {
+	start, end uint64
+	meta       VertexHandle
+}
+
+// MetaMemory is the shadow of the memory. Instead of one handle per byte it
+// keeps the writers as sorted, non-overlapping spans, so that a 32-byte write
+// costs one span rather than 32 copies. Bytes not covered by any span have
+// never been written.
 type MetaMemory struct {
-	store []Metadata
+	spans []metaSpan
+	size  uint64
 }
 
 // NewMemory returns a new memory model.
@@ -92,68 +118,112 @@ func newMetaMemory() *MetaMemory {
 }
 
 // Set sets offset + size to value
-func (m *MetaMemory) Set(offset, size uint64, value Metadata) {
+func (m *MetaMemory) Set(offset, size uint64, value VertexHandle) {
 	// It's possible the offset is greater than 0 and size equals 0. This is because
 	// the calcMemSize (common.go) could potentially return 0 when size is zero (NO-OP)
 	if size > 0 {
 		// length of store may never be less than offset + size.
 		// The store should be resized PRIOR to setting the memory
-		if offset+size > uint64(len(m.store)) {
+		if offset+size > m.size {
 			panic("invalid memory: store empty")
 		}
-		for i := uint64(0); i < size; i++ {
-			m.store[offset+i] = value
-		}
+		m.splice(offset, offset+size, []metaSpan{{offset, offset + size, value}})
 	}
 }
 
 // Set32 sets the 32 bytes starting at offset to the value of val, left-padded with zeroes to
 // 32 bytes.
-func (m *MetaMemory) Set32(offset uint64, value Metadata) {
+func (m *MetaMemory) Set32(offset uint64, value VertexHandle) {
 	// length of store may never be less than offset + size.
 	// The store should be resized PRIOR to setting the memory
-	if offset+32 > uint64(len(m.store)) {
+	if offset+32 > m.size {
 		panic("invalid memory: store empty")
 	}
-	// Fill in relevant bits
-	for i := uint64(0); i < 32; i++ {
-		m.store[offset+i] = value
+	m.splice(offset, offset+32, []metaSpan{{offset, offset + 32, value}})
+}
+
+// SetRange sets offset + size to the provenance held by value. Like
+// Memory.Set, only the bytes covered by value are overwritten.
+func (m *MetaMemory) SetRange(offset, size uint64, value *MetaMemory) {
+	if size > 0 {
+		// length of store may never be less than offset + size.
+		// The store should be resized PRIOR to setting the memory
+		if offset+size > m.size {
+			panic("invalid memory: store empty")
+		}
+		if value == nil {
+			return
+		}
+		if value.size < size {
+			size = value.size
+		}
+		// Bytes the value has no writer for become unwritten as well
+		m.splice(offset, offset+size, nil)
+		for _, span := range value.spans {
+			if span.start >= size {
+				break
+			}
+			end := span.end
+			if end > size {
+				end = size
+			}
+			m.splice(offset+span.start, offset+end, []metaSpan{{offset + span.start, offset + end, span.meta}})
+		}
 	}
 }
 
 // Resize resizes the memory to size
 func (m *MetaMemory) Resize(size uint64) {
-	if uint64(m.Len()) < size {
-		m.store = append(m.store, make([]Metadata, size-uint64(m.Len()))...)
+	if m.size < size {
+		m.size = size
 	}
 }
 
-// GetCopy returns offset + size as a new slice == GetPtr
-func (m *MetaMemory) GetCopy(offset, size int64) Metadata {
-	return m.GetPtr(offset, size)
+// GetWriters returns the distinct writers of offset + size, in the order they
+// first appear in the range. Unwritten bytes have no writer.
+func (m *MetaMemory) GetWriters(offset, size uint64) []VertexHandle {
+	if m == nil || size == 0 {
+		return nil
+	}
+	end := offset + size
+	if end < offset {
+		end = math.MaxUint64
+	}
+	var writers []VertexHandle
+	for i := m.search(offset); i < len(m.spans) && m.spans[i].start < end; i++ {
+		if !containsHandle(writers, m.spans[i].meta) {
+			writers = append(writers, m.spans[i].meta)
+		}
+	}
+	return writers
 }
 
-// GetPtr returns the offset + size
-func (m *MetaMemory) GetPtr(offset, size int64) Metadata {
+// GetRange returns the provenance of offset + size rebased to zero, it is
+// what travels along with the bytes of call arguments and return data.
+func (m *MetaMemory) GetRange(offset, size uint64) *MetaMemory {
+	cpy := &MetaMemory{size: size}
 	if size == 0 {
-		return Metadata{}
+		return cpy
 	}
-
-	if len(m.store) > int(offset) {
-		return m.store[offset]
+	for i := m.search(offset); i < len(m.spans) && m.spans[i].start < offset+size; i++ {
+		span := m.spans[i]
+		if span.start < offset {
+			span.start = offset
+		}
+		if span.end > offset+size {
+			span.end = offset + size
+		}
+		cpy.spans = append(cpy.spans, metaSpan{span.start - offset, span.end - offset, span.meta})
 	}
-
-	return Metadata{}
+	return cpy
 }
 
 // Len returns the length of the backing slice
 func (m *MetaMemory) Len() int {
-	return len(m.store)
-}
-
-// Data returns the backing slice
-func (m *MetaMemory) Data() []Metadata {
-	return m.store
+	if m == nil {
+		return 0
+	}
+	return int(m.size)
 }
 
 // Copy copies data from the src position slice into the dst position.
@@ -164,52 +234,130 @@ func (m *MetaMemory) Copy(dst, src, len uint64) {
 	if len == 0 {
 		return
 	}
-	copy(m.store[dst:], m.store[src:src+len])
+	m.SetRange(dst, len, m.GetRange(src, len))
+}
+
+// search returns the index of the first span ending after offset.
+func (m *MetaMemory) search(offset uint64) int {
+	return sort.Search(len(m.spans), func(i int) bool {
+		return m.spans[i].end > offset
+	})
+}
+
+// splice replaces everything recorded for [start, end) with the given spans,
+// keeping the parts of the overlapped spans that lie outside of the range.
+func (m *MetaMemory) splice(start, end uint64, spans []metaSpan) {
+	first := m.search(start)
+	last := first
+	for last < len(m.spans) && m.spans[last].start < end {
+		last++
+	}
+	if first < last {
+		if head := m.spans[first]; head.start < start {
+			head.end = start
+			spans = append([]metaSpan{head}, spans...)
+		}
+		if tail := m.spans[last-1]; tail.end > end {
+			tail.start = end
+			spans = append(spans, tail)
+		}
+	}
+	m.spans = append(m.spans[:first], append(spans, m.spans[last:]...)...)
+}
+
+// containsHandle reports whether h is in list.
+func containsHandle(list []VertexHandle, h VertexHandle) bool {
+	for _, item := range list {
+		if item == h {
+			return true
+		}
+	}
+	return false
 }
 
 // this structure is the shadow storage implementation
 // only serving for SLOAD and SSTORE
 // could possibly facilitate inter-transaction concurrency
-type EachStorage map[common.Hash]Metadata
+type EachStorage map[common.Hash]VertexHandle
 
 type MetaStorage struct {
-	store map[common.Address]EachStorage
+	store   map[common.Address]EachStorage
+	journal *metaJournal
 }
 
-func newMetaStorage() *MetaStorage {
-	return &MetaStorage{store: make(map[common.Address]EachStorage)}
+func newMetaStorage(journal *metaJournal) *MetaStorage {
+	return &MetaStorage{store: make(map[common.Address]EachStorage), journal: journal}
 }
 
-func (s *MetaStorage) Get(addr common.Address, key common.Hash) Metadata {
-	if _, ok := s.store[addr]; !ok {
-		return SourceMeta
+// Get returns the writer of the given slot, or StorageSourceMeta if it has not
+// been written.
+func (s *MetaStorage) Get(addr common.Address, key common.Hash) VertexHandle {
+	if v, ok := s.store[addr][key]; ok {
+		return v
 	}
-	return s.store[addr][key]
+	return StorageSourceMeta.handle()
 }
 
-func (s *MetaStorage) Set(addr common.Address, key common.Hash, value Metadata) {
+func (s *MetaStorage) Set(addr common.Address, key common.Hash, value VertexHandle) {
 	if _, ok := s.store[addr]; !ok {
 		s.store[addr] = make(EachStorage)
 	}
+	prev, prevSet := s.store[addr][key]
+	s.journal.append(metaStorageChange{store: s.store, addr: addr, key: key, prev: prev, prevSet: prevSet})
 	s.store[addr][key] = value
 }
 
 type MetaAccount struct {
-	store map[common.Address]Metadata
+	store   map[common.Address]VertexHandle
+	source  VertexHandle // writer of the accounts not written yet
+	journal *metaJournal
 }
 
-func newMetaAccount() *MetaAccount {
-	return &MetaAccount{store: make(map[common.Address]Metadata)}
+func newMetaAccount(journal *metaJournal, source Metadata) *MetaAccount {
+	return &MetaAccount{store: make(map[common.Address]VertexHandle), source: source.handle(), journal: journal}
 }
 
-func (s *MetaAccount) Get(addr common.Address) Metadata {
+func (s *MetaAccount) Get(addr common.Address) VertexHandle {
 	if v, ok := s.store[addr]; !ok {
-		return SourceMeta
+		return s.source
 	} else {
 		return v
 	}
 }
 
-func (s *MetaAccount) Set(addr common.Address, value Metadata) {
+func (s *MetaAccount) Set(addr common.Address, value VertexHandle) {
+	prev, prevSet := s.store[addr]
+	s.journal.append(metaAccountChange{store: s.store, addr: addr, prev: prev, prevSet: prevSet})
 	s.store[addr] = value
 }
+
+// MetaTransientStorage is the shadow of the EIP-1153 transient storage, only
+// serving for TLOAD and TSTORE. It is kept apart from MetaStorage since its
+// content is discarded at the end of the transaction.
+type MetaTransientStorage struct {
+	store   map[common.Address]EachStorage
+	journal *metaJournal
+}
+
+func newMetaTransientStorage(journal *metaJournal) *MetaTransientStorage {
+	return &MetaTransientStorage{store: make(map[common.Address]EachStorage), journal: journal}
+}
+
+// Get returns the writer of the given transient slot, or TxInputSourceMeta if
+// it has not been written within the transaction: transient storage starts out
+// empty with every transaction.
+func (s *MetaTransientStorage) Get(addr common.Address, key common.Hash) VertexHandle {
+	if v, ok := s.store[addr][key]; ok {
+		return v
+	}
+	return TxInputSourceMeta.handle()
+}
+
+func (s *MetaTransientStorage) Set(addr common.Address, key common.Hash, value VertexHandle) {
+	if _, ok := s.store[addr]; !ok {
+		s.store[addr] = make(EachStorage)
+	}
+	prev, prevSet := s.store[addr][key]
+	s.journal.append(metaStorageChange{store: s.store, addr: addr, key: key, prev: prev, prevSet: prevSet})
+	s.store[addr][key] = value
+}
@@ -0,0 +1,76 @@
+package vm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMetaMemoryWriters(t *testing.T) {
+	var (
+		a = handleOf(1)
+		b = handleOf(2)
+		c = handleOf(3)
+	)
+	m := newMetaMemory()
+	m.Resize(96)
+	m.Set32(0, a)
+	m.Set32(32, a)
+	m.Set(40, 1, b)
+	m.Set(60, 8, c)
+
+	for i, tc := range []struct {
+		offset, size uint64
+		want         []VertexHandle
+	}{
+		{0, 0, nil},
+		{0, 32, []VertexHandle{a}},
+		{0, 64, []VertexHandle{a, b, c}},
+		{41, 19, []VertexHandle{a}},
+		{40, 1, []VertexHandle{b}},
+		{64, 32, []VertexHandle{c}},
+		{68, 28, nil},
+		{90, ^uint64(0), nil},
+	} {
+		if have := m.GetWriters(tc.offset, tc.size); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("test %d: writers of [%d, +%d): have %v, want %v", i, tc.offset, tc.size, have, tc.want)
+		}
+	}
+	// Overwriting the middle of a span keeps both of its ends
+	if len(m.spans) != 5 {
+		t.Errorf("span count mismatch: have %d, want 5", len(m.spans))
+	}
+}
+
+func TestMetaMemoryCopy(t *testing.T) {
+	var (
+		a = handleOf(1)
+		b = handleOf(2)
+	)
+	m := newMetaMemory()
+	m.Resize(64)
+	m.Set(0, 8, a)
+	m.Set(16, 16, b)
+
+	// Overlapping copy, the unwritten bytes travel along as well
+	m.Copy(4, 0, 16)
+	for i, tc := range []struct {
+		offset, size uint64
+		want         []VertexHandle
+	}{
+		{0, 12, []VertexHandle{a}},
+		{12, 8, nil},
+		{20, 12, []VertexHandle{b}},
+	} {
+		if have := m.GetWriters(tc.offset, tc.size); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("test %d: writers of [%d, +%d): have %v, want %v", i, tc.offset, tc.size, have, tc.want)
+		}
+	}
+	// Ranges are rebased to zero when handed to another frame
+	r := m.GetRange(8, 16)
+	if have, want := r.GetWriters(0, 8), []VertexHandle{a}; !reflect.DeepEqual(have, want) {
+		t.Errorf("rebased range: have %v, want %v", have, want)
+	}
+	if have, want := r.GetWriters(4, 12), []VertexHandle{b}; !reflect.DeepEqual(have, want) {
+		t.Errorf("rebased range: have %v, want %v", have, want)
+	}
+}
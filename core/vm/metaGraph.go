@@ -1,36 +1,569 @@
 package vm
 
+import (
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
+)
+
+// EdgeKind tells which shared resource the target of an edge reads the
+// output of its source through.
+type EdgeKind uint8
+
+const (
+	StackEdge            EdgeKind = iota // the target pops a value pushed by the source
+	MemoryEdge                           // the target reads memory written by the source
+	StorageEdge                          // the target reads a storage slot written by the source
+	TransientStorageEdge                 // the target reads a transient storage slot written by the source
+	BalanceEdge                          // the target reads a balance changed by the source
+	CodeEdge                             // the target reads code deployed by the source
+	CallEdge                             // the target reads what crossed a frame boundary at the source
+	ContextEdge                          // the target reads the block or transaction environment
+	ControlEdge                          // the target only runs because of the branch taken by the source
+)
+
+var edgeKindNames = [...]string{
+	StackEdge:            "stack",
+	MemoryEdge:           "memory",
+	StorageEdge:          "storage",
+	TransientStorageEdge: "transient",
+	BalanceEdge:          "balance",
+	CodeEdge:             "code",
+	CallEdge:             "call",
+	ContextEdge:          "context",
+	ControlEdge:          "control",
+}
+
+func (k EdgeKind) String() string {
+	if int(k) < len(edgeKindNames) {
+		return edgeKindNames[k]
+	}
+	return "unknown"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k EdgeKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Edge is a single reason for a dependency. Only the attributes of its kind
+// are set.
+type Edge struct {
+	Kind EdgeKind `json:"kind"`
+
+	// Operand is the position of a stack operand, 0 being the top
+	Operand int `json:"operand"`
+
+	// Address and Slot locate the account state of storage, balance and
+	// code edges
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+
+	// Offset and Size are the byte range of memory and call edges. For call
+	// edges it is relative to the call data or return data.
+	Offset uint64 `json:"offset"`
+	Size   uint64 `json:"size"`
+}
+
+// DependencyGraph is the graph of the data flowing between the instructions
+// of an execution. Its vertices are indexed by the order the instructions
+// were executed in, the source vertices having negative indexes. See
+// metaArena.go for how it is laid out.
 type DependencyGraph struct {
-	Vertexes map[int]Metadata
-	Edges    map[int]map[int]struct{}
+	sources  [numSources]vertex
+	vertices []vertex     // vertices[i] has handle base+i
+	base     VertexHandle // handle of the first vertex of the arena
+	count    int          // number of vertices, the sources included
+	edges    []edge
+	out      *adjacency // edges by source, nil until asked for
+
+	// constants are the PUSH constants of value-flow mode, operands the ones
+	// popped by every vertex
+	constants []stackConstant
+	operands  map[VertexHandle][]operandConstant
+
+	addrs      []common.Address
+	addrIndex  map[common.Address]uint32
+	lastAddr   uint32 // position of the last address interned
+	slots      []common.Hash
+	slotIndex  map[common.Hash]uint32
+	labels     []string
+	labelIndex map[string]uint32
+	values     []uint256.Int
+
+	// onDependency is called with the sources of the edges recorded, see
+	// DependencyLogger. The edges recorded while instructions run are held
+	// in pending until they are done, those of the instructions of nested
+	// frames being stacked on top of the ones of the calls.
+	onDependency func(sources []Metadata, target Metadata)
+	batches      int
+	pending      []dependency
+}
+
+// dependency is an edge held until it is reported.
+type dependency struct {
+	source, target VertexHandle
+	reported       bool
 }
 
 func NewDependencyGraph() *DependencyGraph {
-	v := make(map[int]Metadata)
-	v[-1] = SourceMeta
+	g := new(DependencyGraph)
+	g.newArena()
+	return g
+}
+
+// NumVertices returns the number of vertices, the sources included.
+func (g *DependencyGraph) NumVertices() int {
+	return g.count
+}
+
+// NumEdges returns the number of edges.
+func (g *DependencyGraph) NumEdges() int {
+	return len(g.adjacency().edges)
+}
 
-	return &DependencyGraph{
-		Vertexes: v,
-		Edges:    make(map[int]map[int]struct{}), // 类似于邻接矩阵吧
+// Vertex returns the vertex of the given index, if it is part of the graph.
+func (g *DependencyGraph) Vertex(index int) (Metadata, bool) {
+	if index < -numSources {
+		return Metadata{}, false
 	}
+	h := handleOf(index)
+	v, ok := g.present(h)
+	if !ok {
+		return Metadata{}, false
+	}
+	return g.metadata(h, v), true
+}
+
+// AddVertex puts the vertex in the graph, replacing the one of the same index
+// but keeping its edges. The source vertices cannot be replaced.
+func (g *DependencyGraph) AddVertex(meta Metadata) {
+	if meta.Index < 0 {
+		return
+	}
+	g.store(meta)
+	g.out = nil
 }
 
+// AddDependency records that target pops the given stack operands, the first
+// one being the top of the stack.
 func (g *DependencyGraph) AddDependency(sources []Metadata, target Metadata) {
-	// Add vertexes if not exist
-	for _, meta := range sources {
-		if _, ok := g.Vertexes[meta.Index]; !ok {
-			g.Vertexes[meta.Index] = meta
+	g.include(target)
+	for i, meta := range sources {
+		if meta.Constant {
+			g.addConstant(g.newConstant(meta.handle(), meta.Pc, meta.Value), i, target.handle())
+			continue
 		}
+		g.AddEdge(meta, target, Edge{Kind: StackEdge, Operand: i})
 	}
-	if _, ok := g.Vertexes[target.Index]; !ok {
-		g.Vertexes[target.Index] = target
+}
+
+// AddEdge records that target depends on source for the given reason.
+func (g *DependencyGraph) AddEdge(source, target Metadata, edge Edge) {
+	g.include(source)
+	g.include(target)
+	g.addEdge(source.handle(), target.handle(), edge)
+}
+
+// AddControlDependency records that target only runs because of the way the
+// branch went.
+func (g *DependencyGraph) AddControlDependency(branch Metadata, target Metadata) {
+	g.AddEdge(branch, target, Edge{Kind: ControlEdge})
+}
+
+// include puts the vertex in the graph unless it is already part of it.
+func (g *DependencyGraph) include(meta Metadata) {
+	if _, ok := g.present(meta.handle()); !ok && meta.Index >= 0 {
+		g.AddVertex(meta)
+	}
+}
+
+// addDependency is AddDependency on the vertices of the arena.
+func (g *DependencyGraph) addDependency(sources []VertexHandle, target VertexHandle) {
+	g.addVertex(target)
+	for i, h := range sources {
+		if h.isConstant() {
+			g.addConstant(h, i, target)
+			continue
+		}
+		g.addEdge(h, target, Edge{Kind: StackEdge, Operand: i})
+	}
+}
+
+// addEdge is AddEdge on the vertices of the arena. A constant stands for the
+// vertex its PUSH depends on.
+func (g *DependencyGraph) addEdge(source, target VertexHandle, e Edge) {
+	if source.isConstant() {
+		source = g.constantSource(source)
 	}
+	g.addVertex(source)
+	to := g.addVertex(target)
+
+	rec := edge{
+		source:  source,
+		target:  target,
+		kind:    e.Kind,
+		operand: uint16(e.Operand),
+		offset:  e.Offset,
+		size:    e.Size,
+	}
+	if e.Address != (common.Address{}) {
+		rec.addr = g.internAddr(e.Address)
+	}
+	rec.slot = g.internSlot(e.Slot)
+	for i := to.lastIn; i != 0; i = g.edges[i-1].next {
+		if other := &g.edges[i-1]; other.source == source && other.kind == rec.kind &&
+			other.operand == rec.operand && other.addr == rec.addr && other.slot == rec.slot &&
+			other.offset == rec.offset && other.size == rec.size {
+			return
+		}
+	}
+	rec.next = to.lastIn
+	g.edges = append(g.edges, rec)
+	to.lastIn = uint32(len(g.edges))
+	g.out = nil
+
+	switch {
+	case g.onDependency == nil:
+	case g.batches > 0:
+		g.pending = append(g.pending, dependency{source: source, target: target})
+	default:
+		g.onDependency([]Metadata{g.handleMetadata(source)}, g.handleMetadata(target))
+	}
+}
 
-	// Add edges
-	for _, meta := range sources {
-		if _, ok := g.Edges[meta.Index]; !ok {
-			g.Edges[meta.Index] = make(map[int]struct{})
+// batch holds the edges recorded from now on until flush is called with the
+// mark it returns. Batches nest, the edges of the inner ones being reported
+// when they are flushed. The interpreter batches every instruction, so the
+// DependencyLogger gets the edges into its vertex at once when it is done:
+// the operands popped, the writers of the memory, storage or state read, in
+// the order they were read. The edges into other vertices, like the value
+// transfer or precompile run of a call, get calls of their own. The edges of
+// a frame which reverts afterwards are reported too.
+func (g *DependencyGraph) batch() int {
+	if g.onDependency == nil {
+		return 0
+	}
+	g.batches++
+	return len(g.pending)
+}
+
+// flush reports the edges held since the batch of the given mark, with one
+// call per target, in the order the targets first got an edge. The edges are
+// recorded in the graph all the same, the calls only spare the tracers
+// walking it after the execution.
+func (g *DependencyGraph) flush(mark int) {
+	if g.onDependency == nil {
+		return
+	}
+	g.batches--
+	held := g.pending[mark:]
+	for i := range held {
+		if held[i].reported {
+			continue
+		}
+		target := held[i].target
+		var sources []Metadata
+		for j := i; j < len(held); j++ {
+			if dep := &held[j]; dep.target == target {
+				sources = append(sources, g.handleMetadata(dep.source))
+				dep.reported = true
+			}
+		}
+		g.onDependency(sources, g.handleMetadata(target))
+	}
+	g.pending = g.pending[:mark]
+}
+
+// handleMetadata returns the Metadata of the vertex or constant of a handle.
+func (g *DependencyGraph) handleMetadata(h VertexHandle) Metadata {
+	if h.isConstant() {
+		c := &g.constants[h&^constantBit]
+		return Metadata{Index: c.source.Index(), Pc: uint64(c.pc), Constant: true, Value: new(uint256.Int).Set(&c.value)}
+	}
+	v := g.lookup(h)
+	if v == nil {
+		return Metadata{Index: h.Index()}
+	}
+	return g.metadata(h, v)
+}
+
+// addRangeDependency records that target reads offset + size of mem, with an
+// edge from the writer of every written part of the range. The edges carry
+// the part of the range they cover.
+func (g *DependencyGraph) addRangeDependency(kind EdgeKind, mem *MetaMemory, offset, size uint64, target VertexHandle) {
+	if mem == nil || size == 0 {
+		return
+	}
+	end := offset + size
+	if end < offset {
+		end = ^uint64(0)
+	}
+	for i := mem.search(offset); i < len(mem.spans) && mem.spans[i].start < end; i++ {
+		span := mem.spans[i]
+		if span.start < offset {
+			span.start = offset
+		}
+		if span.end > end {
+			span.end = end
 		}
-		g.Edges[meta.Index][target.Index] = struct{}{}
+		g.addEdge(span.meta, target, Edge{Kind: kind, Offset: span.start, Size: span.end - span.start})
 	}
 }
+
+// ForEachVertex calls fn on every vertex, in increasing order of index, until
+// it fails.
+func (g *DependencyGraph) ForEachVertex(fn func(v Metadata) error) error {
+	for slot := 0; slot < numSources+len(g.vertices); slot++ {
+		h := g.handleAt(slot)
+		if v := g.lookup(h); v.flags&vertexPresent != 0 {
+			if err := fn(g.metadata(h, v)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ForEachEdge calls fn on every edge, ordered by source then target, until it
+// fails. The edges between the same vertices come in the order they were
+// recorded in.
+func (g *DependencyGraph) ForEachEdge(fn func(source, target int, e Edge) error) error {
+	for _, i := range g.adjacency().edges {
+		e := &g.edges[i]
+		if err := fn(e.source.Index(), e.target.Index(), g.toEdge(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outEdges returns the positions of the edges out of the vertex, by target.
+func (g *DependencyGraph) outEdges(source int) []uint32 {
+	if source < -numSources {
+		return nil
+	}
+	h := handleOf(source)
+	if g.lookup(h) == nil {
+		return nil
+	}
+	out, slot := g.adjacency(), g.slot(h)
+	if slot+1 >= len(out.start) {
+		return nil
+	}
+	return out.edges[out.start[slot]:out.start[slot+1]]
+}
+
+// Targets returns the vertices depending on the given one, in increasing order.
+func (g *DependencyGraph) Targets(source int) []int {
+	var targets []int
+	for _, i := range g.outEdges(source) {
+		if target := g.edges[i].target.Index(); len(targets) == 0 || targets[len(targets)-1] != target {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// Edges returns the edges from source to target, in the order they were
+// recorded.
+func (g *DependencyGraph) Edges(source, target int) []Edge {
+	var edges []Edge
+	for _, i := range g.outEdges(source) {
+		if e := &g.edges[i]; e.target.Index() == target {
+			edges = append(edges, g.toEdge(e))
+		}
+	}
+	return edges
+}
+
+// Kinds returns the kinds of the edges from source to target, in the order
+// they were recorded.
+func (g *DependencyGraph) Kinds(source, target int) []EdgeKind {
+	var kinds []EdgeKind
+	for _, e := range g.Edges(source, target) {
+		kinds = append(kinds, e.Kind)
+	}
+	return kinds
+}
+
+// TxDependencies condenses the graph to the transactions of its vertices. It
+// maps a transaction to the later ones that read state it was the last to
+// write. Edges from the source vertices, the world before the first
+// transaction, are left out.
+func (g *DependencyGraph) TxDependencies() map[int][]int {
+	deps := make(map[int][]int)
+	for i := range g.edges {
+		e := &g.edges[i]
+		if int(e.source) < numSources {
+			continue
+		}
+		from, to := g.lookup(e.source), g.lookup(e.target)
+		if from.tx < 0 || to.tx <= from.tx {
+			continue
+		}
+		if !containsInt(deps[int(from.tx)], int(to.tx)) {
+			deps[int(from.tx)] = append(deps[int(from.tx)], int(to.tx))
+		}
+	}
+	for _, txs := range deps {
+		sort.Ints(txs)
+	}
+	return deps
+}
+
+// TxGraph returns the part of the graph of the given transaction. The edges
+// from the vertices of other transactions become edges from the source vertex
+// of their kind, the state the transaction starts from.
+func (g *DependencyGraph) TxGraph(tx int) *DependencyGraph {
+	sub := NewDependencyGraph()
+	g.ForEachVertex(func(v Metadata) error {
+		if !v.IsSource() && v.TxId == tx {
+			sub.AddVertex(v)
+			sub.copyConstants(g, v.Index, v.Index)
+		}
+		return nil
+	})
+	g.ForEachEdge(func(source, target int, edge Edge) error {
+		to, ok := sub.Vertex(target)
+		if !ok || to.IsSource() {
+			return nil
+		}
+		from, ok := sub.Vertex(source)
+		if !ok {
+			from = sourceOfEdge(edge.Kind)
+		}
+		sub.AddEdge(from, to, edge)
+		return nil
+	})
+	return sub
+}
+
+// Constants returns the PUSH operands of the vertex, only recorded in
+// value-flow mode.
+func (g *DependencyGraph) Constants(index int) []Constant {
+	var constants []Constant
+	for _, c := range g.operands[handleOf(index)] {
+		constant := &g.constants[c.constant]
+		constants = append(constants, Constant{
+			Operand: int(c.operand),
+			Pc:      uint64(constant.pc),
+			Value:   new(uint256.Int).Set(&constant.value),
+		})
+	}
+	return constants
+}
+
+// copyConstants copies the constants of vertex i of other to vertex j.
+func (g *DependencyGraph) copyConstants(other *DependencyGraph, i, j int) {
+	for _, c := range other.Constants(i) {
+		g.putConstant(g.newConstant(TxInputSourceMeta.handle(), c.Pc, c.Value), c.Operand, handleOf(j))
+	}
+}
+
+// containsInt reports whether n is in list.
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
+
+// RevertVertices handles the vertices [from, to) of a reverted frame. They are
+// either marked as reverted, or dropped along with every edge touching them.
+//
+// Dropping relies on every edge recorded since the frame started going into
+// one of its vertices: the edges of the frame are the tail of the edge arena.
+func (g *DependencyGraph) RevertVertices(from, to int, drop bool) {
+	if from >= to {
+		return
+	}
+	if !drop {
+		for i := from; i < to; i++ {
+			if v, ok := g.present(handleOf(i)); ok {
+				v.flags |= vertexReverted
+			}
+		}
+		return
+	}
+	first := handleOf(from)
+	n := len(g.edges)
+	for n > 0 && g.edges[n-1].target >= first {
+		n--
+	}
+	g.edges = g.edges[:n]
+	for i := from; i < to; i++ {
+		h := handleOf(i)
+		if v, ok := g.present(h); ok {
+			*v = vertex{}
+			g.count--
+		}
+		delete(g.operands, h)
+	}
+	g.out = nil
+}
+
+// annotateVertex fills in the attributes of the vertex produced by the step
+// just executed, if any. The value is only recorded for steps that pushed
+// one and did not fail.
+func (in *EVMInterpreter) annotateVertex(scope *ScopeContext, operation *operation, index int, cost uint64, err error) {
+	v, ok := in.evm.Graph.present(handleOf(index))
+	if !ok {
+		return
+	}
+	v.depth = uint16(in.evm.depth)
+	v.frame = int32(scope.frame)
+	v.txFrame, v.step = int32(scope.txFrame), int32(scope.step)
+	if number := in.evm.Context.BlockNumber; number != nil {
+		v.block = number.Uint64()
+	}
+	v.flags |= vertexHasID
+	v.gas = cost
+	if err == nil && int(params.StackLimit)+operation.minStack-operation.maxStack > 0 {
+		in.evm.Graph.setValue(v, scope.Stack.peek())
+	}
+}
+
+// absorb adds the vertices and edges of other to the graph, shifting their
+// indexes, frame ids and frames within the transaction by the given offsets
+// so they don't collide with the ones already in it. The source vertices are
+// shared, unless link records the edges out of them otherwise: it is given
+// every such edge along with its shifted target, and returns whether it did.
+// It returns the index past the last vertex added.
+func (g *DependencyGraph) absorb(other *DependencyGraph, index, frame, txFrame int, link func(source Metadata, e Edge, target VertexHandle) bool) int {
+	shift := func(v Metadata) Metadata {
+		if v.IsSource() {
+			return v
+		}
+		v.Index += index
+		v.Frame += frame
+		v.ID.Frame += txFrame
+		return v
+	}
+	next := index
+	other.ForEachVertex(func(v Metadata) error {
+		if v.IsSource() {
+			return nil
+		}
+		g.AddVertex(shift(v))
+		g.copyConstants(other, v.Index, v.Index+index)
+		if v.Index+index >= next {
+			next = v.Index + index + 1
+		}
+		return nil
+	})
+	other.ForEachEdge(func(source, target int, edge Edge) error {
+		from, _ := other.Vertex(source)
+		to, _ := other.Vertex(target)
+		if from.IsSource() && link != nil && link(from, edge, shift(to).handle()) {
+			return nil
+		}
+		g.AddEdge(shift(from), shift(to), edge)
+		return nil
+	})
+	return next
+}
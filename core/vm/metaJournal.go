@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// metaJournalEntry is a modification of the shadow state that can be undone
+// when the frame that made it reverts.
+type metaJournalEntry interface {
+	// revert undoes the changes introduced by this journal entry.
+	revert()
+}
+
+// metaJournal mirrors the journal of the StateDB for the shadow storage,
+// transient storage and accounts, so that they can be rolled back in lockstep
+// with StateDB.RevertToSnapshot.
+type metaJournal struct {
+	entries []metaJournalEntry
+}
+
+func newMetaJournal() *metaJournal {
+	return &metaJournal{}
+}
+
+// append inserts a new modification entry to the end of the journal.
+func (j *metaJournal) append(entry metaJournalEntry) {
+	if j != nil {
+		j.entries = append(j.entries, entry)
+	}
+}
+
+// snapshot returns the current position of the journal.
+func (j *metaJournal) snapshot() int {
+	return len(j.entries)
+}
+
+// reset drops every entry, the changes made so far can no longer be undone.
+func (j *metaJournal) reset() {
+	j.entries = nil
+}
+
+// revert undoes every modification made since the given snapshot.
+func (j *metaJournal) revert(snapshot int) {
+	for i := len(j.entries) - 1; i >= snapshot; i-- {
+		j.entries[i].revert()
+	}
+	j.entries = j.entries[:snapshot]
+}
+
+type (
+	// Changes to a slot of the shadow (transient) storage
+	metaStorageChange struct {
+		store   map[common.Address]EachStorage
+		addr    common.Address
+		key     common.Hash
+		prev    VertexHandle
+		prevSet bool
+	}
+	// Changes to the balance or code writer of an account
+	metaAccountChange struct {
+		store   map[common.Address]VertexHandle
+		addr    common.Address
+		prev    VertexHandle
+		prevSet bool
+	}
+)
+
+func (ch metaStorageChange) revert() {
+	if ch.prevSet {
+		ch.store[ch.addr][ch.key] = ch.prev
+	} else {
+		delete(ch.store[ch.addr], ch.key)
+	}
+}
+
+func (ch metaAccountChange) revert() {
+	if ch.prevSet {
+		ch.store[ch.addr] = ch.prev
+	} else {
+		delete(ch.store, ch.addr)
+	}
+}
+
+// metaRevision is the point a frame can be rolled back to: the position of the
+// shadow journal, the first vertex index the frame may have produced and the
+// number of parallel calls spawned before it.
+type metaRevision struct {
+	journal int
+	vertex  int
+	calls   int
+}
+
+// metaSnapshot is taken next to every StateDB.Snapshot of a frame.
+func (evm *EVM) metaSnapshot() metaRevision {
+	rev := metaRevision{calls: evm.parallel.snapshot()}
+	if evm.Config.EnableDFG {
+		rev.journal, rev.vertex = evm.metaJournal.snapshot(), evm.opCodeCounter
+	}
+	return rev
+}
+
+// metaRevertToSnapshot is called next to every StateDB.RevertToSnapshot of a
+// frame. It drops the parallel calls the frame spawned, undoes the shadow
+// writes of the frame and marks (or drops) every vertex the frame produced.
+func (evm *EVM) metaRevertToSnapshot(rev metaRevision) {
+	evm.parallel.revert(rev.calls)
+	if !evm.Config.EnableDFG {
+		return
+	}
+	evm.metaJournal.revert(rev.journal)
+
+	// The frame produced every vertex indexed from the snapshot on
+	evm.Graph.RevertVertices(rev.vertex, evm.opCodeCounter, evm.Config.DFGDropReverted)
+	if evm.Config.DFGDropReverted {
+		// Nothing may refer to the dropped vertices, the revert data is
+		// attributed to the call instead.
+		evm.interpreter.returnDataMeta = nil
+	}
+}
+
+// metaTransferReads records the balances read by the value transfer of a call
+// or create vertex, the one of the sender and the one credited. It must run
+// before the snapshot of the frame: the vertex belongs to the caller and its
+// edges are kept if the frame reverts. Transfers of the transaction itself
+// have no vertex to read into.
+func (evm *EVM) metaTransferReads(from, to common.Address, value *big.Int, sourceIndex int) {
+	if !evm.Config.EnableDFG || value.Sign() == 0 || sourceIndex < 0 {
+		return
+	}
+	h := handleOf(sourceIndex)
+	evm.Graph.addEdge(evm.metaBalance.Get(from), h, Edge{Kind: BalanceEdge, Address: from})
+	evm.Graph.addEdge(evm.metaBalance.Get(to), h, Edge{Kind: BalanceEdge, Address: to})
+}
+
+// metaTransfer makes the call or create vertex, or the transaction input, the
+// writer of both balances of a value transfer. The writes are undone with the
+// frame.
+func (evm *EVM) metaTransfer(from, to common.Address, value *big.Int, sourceIndex int) {
+	if !evm.Config.EnableDFG || value.Sign() == 0 {
+		return
+	}
+	evm.metaBalance.Set(from, handleOf(sourceIndex))
+	evm.metaBalance.Set(to, handleOf(sourceIndex))
+}
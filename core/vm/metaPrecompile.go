@@ -0,0 +1,86 @@
+package vm
+
+import "github.com/ethereum/go-ethereum/common"
+
+// precompileName returns the label of the vertices of the runs of a
+// precompiled contract.
+func precompileName(p PrecompiledContract) string {
+	switch p.(type) {
+	case *ecrecover:
+		return "ecrecover"
+	case *sha256hash:
+		return "sha256"
+	case *ripemd160hash:
+		return "ripemd160"
+	case *dataCopy:
+		return "identity"
+	case *bigModExp:
+		return "modexp"
+	case *bn256AddIstanbul, *bn256AddByzantium:
+		return "bn256add"
+	case *bn256ScalarMulIstanbul, *bn256ScalarMulByzantium:
+		return "bn256scalarmul"
+	case *bn256PairingIstanbul, *bn256PairingByzantium:
+		return "bn256pairing"
+	case *blake2F:
+		return "blake2f"
+	case *kzgPointEvaluation:
+		return "pointevaluation"
+	case *bls12381G1Add:
+		return "bls12381g1add"
+	case *bls12381G1Mul:
+		return "bls12381g1mul"
+	case *bls12381G1MultiExp:
+		return "bls12381g1multiexp"
+	case *bls12381G2Add:
+		return "bls12381g2add"
+	case *bls12381G2Mul:
+		return "bls12381g2mul"
+	case *bls12381G2MultiExp:
+		return "bls12381g2multiexp"
+	case *bls12381Pairing:
+		return "bls12381pairing"
+	case *bls12381MapG1:
+		return "bls12381mapg1"
+	case *bls12381MapG2:
+		return "bls12381mapg2"
+	}
+	return "precompile"
+}
+
+// runPrecompile runs a precompiled contract called by the vertex of the given
+// index. When the dependency graph is built, the run becomes a vertex of its
+// own, labeled with the name of the precompile: it reads the bytes of the
+// input the caller wrote, and writes all of the returned data. Like a frame
+// running code, it is given a frame of its own one level deeper than the
+// caller, and is reverted with it.
+func (evm *EVM) runPrecompile(p PrecompiledContract, addr common.Address, input []byte, gas uint64, sourceIndex int) ([]byte, uint64, error) {
+	ret, remaining, err := RunPrecompiledContract(p, input, gas)
+	if !evm.Config.EnableDFG {
+		return ret, remaining, err
+	}
+	in := evm.interpreter
+	meta := Metadata{
+		TxId:   evm.StateDB.GetTxId(),
+		Index:  evm.opCodeCounter,
+		Addr:   addr,
+		OpCode: precompileName(p),
+		Depth:  evm.depth + 1,
+		Frame:  evm.frameCounter,
+		Gas:    gas - remaining,
+	}
+	meta.ID = VertexID{Tx: meta.TxId, Frame: evm.frameCounter - evm.txFrameBase}
+	if number := evm.Context.BlockNumber; number != nil {
+		meta.ID.Block = number.Uint64()
+	}
+	evm.opCodeCounter++
+	evm.frameCounter++
+
+	h := meta.handle()
+	evm.Graph.store(meta)
+	evm.Graph.addEdge(handleOf(sourceIndex), h, Edge{Kind: CallEdge})
+	evm.Graph.addRangeDependency(CallEdge, in.callArgsMeta, 0, uint64(len(input)), h)
+	in.callArgsMeta = nil
+	in.returnDataMeta = returnedMeta(ret, nil, h)
+	return ret, remaining, err
+}
@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"github.com/holiman/uint256"
+)
+
+// In value-flow mode, enabled by Config.DFGValueFlow, the instructions only
+// moving values around the stack are left out of the graph. DUP and SWAP move
+// the metadata of the producers along with the values, POP drops it, and PUSH
+// puts a constant on the meta stack instead of a vertex. A constant is recorded
+// on the vertex consuming it, which also gets the call edge the PUSH vertex
+// would have had. JUMPDEST never makes a vertex.
+//
+// The reachability between the vertices left is the same as in the full graph,
+// with two exceptions: a full SWAP vertex merges both values it swaps, so the
+// full graph may have paths value-flow mode rightly lacks, and a value moved
+// by a DUP or SWAP under a branch loses the control edge of the move.
+
+// Constant is a PUSH operand of an instruction in value-flow mode.
+type Constant struct {
+	Operand int          `json:"operand"` // position of the stack operand, 0 being the top
+	Pc      uint64       `json:"pc"`      // pc of the PUSH
+	Value   *uint256.Int `json:"value"`
+}
+
+// newConstantMeta returns the constant a PUSH puts on the meta stack in
+// value-flow mode. It depends on the vertex the PUSH would depend on.
+func newConstantMeta(interpreter *EVMInterpreter, scope *ScopeContext, pc uint64) VertexHandle {
+	return interpreter.evm.Graph.newConstant(handleOf(scope.Contract.SourceIndex), pc, scope.Stack.peek())
+}
+
+// makePushValueFlow makes the PUSH instruction function of value-flow mode.
+func makePushValueFlow(push executionFunc) executionFunc {
+	return func(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+		opPc := *pc
+		res, err := push(pc, interpreter, scope)
+		scope.metaStack.push(newConstantMeta(interpreter, scope, opPc))
+		return res, err
+	}
+}
+
+// makeDupValueFlow makes the DUP instruction function of value-flow mode.
+func makeDupValueFlow(size int64) executionFunc {
+	dup := makeDup(size)
+	return func(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+		res, err := dup(pc, interpreter, scope)
+		scope.metaStack.dup(int(size))
+		return res, err
+	}
+}
+
+// makeSwapValueFlow makes the SWAP instruction function of value-flow mode.
+func makeSwapValueFlow(size int64) executionFunc {
+	swap := makeSwap(size)
+	return func(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+		res, err := swap(pc, interpreter, scope)
+		scope.metaStack.swap(int(size) + 1)
+		return res, err
+	}
+}
+
+// opPopValueFlow implements POP in value-flow mode.
+func opPopValueFlow(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]byte, error) {
+	scope.Stack.pop()
+	scope.metaStack.pop()
+	return nil, nil
+}
+
+// setValueFlowInstructions replaces the stack shuffling instructions of a
+// dependency-tracking jump table by their value-flow versions, which make no
+// vertex.
+func setValueFlowInstructions(tbl *JumpTable) {
+	if tbl[PUSH0].HasCost() {
+		tbl[PUSH0].execute = makePushValueFlow(opPush0)
+		tbl[PUSH0].noVertex = true
+	}
+	tbl[PUSH1].execute = makePushValueFlow(opPush1)
+	tbl[PUSH1].noVertex = true
+	for i := 2; i <= 32; i++ {
+		tbl[PUSH1+OpCode(i-1)].execute = makePushValueFlow(makePush(uint64(i), i))
+		tbl[PUSH1+OpCode(i-1)].noVertex = true
+	}
+	for i := 1; i <= 16; i++ {
+		tbl[DUP1+OpCode(i-1)].execute = makeDupValueFlow(int64(i))
+		tbl[DUP1+OpCode(i-1)].noVertex = true
+		tbl[SWAP1+OpCode(i-1)].execute = makeSwapValueFlow(int64(i))
+		tbl[SWAP1+OpCode(i-1)].noVertex = true
+	}
+	tbl[POP].execute = opPopValueFlow
+	tbl[POP].noVertex = true
+}
+
+// addConstant records that target pops the given constant. The target gets
+// the call edge the PUSH would have had instead.
+func (g *DependencyGraph) addConstant(constant VertexHandle, operand int, target VertexHandle) {
+	g.putConstant(constant, operand, target)
+	g.addEdge(g.constantSource(constant), target, Edge{Kind: CallEdge})
+}
+
+// putConstant records that target pops the given constant, without the edge.
+func (g *DependencyGraph) putConstant(constant VertexHandle, operand int, target VertexHandle) {
+	g.addVertex(target)
+	if g.operands == nil {
+		g.operands = make(map[VertexHandle][]operandConstant)
+	}
+	g.operands[target] = append(g.operands[target], operandConstant{operand: uint16(operand), constant: uint32(constant &^ constantBit)})
+}
+
+// constantSource returns the vertex a constant depends on, the transaction
+// input if that vertex is not part of the graph.
+func (g *DependencyGraph) constantSource(constant VertexHandle) VertexHandle {
+	source := g.constants[constant&^constantBit].source
+	if _, ok := g.present(source); !ok {
+		return TxInputSourceMeta.handle()
+	}
+	return source
+}
@@ -0,0 +1,333 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// The parallel call subsystem lets a contract run calls concurrently within
+// its transaction. It is an experiment, not part of any fork: the addresses
+// below are only intercepted when Config.ParallelCallWorkers is set.
+//
+// A CALL to ParallelSpawnAddress queues a call, its input being the target
+// address as a 32 byte word followed by the call data. The gas handed to the
+// spawn is the gas of the queued call, and the id of the call is returned as
+// a 32 byte word.
+//
+// A CALL to ParallelJoinAddress runs all the queued calls concurrently, each
+// against its own interpreter and state overlay, and merges their results in
+// the order they were spawned. A call that read state written by an earlier
+// one is run again on the merged state, so the outcome is the same as if the
+// calls had run one after the other. With a call id as input, the join returns
+// the output of that call and fails if the call failed. A join cannot be made
+// from a static context, as the calls may write state.
+//
+// The queued calls belong to the frame spawning them: they are dropped if the
+// frame reverts, along with the gas handed to them. The gas the calls did not
+// use is handed back by the join running them, which may be made by another
+// frame than the spawn: a frame returning with calls still queued leaves their
+// gas to the frame joining them, its caller or a later one.
+//
+// Spawns and joins are subject to the call depth limit, and reported to the
+// tracer as calls of their addresses. The queued calls run without tracer.
+var (
+	ParallelSpawnAddress = common.HexToAddress("0x00000000000000000000000000000000000ca110")
+	ParallelJoinAddress  = common.HexToAddress("0x00000000000000000000000000000000000ca111")
+)
+
+var (
+	errParallelCallValue   = errors.New("parallel call with value")
+	errParallelCallUnknown = errors.New("unknown parallel call")
+)
+
+// newParallelEVM creates the EVM running a parallel call. It is only assigned
+// in init, as NewEVM refers back to Call through the instruction sets.
+var newParallelEVM func(BlockContext, TxContext, StateDB, *params.ChainConfig, Config) *EVM
+
+func init() {
+	newParallelEVM = NewEVM
+}
+
+// parallelCall is a call queued by a spawn.
+type parallelCall struct {
+	caller common.Address
+	addr   common.Address
+	input  []byte
+	gas    uint64
+
+	// For DFG Generation, the vertex of the spawning CALL and the provenance
+	// of the call data in the spawning frame
+	source    int
+	metaInput *MetaMemory
+
+	ret    []byte
+	left   uint64
+	err    error
+	state  *overlayState
+	child  *EVM // EVM the call ran in, until merged
+	joined bool // whether the left over gas has been handed back
+}
+
+// parallelCalls holds the calls spawned within a transaction.
+type parallelCalls struct {
+	workers int
+	calls   []*parallelCall
+	pending int // index of the first call not run yet
+}
+
+func newParallelCalls(workers int) *parallelCalls {
+	return &parallelCalls{workers: workers}
+}
+
+// snapshot returns the number of calls spawned so far, see revert.
+func (pc *parallelCalls) snapshot() int {
+	if pc == nil {
+		return 0
+	}
+	return len(pc.calls)
+}
+
+// revert drops the calls spawned since the snapshot, by a frame reverting.
+// Those that already ran were joined within the frame, their writes are
+// reverted along with it.
+func (pc *parallelCalls) revert(snapshot int) {
+	if pc == nil || snapshot >= len(pc.calls) {
+		return
+	}
+	pc.calls = pc.calls[:snapshot]
+	if pc.pending > snapshot {
+		pc.pending = snapshot
+	}
+}
+
+// callParallel runs a call of the spawn or join address, reporting it to the
+// tracer as Call does the other calls.
+func (evm *EVM) callParallel(caller ContractRef, addr common.Address, input []byte, gas uint64, value *big.Int, sourceIndex int) (ret []byte, leftOverGas uint64, err error) {
+	if tracer := evm.Config.Tracer; tracer != nil {
+		if evm.depth == 0 {
+			tracer.CaptureStart(evm, caller.Address(), addr, false, input, gas, value)
+			defer func(startGas uint64) {
+				tracer.CaptureEnd(ret, startGas-leftOverGas, err)
+			}(gas)
+		} else {
+			tracer.CaptureEnter(CALL, caller.Address(), addr, input, gas, value)
+			defer func(startGas uint64) {
+				tracer.CaptureExit(ret, startGas-leftOverGas, err)
+			}(gas)
+		}
+	}
+	if addr == ParallelSpawnAddress {
+		return evm.spawnParallelCall(caller, input, gas, value, sourceIndex)
+	}
+	return evm.joinParallelCalls(input, gas)
+}
+
+// spawnParallelCall queues a call made by the vertex of the given index,
+// returning its id.
+func (evm *EVM) spawnParallelCall(caller ContractRef, input []byte, gas uint64, value *big.Int, sourceIndex int) ([]byte, uint64, error) {
+	if value.Sign() != 0 {
+		return nil, gas, errParallelCallValue
+	}
+	if evm.interpreter.readOnly {
+		return nil, gas, ErrWriteProtection
+	}
+	call := &parallelCall{
+		caller: caller.Address(),
+		addr:   common.BytesToAddress(getData(input, 0, 32)),
+		gas:    gas,
+	}
+	if len(input) > 32 {
+		call.input = common.CopyBytes(input[32:])
+	}
+	if evm.Config.EnableDFG {
+		call.source = sourceIndex
+		if args := evm.interpreter.callArgsMeta; args != nil && len(input) > 32 {
+			call.metaInput = args.GetRange(32, uint64(len(input)-32))
+		}
+	}
+	pc := evm.parallel
+	pc.calls = append(pc.calls, call)
+	return common.BigToHash(big.NewInt(int64(len(pc.calls) - 1))).Bytes(), 0, nil
+}
+
+// joinParallelCalls runs the queued calls and hands back the result of the
+// one asked for, if any.
+func (evm *EVM) joinParallelCalls(input []byte, gas uint64) ([]byte, uint64, error) {
+	if evm.interpreter.readOnly {
+		return nil, gas, ErrWriteProtection
+	}
+	pc := evm.parallel
+	if pc.pending < len(pc.calls) {
+		evm.runParallelCalls(pc.calls[pc.pending:])
+		pc.pending = len(pc.calls)
+	}
+	for _, call := range pc.calls {
+		if !call.joined {
+			gas += call.left
+			call.joined = true
+		}
+	}
+	if len(input) == 0 {
+		return nil, gas, nil
+	}
+	id := new(big.Int).SetBytes(getData(input, 0, 32))
+	if !id.IsUint64() || id.Uint64() >= uint64(len(pc.calls)) {
+		return nil, gas, errParallelCallUnknown
+	}
+	call := pc.calls[id.Uint64()]
+	if call.err != nil {
+		return call.ret, gas, ErrExecutionReverted
+	}
+	return call.ret, gas, nil
+}
+
+// runParallelCalls runs a batch of calls concurrently, then merges them in
+// order. Once a call had to be run again on the merged state, nothing is
+// known about what it wrote, so the rest of the batch is run again as well.
+func (evm *EVM) runParallelCalls(calls []*parallelCall) {
+	var (
+		mu   sync.Mutex // serialises the reads of the base state
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, evm.parallel.workers)
+		base = evm.StateDB
+	)
+	for _, call := range calls {
+		call := call
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			call.state = newOverlayState(base, &mu)
+			evm.runParallelCall(call, call.state)
+		}()
+	}
+	wg.Wait()
+
+	var (
+		written = make(map[overlayKey]struct{})
+		serial  bool
+	)
+	for _, call := range calls {
+		if serial || call.state.unsafe || call.state.conflicts(written) {
+			serial = true
+			evm.runParallelCall(call, base)
+		} else {
+			call.state.merge()
+			for key := range call.state.writes {
+				written[key] = struct{}{}
+			}
+		}
+		call.state = nil
+		if evm.Config.EnableDFG {
+			evm.mergeParallelCall(call)
+		}
+		call.child = nil
+	}
+}
+
+// runParallelCall runs a call against the given state, with an interpreter of
+// its own. Parallel calls cannot be nested, and tracers only follow the
+// spawning transaction.
+func (evm *EVM) runParallelCall(call *parallelCall, statedb StateDB) {
+	cfg := evm.Config
+	cfg.Tracer = nil
+	cfg.ParallelCallWorkers = 0
+
+	child := newParallelEVM(evm.Context, evm.TxContext, statedb, evm.chainConfig, cfg)
+	child.depth = evm.depth
+	if cfg.EnableDFG {
+		// The call data comes from the transaction input, until merged
+		input := newMetaMemory()
+		input.Resize(uint64(len(call.input)))
+		input.Set(0, uint64(len(call.input)), TxInputSourceMeta.handle())
+		child.interpreter.callArgsMeta = input
+	}
+	call.ret, call.left, call.err = child.Call(AccountRef(call.caller), call.addr, call.input, call.gas, new(big.Int), TxInputSourceMeta.Index)
+	call.child = child
+}
+
+// mergeParallelCall adds the graph of a call to the one of the transaction,
+// and makes its writers of the shadow state the current ones. The calls are
+// merged in the order they were spawned, so the shadow state of the
+// transaction is the one the call saw: the edges out of the source vertices
+// are tied to the writers the transaction has at this point instead. The call
+// itself was run for the transaction input, which stands for the spawning
+// CALL and the bytes of the call data it read.
+func (evm *EVM) mergeParallelCall(call *parallelCall) {
+	var (
+		child = call.child
+		base  = evm.opCodeCounter
+		spawn = handleOf(call.source)
+	)
+	link := func(source Metadata, e Edge, target VertexHandle) bool {
+		var from VertexHandle
+		switch {
+		case source.Index == StorageSourceMeta.Index && e.Kind == StorageEdge:
+			from = evm.metaStorage.Get(e.Address, e.Slot)
+		case source.Index == BalanceSourceMeta.Index && e.Kind == BalanceEdge:
+			from = evm.metaBalance.Get(e.Address)
+		case source.Index == CodeSourceMeta.Index && e.Kind == CodeEdge:
+			from = evm.metaCode.Get(e.Address)
+		case source.Index == TxInputSourceMeta.Index && e.Kind == TransientStorageEdge:
+			from = evm.metaTStorage.Get(e.Address, e.Slot)
+		case source.Index == TxInputSourceMeta.Index && e.Kind == CallEdge && e.Size > 0:
+			evm.Graph.addRangeDependency(CallEdge, call.metaInput, e.Offset, e.Size, target)
+			return true
+		case source.Index == TxInputSourceMeta.Index && (e.Kind == CallEdge || e.Kind == StackEdge):
+			// The frame of the call, or the constants depending on it
+			from = spawn
+		default:
+			return false
+		}
+		evm.Graph.addEdge(from, target, e)
+		return true
+	}
+	evm.opCodeCounter = evm.Graph.absorb(child.Graph, base, evm.frameCounter, evm.frameCounter-evm.txFrameBase, link)
+	evm.frameCounter += child.frameCounter
+
+	shift := func(h VertexHandle) VertexHandle {
+		switch {
+		case h == TxInputSourceMeta.handle():
+			return spawn
+		case int(h) < numSources:
+			return h
+		}
+		return handleOf(h.Index() + base)
+	}
+	for addr, slots := range child.metaStorage.store {
+		for slot, h := range slots {
+			evm.metaStorage.Set(addr, slot, shift(h))
+		}
+	}
+	for addr, slots := range child.metaTStorage.store {
+		for slot, h := range slots {
+			evm.metaTStorage.Set(addr, slot, shift(h))
+		}
+	}
+	for addr, h := range child.metaBalance.store {
+		evm.metaBalance.Set(addr, shift(h))
+	}
+	for addr, h := range child.metaCode.store {
+		evm.metaCode.Set(addr, shift(h))
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// overlayKeyKind tells which part of the state an overlayKey refers to.
+type overlayKeyKind uint8
+
+const (
+	accountKey       overlayKeyKind = iota // balance, nonce, code and existence of an account
+	storageKey                             // a storage slot
+	transientKey                           // a transient storage slot
+	addressAccessKey                       // an account in the access list
+	slotAccessKey                          // a slot in the access list
+)
+
+// overlayKey is the unit at which the reads and writes of a parallel call
+// are tracked.
+type overlayKey struct {
+	kind overlayKeyKind
+	addr common.Address
+	slot common.Hash
+}
+
+// overlayAccount is an account as seen by a parallel call.
+type overlayAccount struct {
+	exist    bool
+	balance  *big.Int
+	nonce    uint64
+	code     []byte
+	codeHash common.Hash
+
+	created    bool // created by the call, storage starts out empty
+	destructed bool
+	dirty      bool // modified by the call
+	codeDirty  bool
+}
+
+// overlayState is the StateDB of a parallel call. Reads go through to the
+// state of the spawning transaction, writes stay in the overlay until they
+// are merged back. Everything read and written is recorded, so that the
+// merge can tell whether the call saw state changed by an earlier one.
+//
+// The base is only ever read, under a lock shared by all the overlays of a
+// batch, as StateDB implementations cache what they load.
+type overlayState struct {
+	base StateDB
+	mu   *sync.Mutex
+	txId int
+
+	accounts  map[common.Address]*overlayAccount
+	storage   map[common.Address]map[common.Hash]common.Hash
+	transient map[common.Address]map[common.Hash]common.Hash
+	access    map[overlayKey]bool
+	refund    int64 // change to the refund counter of the base
+	logs      []*types.Log
+	preimages map[common.Hash][]byte
+
+	reads  map[overlayKey]struct{}
+	writes map[overlayKey]struct{}
+
+	// unsafe is set when the call did something the overlay cannot
+	// reproduce on its own, the call is then run again on the base.
+	unsafe bool
+
+	journal []func()
+}
+
+func newOverlayState(base StateDB, mu *sync.Mutex) *overlayState {
+	s := &overlayState{
+		base:      base,
+		mu:        mu,
+		accounts:  make(map[common.Address]*overlayAccount),
+		storage:   make(map[common.Address]map[common.Hash]common.Hash),
+		transient: make(map[common.Address]map[common.Hash]common.Hash),
+		access:    make(map[overlayKey]bool),
+		preimages: make(map[common.Hash][]byte),
+		reads:     make(map[overlayKey]struct{}),
+		writes:    make(map[overlayKey]struct{}),
+	}
+	mu.Lock()
+	s.txId = base.GetTxId()
+	mu.Unlock()
+	return s
+}
+
+// conflicts reports whether the call read any of the given keys.
+func (s *overlayState) conflicts(written map[overlayKey]struct{}) bool {
+	for key := range s.reads {
+		if _, ok := written[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *overlayState) read(key overlayKey) {
+	s.reads[key] = struct{}{}
+}
+
+// write records a write, which is a read as well: the merged value only
+// stands if nobody changed it in between.
+func (s *overlayState) write(key overlayKey) {
+	s.reads[key] = struct{}{}
+	s.writes[key] = struct{}{}
+}
+
+func (s *overlayState) account(addr common.Address) *overlayAccount {
+	s.read(overlayKey{kind: accountKey, addr: addr})
+	if obj, ok := s.accounts[addr]; ok {
+		return obj
+	}
+	s.mu.Lock()
+	obj := &overlayAccount{
+		exist:    s.base.Exist(addr),
+		balance:  new(big.Int).Set(s.base.GetBalance(addr)),
+		nonce:    s.base.GetNonce(addr),
+		code:     s.base.GetCode(addr),
+		codeHash: s.base.GetCodeHash(addr),
+	}
+	obj.destructed = s.base.HasSelfDestructed(addr)
+	s.mu.Unlock()
+	s.accounts[addr] = obj
+	return obj
+}
+
+// modify returns the account for a write, journaling its current content.
+func (s *overlayState) modify(addr common.Address) *overlayAccount {
+	obj := s.account(addr)
+	s.write(overlayKey{kind: accountKey, addr: addr})
+
+	prev := *obj
+	prev.balance = new(big.Int).Set(obj.balance)
+	s.journal = append(s.journal, func() { *obj = prev })
+
+	obj.dirty = true
+	return obj
+}
+
+func (s *overlayState) CreateAccount(addr common.Address) {
+	obj := s.modify(addr)
+	obj.exist = true
+	obj.nonce = 0
+	obj.code = nil
+	obj.codeHash = types.EmptyCodeHash
+	obj.codeDirty = true
+	obj.created = true
+	obj.destructed = false
+
+	prev, ok := s.storage[addr]
+	s.journal = append(s.journal, func() {
+		if ok {
+			s.storage[addr] = prev
+		} else {
+			delete(s.storage, addr)
+		}
+	})
+	s.storage[addr] = make(map[common.Hash]common.Hash)
+}
+
+func (s *overlayState) SubBalance(addr common.Address, amount *big.Int) {
+	obj := s.modify(addr)
+	obj.balance.Sub(obj.balance, amount)
+}
+
+func (s *overlayState) AddBalance(addr common.Address, amount *big.Int) {
+	obj := s.modify(addr)
+	obj.balance.Add(obj.balance, amount)
+}
+
+func (s *overlayState) GetBalance(addr common.Address) *big.Int {
+	return new(big.Int).Set(s.account(addr).balance)
+}
+
+func (s *overlayState) GetNonce(addr common.Address) uint64 {
+	return s.account(addr).nonce
+}
+
+func (s *overlayState) SetNonce(addr common.Address, nonce uint64) {
+	s.modify(addr).nonce = nonce
+}
+
+func (s *overlayState) GetCodeHash(addr common.Address) common.Hash {
+	return s.account(addr).codeHash
+}
+
+func (s *overlayState) GetCode(addr common.Address) []byte {
+	return s.account(addr).code
+}
+
+func (s *overlayState) SetCode(addr common.Address, code []byte) {
+	obj := s.modify(addr)
+	obj.exist = true
+	obj.code = code
+	obj.codeHash = crypto.Keccak256Hash(code)
+	obj.codeDirty = true
+}
+
+func (s *overlayState) GetCodeSize(addr common.Address) int {
+	return len(s.account(addr).code)
+}
+
+func (s *overlayState) AddRefund(gas uint64) {
+	s.journal = append(s.journal, func() { s.refund -= int64(gas) })
+	s.refund += int64(gas)
+}
+
+func (s *overlayState) SubRefund(gas uint64) {
+	if int64(s.GetRefund()) < int64(gas) {
+		panic("refund counter below zero")
+	}
+	s.journal = append(s.journal, func() { s.refund += int64(gas) })
+	s.refund -= int64(gas)
+}
+
+func (s *overlayState) GetRefund() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return uint64(int64(s.base.GetRefund()) + s.refund)
+}
+
+func (s *overlayState) GetCommittedState(addr common.Address, key common.Hash) common.Hash {
+	if obj := s.account(addr); obj.created {
+		return common.Hash{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.base.GetCommittedState(addr, key)
+}
+
+func (s *overlayState) GetState(addr common.Address, key common.Hash) common.Hash {
+	s.read(overlayKey{kind: storageKey, addr: addr, slot: key})
+	if value, ok := s.storage[addr][key]; ok {
+		return value
+	}
+	if obj := s.account(addr); obj.created {
+		return common.Hash{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.base.GetState(addr, key)
+}
+
+func (s *overlayState) SetState(addr common.Address, key, value common.Hash) {
+	s.setSlot(s.storage, overlayKey{kind: storageKey, addr: addr, slot: key}, value)
+}
+
+func (s *overlayState) GetTransientState(addr common.Address, key common.Hash) common.Hash {
+	s.read(overlayKey{kind: transientKey, addr: addr, slot: key})
+	if value, ok := s.transient[addr][key]; ok {
+		return value
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.base.GetTransientState(addr, key)
+}
+
+func (s *overlayState) SetTransientState(addr common.Address, key, value common.Hash) {
+	s.setSlot(s.transient, overlayKey{kind: transientKey, addr: addr, slot: key}, value)
+}
+
+func (s *overlayState) setSlot(slots map[common.Address]map[common.Hash]common.Hash, key overlayKey, value common.Hash) {
+	s.write(key)
+	if _, ok := slots[key.addr]; !ok {
+		slots[key.addr] = make(map[common.Hash]common.Hash)
+	}
+	prev, ok := slots[key.addr][key.slot]
+	s.journal = append(s.journal, func() {
+		if ok {
+			slots[key.addr][key.slot] = prev
+		} else {
+			delete(slots[key.addr], key.slot)
+		}
+	})
+	slots[key.addr][key.slot] = value
+}
+
+func (s *overlayState) SelfDestruct(addr common.Address) {
+	if !s.account(addr).exist {
+		return
+	}
+	obj := s.modify(addr)
+	obj.destructed = true
+	obj.balance = new(big.Int)
+}
+
+func (s *overlayState) HasSelfDestructed(addr common.Address) bool {
+	return s.account(addr).destructed
+}
+
+func (s *overlayState) Selfdestruct6780(addr common.Address) {
+	obj := s.account(addr)
+	if obj.created {
+		s.SelfDestruct(addr)
+		return
+	}
+	// Whether the account was created earlier in the transaction is only
+	// known to the base
+	s.unsafe = true
+}
+
+func (s *overlayState) Exist(addr common.Address) bool {
+	return s.account(addr).exist
+}
+
+func (s *overlayState) Empty(addr common.Address) bool {
+	obj := s.account(addr)
+	return !obj.exist || (obj.nonce == 0 && obj.balance.Sign() == 0 && obj.codeHash == types.EmptyCodeHash)
+}
+
+func (s *overlayState) AddressInAccessList(addr common.Address) bool {
+	key := overlayKey{kind: addressAccessKey, addr: addr}
+	s.read(key)
+	if s.access[key] {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.base.AddressInAccessList(addr)
+}
+
+func (s *overlayState) SlotInAccessList(addr common.Address, slot common.Hash) (addressOk bool, slotOk bool) {
+	key := overlayKey{kind: slotAccessKey, addr: addr, slot: slot}
+	s.read(key)
+	addressOk = s.AddressInAccessList(addr)
+	if s.access[key] {
+		return addressOk, true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, slotOk = s.base.SlotInAccessList(addr, slot)
+	return addressOk, slotOk
+}
+
+func (s *overlayState) AddAddressToAccessList(addr common.Address) {
+	if !s.AddressInAccessList(addr) {
+		s.addAccess(overlayKey{kind: addressAccessKey, addr: addr})
+	}
+}
+
+func (s *overlayState) AddSlotToAccessList(addr common.Address, slot common.Hash) {
+	s.AddAddressToAccessList(addr)
+	if _, ok := s.SlotInAccessList(addr, slot); !ok {
+		s.addAccess(overlayKey{kind: slotAccessKey, addr: addr, slot: slot})
+	}
+}
+
+func (s *overlayState) addAccess(key overlayKey) {
+	s.write(key)
+	s.journal = append(s.journal, func() { delete(s.access, key) })
+	s.access[key] = true
+}
+
+// Prepare is only called at the start of a transaction, which a parallel
+// call never is.
+func (s *overlayState) Prepare(rules params.Rules, sender, coinbase common.Address, dest *common.Address, precompiles []common.Address, txAccesses types.AccessList) {
+}
+
+func (s *overlayState) Snapshot() int {
+	return len(s.journal)
+}
+
+func (s *overlayState) RevertToSnapshot(revid int) {
+	for i := len(s.journal) - 1; i >= revid; i-- {
+		s.journal[i]()
+	}
+	s.journal = s.journal[:revid]
+}
+
+func (s *overlayState) AddLog(log *types.Log) {
+	n := len(s.logs)
+	s.journal = append(s.journal, func() { s.logs = s.logs[:n] })
+	s.logs = append(s.logs, log)
+}
+
+func (s *overlayState) AddPreimage(hash common.Hash, preimage []byte) {
+	if _, ok := s.preimages[hash]; !ok {
+		s.preimages[hash] = common.CopyBytes(preimage)
+	}
+}
+
+func (s *overlayState) GetTxId() int {
+	return s.txId
+}
+
+// merge applies the writes of the call to the base, in a deterministic order.
+// It must only be called when the call does not conflict with what has been
+// merged before.
+func (s *overlayState) merge() {
+	var addrs []common.Address
+	for addr, obj := range s.accounts {
+		if _, ok := s.storage[addr]; ok || obj.dirty {
+			addrs = append(addrs, addr)
+		}
+	}
+	for addr := range s.storage {
+		if _, ok := s.accounts[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool { return bytes.Compare(addrs[i][:], addrs[j][:]) < 0 })
+
+	for _, addr := range addrs {
+		obj := s.accounts[addr]
+		if obj != nil && obj.dirty {
+			if obj.created {
+				s.base.CreateAccount(addr)
+			}
+			switch have := s.base.GetBalance(addr); have.Cmp(obj.balance) {
+			case -1:
+				s.base.AddBalance(addr, new(big.Int).Sub(obj.balance, have))
+			case 1:
+				s.base.SubBalance(addr, new(big.Int).Sub(have, obj.balance))
+			}
+			if s.base.GetNonce(addr) != obj.nonce {
+				s.base.SetNonce(addr, obj.nonce)
+			}
+			if obj.codeDirty && len(obj.code) > 0 {
+				s.base.SetCode(addr, obj.code)
+			}
+		}
+		for _, slot := range sortedSlots(s.storage[addr]) {
+			s.base.SetState(addr, slot, s.storage[addr][slot])
+		}
+		if obj != nil && obj.destructed {
+			s.base.SelfDestruct(addr)
+		}
+	}
+	for addr, slots := range s.transient {
+		for _, slot := range sortedSlots(slots) {
+			s.base.SetTransientState(addr, slot, slots[slot])
+		}
+	}
+	for key := range s.access {
+		if key.kind == addressAccessKey {
+			s.base.AddAddressToAccessList(key.addr)
+		} else {
+			s.base.AddSlotToAccessList(key.addr, key.slot)
+		}
+	}
+	if s.refund > 0 {
+		s.base.AddRefund(uint64(s.refund))
+	} else if s.refund < 0 {
+		s.base.SubRefund(uint64(-s.refund))
+	}
+	for _, log := range s.logs {
+		s.base.AddLog(log)
+	}
+	for hash, preimage := range s.preimages {
+		s.base.AddPreimage(hash, preimage)
+	}
+}
+
+func sortedSlots(slots map[common.Hash]common.Hash) []common.Hash {
+	keys := make([]common.Hash, 0, len(slots))
+	for key := range slots {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i][:], keys[j][:]) < 0 })
+	return keys
+}
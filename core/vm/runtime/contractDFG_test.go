@@ -1,18 +1,31 @@
 package runtime
 
 import (
+	"bytes"
 	"math/big"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+	"github.com/ethereum/go-ethereum/params"
 )
 
+// fibDeployCode deploys a contract exposing four fibonacci implementations,
+// two of them recursing through external calls to itself.
+var fibDeployCode = common.Hex2Bytes("608060405234801561001057600080fd5b506108a3806100206000396000f3fe608060405234801561001057600080fd5b506004361061004c5760003560e01c80633a9bbfcd146100515780634c803feb146100815780636b83dd2e146100b1578063b5463014146100e1575b600080fd5b61006b60048036038101906100669190610614565b610111565b6040516100789190610675565b60405180910390f35b61009b60048036038101906100969190610614565b610335565b6040516100a89190610675565b60405180910390f35b6100cb60048036038101906100c69190610614565b610496565b6040516100d89190610675565b60405180910390f35b6100fb60048036038101906100f69190610614565b6104f4565b6040516101089190610675565b60405180910390f35b6000806001836101219190610690565b67ffffffffffffffff811115610160577f4e487b7100000000000000000000000000000000000000000000000000000000600052604160045260246000fd5b60405190808252806020026020018201604052801561018e5781602001602082028036833780820191505090505b50905060005b8381116102eb57600181116101ee57808282815181106101dd577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010181815250506102d8565b816002826101fc9190610771565b81518110610233577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010151826001836102499190610771565b81518110610280577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b60200260200101516102929190610690565b8282815181106102cb577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010181815250505b80806102e3906107af565b915050610194565b50808381518110610325577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010151915050919050565b6000808214156103485760009050610491565b600182141561035a5760019050610491565b3073ffffffffffffffffffffffffffffffffffffffff16634c803feb6002846103839190610771565b6040518263ffffffff1660e01b815260040161039f9190610675565b60206040518083038186803b1580156103b757600080fd5b505afa1580156103cb573d6000803e3d6000fd5b505050506040513d601f19601f820116820180604052508101906103ef919061063d565b3073ffffffffffffffffffffffffffffffffffffffff16634c803feb6001856104189190610771565b6040518263ffffffff1660e01b81526004016104349190610675565b60206040518083038186803b15801561044c57600080fd5b505afa158015610460573d6000803e3d6000fd5b505050506040513d601f19601f82011682018060405250810190610484919061063d565b61048e9190610690565b90505b919050565b6000808214156104a957600090506104ef565b600060019050600191506000600290505b838110156104ec57600083836104d09190610690565b90508392508093505080806104e4906107af565b9150506104ba565b50505b919050565b60008082141561050757600090506105e5565b600060028361051691906106e6565b90506000600190505b81811161053257600181901b905061051f565b600181901c90506001925060006001905060005b60008311156105e057818261055b9190610717565b85866105679190610717565b6105719190610690565b9050600083871611156105ab5784600261058b9190610717565b826105969190610690565b826105a19190610717565b91508094506105d4565b848260026105b99190610717565b6105c39190610771565b856105ce9190610717565b94508091505b600183901c9250610546565b505050505b919050565b6000813590506105f981610856565b92915050565b60008151905061060e81610856565b92915050565b60006020828403121561062657600080fd5b6000610634848285016105ea565b91505092915050565b60006020828403121561064f57600080fd5b600061065d848285016105ff565b91505092915050565b61066f816107a5565b82525050565b600060208201905061068a6000830184610666565b92915050565b600061069b826107a5565b91506106a6836107a5565b9250827fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff038211156106db576106da6107f8565b5b828201905092915050565b60006106f1826107a5565b91506106fc836107a5565b92508261070c5761070b610827565b5b828204905092915050565b6000610722826107a5565b915061072d836107a5565b9250817fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff0483118215151615610766576107656107f8565b5b828202905092915050565b600061077c826107a5565b9150610787836107a5565b92508282101561079a576107996107f8565b5b828203905092915050565b6000819050919050565b60006107ba826107a5565b91507fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff8214156107ed576107ec6107f8565b5b600182019050919050565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052601160045260246000fd5b7f4e487b7100000000000000000000000000000000000000000000000000000000600052601260045260246000fd5b61085f816107a5565b811461086a57600080fd5b5056fea26469706673582212205aa624f01aeacae044ff9989fb2c19d7c1b42a8c4a0a0c427dbdb95f6e696b1764736f6c63430008040033")
+
 func TestExecution(t *testing.T) {
 	state := state.NewFakeState()
 
-	deployCode := common.Hex2Bytes("608060405234801561001057600080fd5b506108a3806100206000396000f3fe608060405234801561001057600080fd5b506004361061004c5760003560e01c80633a9bbfcd146100515780634c803feb146100815780636b83dd2e146100b1578063b5463014146100e1575b600080fd5b61006b60048036038101906100669190610614565b610111565b6040516100789190610675565b60405180910390f35b61009b60048036038101906100969190610614565b610335565b6040516100a89190610675565b60405180910390f35b6100cb60048036038101906100c69190610614565b610496565b6040516100d89190610675565b60405180910390f35b6100fb60048036038101906100f69190610614565b6104f4565b6040516101089190610675565b60405180910390f35b6000806001836101219190610690565b67ffffffffffffffff811115610160577f4e487b7100000000000000000000000000000000000000000000000000000000600052604160045260246000fd5b60405190808252806020026020018201604052801561018e5781602001602082028036833780820191505090505b50905060005b8381116102eb57600181116101ee57808282815181106101dd577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010181815250506102d8565b816002826101fc9190610771565b81518110610233577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010151826001836102499190610771565b81518110610280577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b60200260200101516102929190610690565b8282815181106102cb577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010181815250505b80806102e3906107af565b915050610194565b50808381518110610325577f4e487b7100000000000000000000000000000000000000000000000000000000600052603260045260246000fd5b6020026020010151915050919050565b6000808214156103485760009050610491565b600182141561035a5760019050610491565b3073ffffffffffffffffffffffffffffffffffffffff16634c803feb6002846103839190610771565b6040518263ffffffff1660e01b815260040161039f9190610675565b60206040518083038186803b1580156103b757600080fd5b505afa1580156103cb573d6000803e3d6000fd5b505050506040513d601f19601f820116820180604052508101906103ef919061063d565b3073ffffffffffffffffffffffffffffffffffffffff16634c803feb6001856104189190610771565b6040518263ffffffff1660e01b81526004016104349190610675565b60206040518083038186803b15801561044c57600080fd5b505afa158015610460573d6000803e3d6000fd5b505050506040513d601f19601f82011682018060405250810190610484919061063d565b61048e9190610690565b90505b919050565b6000808214156104a957600090506104ef565b600060019050600191506000600290505b838110156104ec57600083836104d09190610690565b90508392508093505080806104e4906107af565b9150506104ba565b50505b919050565b60008082141561050757600090506105e5565b600060028361051691906106e6565b90506000600190505b81811161053257600181901b905061051f565b600181901c90506001925060006001905060005b60008311156105e057818261055b9190610717565b85866105679190610717565b6105719190610690565b9050600083871611156105ab5784600261058b9190610717565b826105969190610690565b826105a19190610717565b91508094506105d4565b848260026105b99190610717565b6105c39190610771565b856105ce9190610717565b94508091505b600183901c9250610546565b505050505b919050565b6000813590506105f981610856565b92915050565b60008151905061060e81610856565b92915050565b60006020828403121561062657600080fd5b6000610634848285016105ea565b91505092915050565b60006020828403121561064f57600080fd5b600061065d848285016105ff565b91505092915050565b61066f816107a5565b82525050565b600060208201905061068a6000830184610666565b92915050565b600061069b826107a5565b91506106a6836107a5565b9250827fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff038211156106db576106da6107f8565b5b828201905092915050565b60006106f1826107a5565b91506106fc836107a5565b92508261070c5761070b610827565b5b828204905092915050565b6000610722826107a5565b915061072d836107a5565b9250817fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff0483118215151615610766576107656107f8565b5b828202905092915050565b600061077c826107a5565b9150610787836107a5565b92508282101561079a576107996107f8565b5b828203905092915050565b6000819050919050565b60006107ba826107a5565b91507fffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff8214156107ed576107ec6107f8565b5b600182019050919050565b7f4e487b7100000000000000000000000000000000000000000000000000000000600052601160045260246000fd5b7f4e487b7100000000000000000000000000000000000000000000000000000000600052601260045260246000fd5b61085f816107a5565b811461086a57600080fd5b5056fea26469706673582212205aa624f01aeacae044ff9989fb2c19d7c1b42a8c4a0a0c427dbdb95f6e696b1764736f6c63430008040033")
+	deployCode := common.CopyBytes(fibDeployCode)
 	user := common.BytesToAddress([]byte("user"))
 	state.CreateAccount(user)
 	state.SetBalance(user, big.NewInt(1000000000000000000))
@@ -21,6 +34,7 @@ func TestExecution(t *testing.T) {
 	setDefaults(cfg)
 	cfg.FakeState = state
 	cfg.Origin = user
+	cfg.EVMConfig.EnableDFG = true
 	evm := NewEnv(cfg)
 	userRef := vm.AccountRef(user)
 
@@ -46,6 +60,774 @@ func TestExecution(t *testing.T) {
 	}
 	t.Log("Contract returned", res)
 
+	// Rendered out of the source tree, so that running the tests leaves it clean
 	graph := evm.Graph
-	vm.VisualizeGraph(graph)
+	if err := vm.ExportGraphFile(filepath.Join(t.TempDir(), "graph.html"), graph, new(vm.EChartsExporter)); err != nil {
+		t.Fatalf("Failed to render graph: %v", err)
+	}
+}
+
+// TestDFGMatchesStock checks that the dependency-tracking interpreter path
+// produces exactly the same results as the stock one.
+func TestDFGMatchesStock(t *testing.T) {
+	inputs := [][]byte{
+		common.Hex2Bytes("3a9bbfcd0000000000000000000000000000000000000000000000000000000000000005"),
+		common.Hex2Bytes("4c803feb0000000000000000000000000000000000000000000000000000000000000005"),
+		common.Hex2Bytes("6b83dd2e0000000000000000000000000000000000000000000000000000000000000005"),
+		common.Hex2Bytes("b54630140000000000000000000000000000000000000000000000000000000000000005"),
+		common.Hex2Bytes("deadbeef"),
+	}
+	type result struct {
+		ret  [][]byte
+		gas  []uint64
+		errs []error
+		root common.Hash
+	}
+	run := func(enableDFG bool) result {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		cfg := &Config{State: statedb}
+		cfg.EVMConfig.EnableDFG = enableDFG
+
+		var res result
+		_, addr, _, err := Create(common.CopyBytes(fibDeployCode), cfg)
+		if err != nil {
+			t.Fatalf("failed to deploy contract: %v", err)
+		}
+		for _, input := range inputs {
+			ret, gas, err := Call(addr, input, cfg)
+			res.ret = append(res.ret, ret)
+			res.gas = append(res.gas, gas)
+			res.errs = append(res.errs, err)
+		}
+		res.root = statedb.IntermediateRoot(true)
+		return res
+	}
+	stock, dfg := run(false), run(true)
+	for i := range inputs {
+		if !bytes.Equal(stock.ret[i], dfg.ret[i]) {
+			t.Errorf("input %d: return mismatch: stock %x, dfg %x", i, stock.ret[i], dfg.ret[i])
+		}
+		if stock.gas[i] != dfg.gas[i] {
+			t.Errorf("input %d: gas mismatch: stock %d, dfg %d", i, stock.gas[i], dfg.gas[i])
+		}
+		if stock.errs[i] != dfg.errs[i] {
+			t.Errorf("input %d: error mismatch: stock %v, dfg %v", i, stock.errs[i], dfg.errs[i])
+		}
+	}
+	if stock.root != dfg.root {
+		t.Errorf("state root mismatch: stock %x, dfg %x", stock.root, dfg.root)
+	}
+}
+
+// TestDFGCancunOpcodes runs the opcodes added since Istanbul through the
+// dependency-tracking interpreter, checking that transient storage and memory
+// copies are linked to their writers.
+func TestDFGCancunOpcodes(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH0), byte(vm.TSTORE), // tstore(0, 42)
+		byte(vm.PUSH0), byte(vm.TLOAD), // tload(0)
+		byte(vm.PUSH0), byte(vm.MSTORE), // mstore(0, tload(0))
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH0), byte(vm.PUSH1), 0x20, byte(vm.MCOPY), // mcopy(32, 0, 32)
+		byte(vm.CHAINID), byte(vm.BASEFEE), byte(vm.ADD),
+		byte(vm.SELFBALANCE), byte(vm.ADD),
+		byte(vm.PUSH0), byte(vm.BLOBHASH), byte(vm.ADD),
+		byte(vm.BLOBBASEFEE), byte(vm.ADD), byte(vm.POP),
+		byte(vm.PUSH1), 0x01, byte(vm.PUSH0), byte(vm.PUSH0), byte(vm.LOG1), // log1(0, 0, 1)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x20, byte(vm.MLOAD), // mload(32)
+		byte(vm.PUSH0), byte(vm.MSTORE), // mstore(0, mload(32))
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH0), byte(vm.RETURN), // return(0, 32)
+	}
+	chainConfig := *params.AllEthashProtocolChanges
+	chainConfig.ShanghaiTime = new(uint64)
+	chainConfig.CancunTime = new(uint64)
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb, ChainConfig: &chainConfig, Random: &common.Hash{}}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+
+	address := common.BytesToAddress([]byte("contract"))
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+
+	evm := NewEnv(cfg)
+	ret, _, err := evm.Call(vm.AccountRef(cfg.Origin), address, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if want := common.LeftPadBytes([]byte{0x2a}, 32); !bytes.Equal(ret, want) {
+		t.Fatalf("return mismatch: have %x, want %x", ret, want)
+	}
+	// Locate the vertices by opcode, only PUSH0 and MSTORE run more than once
+	vertices := make(map[string][]vm.Metadata)
+	for _, v := range graphVertices(evm.Graph) {
+		vertices[v.OpCode] = append(vertices[v.OpCode], v)
+	}
+	for _, op := range []vm.OpCode{vm.PUSH0, vm.TSTORE, vm.TLOAD, vm.MCOPY, vm.CHAINID, vm.BASEFEE, vm.SELFBALANCE, vm.BLOBHASH, vm.BLOBBASEFEE} {
+		if len(vertices[op.String()]) == 0 {
+			t.Errorf("no vertex for %v", op)
+		}
+	}
+	dependsOn := func(target, source vm.Metadata) bool {
+		return len(evm.Graph.Edges(source.Index, target.Index)) > 0
+	}
+	tstore, tload, mcopy, mload := vertices["TSTORE"][0], vertices["TLOAD"][0], vertices["MCOPY"][0], vertices["MLOAD"][0]
+	if !dependsOn(tload, tstore) {
+		t.Errorf("TLOAD is not linked to the TSTORE it reads")
+	}
+	// MCOPY keeps the provenance of the copied bytes, the MLOAD of the copy
+	// reads the bytes written by the first MSTORE.
+	var first vm.Metadata
+	for _, v := range vertices["MSTORE"] {
+		if first.OpCode == "" || v.Index < first.Index {
+			first = v
+		}
+	}
+	if !dependsOn(mload, first) {
+		t.Errorf("MLOAD of the copied range is not linked to the original MSTORE")
+	}
+	if !dependsOn(mcopy, first) {
+		t.Errorf("MCOPY is not linked to the writer of its source range")
+	}
+	for op, source := range map[string]vm.Metadata{"CHAINID": vm.ContextSourceMeta, "BASEFEE": vm.ContextSourceMeta, "BLOBHASH": vm.TxInputSourceMeta, "BLOBBASEFEE": vm.ContextSourceMeta} {
+		if !dependsOn(vertices[op][0], source) {
+			t.Errorf("%s does not depend on the %v source", op, source.SourceKind())
+		}
+	}
+}
+
+// TestDFGCallFrames checks that every frame has its own shadow stack and
+// memory, and that data crossing a call boundary keeps its provenance.
+func TestDFGCallFrames(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		callee = common.BytesToAddress([]byte("callee"))
+	)
+	calleeCode := []byte{
+		byte(vm.PUSH1), 0x7f, // leftover stack item, must not leak into the caller
+		byte(vm.PUSH1), 0x00, byte(vm.CALLDATALOAD), // calldataload(0)
+		byte(vm.PUSH1), 0x01, byte(vm.ADD),
+		byte(vm.PUSH1), 0x40, byte(vm.MSTORE), // mstore(64, calldataload(0)+1)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x40, byte(vm.RETURN), // return(64, 32)
+	}
+	callerCode := []byte{
+		byte(vm.PUSH1), 0x29, byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, 41)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x20, // retSize, retOffset
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, // inSize, inOffset
+		byte(vm.PUSH1), 0x00, byte(vm.PUSH20)}
+	callerCode = append(callerCode, callee.Bytes()...)
+	callerCode = append(callerCode,
+		byte(vm.GAS), byte(vm.CALL), byte(vm.POP), // call(gas, callee, 0, 0, 32, 32, 32)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.RETURNDATACOPY), // returndatacopy(0, 0, 32)
+		byte(vm.PUSH1), 0x20, byte(vm.MLOAD), // mload(32)
+		byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, mload(32))
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN), // return(0, 32)
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+
+	statedb.CreateAccount(caller)
+	statedb.SetCode(caller, callerCode)
+	statedb.CreateAccount(callee)
+	statedb.SetCode(callee, calleeCode)
+
+	evm := NewEnv(cfg)
+	ret, _, err := evm.Call(vm.AccountRef(cfg.Origin), caller, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index)
+	if err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if want := common.LeftPadBytes([]byte{0x2a}, 32); !bytes.Equal(ret, want) {
+		t.Fatalf("return mismatch: have %x, want %x", ret, want)
+	}
+	// vertex returns the first instance of op run by addr
+	vertex := func(op vm.OpCode, addr common.Address) vm.Metadata {
+		var found *vm.Metadata
+		for _, v := range graphVertices(evm.Graph) {
+			if v := v; v.OpCode == op.String() && v.Addr == addr && (found == nil || v.Index < found.Index) {
+				found = &v
+			}
+		}
+		if found == nil {
+			t.Fatalf("no %v vertex in %x", op, addr)
+		}
+		return *found
+	}
+	dependsOn := func(target, source vm.Metadata) bool {
+		return len(evm.Graph.Edges(source.Index, target.Index)) > 0
+	}
+	var (
+		argWriter = vertex(vm.MSTORE, caller)
+		retWriter = vertex(vm.MSTORE, callee)
+	)
+	if !dependsOn(vertex(vm.CALLDATALOAD, callee), argWriter) {
+		t.Errorf("CALLDATALOAD is not linked to the caller's writer of the arguments")
+	}
+	if !dependsOn(vertex(vm.RETURNDATACOPY, caller), retWriter) {
+		t.Errorf("RETURNDATACOPY is not linked to the callee's writer of the return data")
+	}
+	if !dependsOn(vertex(vm.MLOAD, caller), retWriter) {
+		t.Errorf("MLOAD of the ret range is not linked to the callee's writer of the return data")
+	}
+}
+
+// TestDFGRevert checks that the shadow storage is rolled back together with
+// the state, and that the vertices of the reverted frame are marked or dropped.
+func TestDFGRevert(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		callee = common.BytesToAddress([]byte("callee"))
+	)
+	// Without calldata the callee writes slot 0 and reverts, with calldata it
+	// returns the content of slot 0.
+	calleeCode := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0x0d, byte(vm.JUMPI),
+		byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.SSTORE), // sstore(0, 1)
+		byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.REVERT), // revert(0, 0)
+		byte(vm.JUMPDEST),
+		byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, sload(0))
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN), // return(0, 32)
+	}
+	var callerCode []byte
+	for _, inSize := range []byte{0, 1} {
+		callerCode = append(callerCode,
+			byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, // retSize, retOffset
+			byte(vm.PUSH1), inSize, byte(vm.PUSH1), 0x00, // inSize, inOffset
+			byte(vm.PUSH1), 0x00, byte(vm.PUSH20))
+		callerCode = append(callerCode, callee.Bytes()...)
+		callerCode = append(callerCode, byte(vm.GAS), byte(vm.CALL), byte(vm.POP))
+	}
+	for _, drop := range []bool{false, true} {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		cfg := &Config{State: statedb}
+		setDefaults(cfg)
+		cfg.EVMConfig.EnableDFG = true
+		cfg.EVMConfig.DFGDropReverted = drop
+
+		statedb.CreateAccount(caller)
+		statedb.SetCode(caller, callerCode)
+		statedb.CreateAccount(callee)
+		statedb.SetCode(callee, calleeCode)
+
+		evm := NewEnv(cfg)
+		if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), caller, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		var sstore, sload *vm.Metadata
+		for _, v := range graphVertices(evm.Graph) {
+			v := v
+			switch v.OpCode {
+			case vm.SSTORE.String():
+				sstore = &v
+			case vm.SLOAD.String():
+				sload = &v
+			}
+		}
+		if sload == nil {
+			t.Fatalf("drop %v: no SLOAD vertex", drop)
+		}
+		if len(evm.Graph.Edges(vm.StorageSourceMeta.Index, sload.Index)) == 0 {
+			t.Errorf("drop %v: SLOAD does not read the pre-transaction value", drop)
+		}
+		switch {
+		case drop && sstore != nil:
+			t.Errorf("reverted SSTORE kept in the graph")
+		case !drop && sstore == nil:
+			t.Errorf("reverted SSTORE missing from the graph")
+		case !drop && !sstore.Reverted:
+			t.Errorf("reverted SSTORE not marked")
+		case !drop && sload.Reverted:
+			t.Errorf("SLOAD of a successful frame marked reverted")
+		}
+		if sstore != nil {
+			if len(evm.Graph.Edges(sstore.Index, sload.Index)) > 0 {
+				t.Errorf("drop %v: SLOAD depends on the reverted SSTORE", drop)
+			}
+		}
+	}
+}
+
+// TestDFGAccountProvenance checks that the code deployed by CREATE is linked
+// to the init code which returned it, and the balances to the transfers and
+// self-destructs which wrote them.
+func TestDFGAccountProvenance(t *testing.T) {
+	factory := common.BytesToAddress([]byte("factory"))
+	// The deployed code sends the balance back to its caller
+	initCode := []byte{
+		byte(vm.PUSH1), 0x02, byte(vm.PUSH1), 0x0c, byte(vm.PUSH1), 0x00, byte(vm.CODECOPY), // codecopy(0, 12, 2)
+		byte(vm.PUSH1), 0x02, byte(vm.PUSH1), 0x00, byte(vm.RETURN), // return(0, 2)
+		byte(vm.CALLER), byte(vm.SELFDESTRUCT),
+	}
+	factoryCode := append([]byte{byte(vm.PUSH14)}, initCode...)
+	factoryCode = append(factoryCode,
+		byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, initCode)
+		byte(vm.PUSH1), 0x0e, byte(vm.PUSH1), 0x12, byte(vm.PUSH1), 0x00, byte(vm.CREATE), // create(0, 18, 14)
+		byte(vm.DUP1), byte(vm.EXTCODESIZE), byte(vm.POP),
+		byte(vm.DUP1), byte(vm.EXTCODEHASH), byte(vm.POP),
+		byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00,
+		byte(vm.PUSH1), 0x01, byte(vm.DUP6), byte(vm.GAS), byte(vm.CALL), byte(vm.POP), // call(gas, child, 1, 0, 0, 0, 0)
+		byte(vm.BALANCE), byte(vm.POP), // balance(child)
+		byte(vm.SELFBALANCE), byte(vm.POP), byte(vm.STOP),
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+	statedb.CreateAccount(factory)
+	statedb.SetCode(factory, factoryCode)
+	statedb.AddBalance(factory, big.NewInt(10))
+	child := crypto.CreateAddress(factory, statedb.GetNonce(factory))
+
+	evm := NewEnv(cfg)
+	if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), factory, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if code := statedb.GetCode(child); !bytes.Equal(code, initCode[12:]) {
+		t.Fatalf("deployed code mismatch: have %x, want %x", code, initCode[12:])
+	}
+	vertex := func(op vm.OpCode, addr common.Address) vm.Metadata {
+		for _, v := range graphVertices(evm.Graph) {
+			if v.OpCode == op.String() && v.Addr == addr {
+				return v
+			}
+		}
+		t.Fatalf("no %v vertex in %x", op, addr)
+		return vm.Metadata{}
+	}
+	var (
+		initWriter = vertex(vm.MSTORE, factory)
+		create     = vertex(vm.CREATE, factory)
+		codeCopy   = vertex(vm.CODECOPY, child)
+		deployment = vertex(vm.RETURN, child)
+		call       = vertex(vm.CALL, factory)
+		destruct   = vertex(vm.SELFDESTRUCT, child)
+	)
+	for i, tc := range []struct {
+		source, target vm.Metadata
+		want           vm.Edge
+	}{
+		{initWriter, create, vm.Edge{Kind: vm.MemoryEdge, Offset: 18, Size: 14}},
+		{create, codeCopy, vm.Edge{Kind: vm.CodeEdge, Address: child}},
+		{codeCopy, deployment, vm.Edge{Kind: vm.MemoryEdge, Offset: 0, Size: 2}},
+		{deployment, vertex(vm.EXTCODESIZE, factory), vm.Edge{Kind: vm.CodeEdge, Address: child}},
+		{deployment, vertex(vm.EXTCODEHASH, factory), vm.Edge{Kind: vm.CodeEdge, Address: child}},
+		{deployment, call, vm.Edge{Kind: vm.CodeEdge, Address: child}},
+		{vm.BalanceSourceMeta, call, vm.Edge{Kind: vm.BalanceEdge, Address: factory}},
+		{call, destruct, vm.Edge{Kind: vm.BalanceEdge, Address: child}},
+		{call, destruct, vm.Edge{Kind: vm.BalanceEdge, Address: factory}},
+		{destruct, vertex(vm.BALANCE, factory), vm.Edge{Kind: vm.BalanceEdge, Address: child}},
+		{destruct, vertex(vm.SELFBALANCE, factory), vm.Edge{Kind: vm.BalanceEdge, Address: factory}},
+	} {
+		found := false
+		for _, e := range evm.Graph.Edges(tc.source.Index, tc.target.Index) {
+			found = found || e == tc.want
+		}
+		if !found {
+			t.Errorf("test %d: no edge %+v from %s to %s", i, tc.want, tc.source.OpCode, tc.target.OpCode)
+		}
+	}
+}
+
+// TestDFGPrecompileAndLog checks that a precompile run is a vertex reading the
+// call input and writing the returned data, and that LOGn is a sink of its
+// topics and data.
+func TestDFGPrecompileAndLog(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, 42)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00,
+		byte(vm.PUSH1), 0x02, byte(vm.GAS), byte(vm.STATICCALL), byte(vm.POP), // staticcall(gas, sha256, 0, 32, 32, 32)
+		byte(vm.PUSH1), 0x20, byte(vm.MLOAD), // mload(32)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.LOG1), // log1(0, 32, mload(32))
+		byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.RETURN), // return(0, 0)
+	}
+	var (
+		address = common.BytesToAddress([]byte("contract"))
+		sha256  = common.BytesToAddress([]byte{2})
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+
+	evm := NewEnv(cfg)
+	if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), address, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if logs := statedb.Logs(); len(logs) != 1 || len(logs[0].Topics) != 1 {
+		t.Fatalf("have logs %v, want one with one topic", logs)
+	}
+	vertices := make(map[string]vm.Metadata)
+	for _, v := range graphVertices(evm.Graph) {
+		vertices[v.OpCode] = v
+	}
+	run, ok := vertices["sha256"]
+	if !ok {
+		t.Fatal("no vertex of the sha256 run")
+	}
+	if run.Addr != sha256 || run.Depth != 2 || run.Gas == 0 {
+		t.Errorf("sha256 attributes mismatch: have address %x depth %d gas %d", run.Addr, run.Depth, run.Gas)
+	}
+	log := vertices[vm.LOG1.String()]
+	for i, tc := range []struct {
+		source, target vm.Metadata
+		want           []vm.Edge
+	}{
+		{vertices[vm.MSTORE.String()], run, []vm.Edge{{Kind: vm.CallEdge, Offset: 0, Size: 32}}},
+		{vertices[vm.STATICCALL.String()], run, []vm.Edge{{Kind: vm.CallEdge}}},
+		{run, vertices[vm.MLOAD.String()], []vm.Edge{{Kind: vm.MemoryEdge, Offset: 32, Size: 32}}},
+		{vertices[vm.MLOAD.String()], log, []vm.Edge{{Kind: vm.StackEdge, Operand: 2}}},
+		{vertices[vm.MSTORE.String()], log, []vm.Edge{{Kind: vm.MemoryEdge, Offset: 0, Size: 32}}},
+	} {
+		if have := evm.Graph.Edges(tc.source.Index, tc.target.Index); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("test %d: edges from %s to %s: have %+v, want %+v", i, tc.source.OpCode, tc.target.OpCode, have, tc.want)
+		}
+	}
+	if targets := evm.Graph.Targets(log.Index); len(targets) != 0 {
+		t.Errorf("LOG1 has dependents %v", targets)
+	}
+}
+
+// TestDFGControlDependencies checks that the instructions guarded by a branch
+// get a control edge from its JUMPI, and those after the join point don't.
+func TestDFGControlDependencies(t *testing.T) {
+	code := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0x07, byte(vm.JUMPI), // if calldatasize == 0 {
+		byte(vm.PUSH1), 0x02, byte(vm.POP), //   guarded
+		byte(vm.JUMPDEST), // }
+		byte(vm.PUSH1), 0x03, byte(vm.POP), byte(vm.STOP),
+	}
+	address := common.BytesToAddress([]byte("contract"))
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+
+	evm := NewEnv(cfg)
+	if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), address, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	vertices := make(map[uint64]vm.Metadata)
+	for _, v := range graphVertices(evm.Graph) {
+		if v.Index != vm.TxInputSourceMeta.Index {
+			vertices[v.Pc] = v
+		}
+	}
+	kinds := func(source, target uint64) []vm.EdgeKind {
+		return evm.Graph.Kinds(vertices[source].Index, vertices[target].Index)
+	}
+	if have, want := kinds(3, 4), []vm.EdgeKind{vm.ControlEdge}; !reflect.DeepEqual(have, want) {
+		t.Errorf("guarded PUSH1: have edge kinds %v, want %v", have, want)
+	}
+	if have, want := kinds(4, 6), []vm.EdgeKind{vm.StackEdge}; !reflect.DeepEqual(have, want) {
+		t.Errorf("guarded POP: have edge kinds %v, want %v", have, want)
+	}
+	if have, want := kinds(3, 6), []vm.EdgeKind{vm.ControlEdge}; !reflect.DeepEqual(have, want) {
+		t.Errorf("guarded POP: have control edge kinds %v, want %v", have, want)
+	}
+	for _, pc := range []uint64{8, 10, 11} {
+		if kinds(3, pc) != nil {
+			t.Errorf("instruction at pc %d after the join is control dependent on the branch", pc)
+		}
+	}
+}
+
+// TestDFGEdgeAttributes checks that edges tell which resource links their
+// ends, and that vertices carry what their instruction did.
+func TestDFGEdgeAttributes(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, 42)
+		byte(vm.PUSH1), 0x05, byte(vm.PUSH1), 0x07, byte(vm.SSTORE), // sstore(7, 5)
+		byte(vm.PUSH1), 0x07, byte(vm.SLOAD), // sload(7)
+		byte(vm.PUSH1), 0x00, byte(vm.MLOAD), // mload(0)
+		byte(vm.ADD), byte(vm.POP), byte(vm.STOP),
+	}
+	address := common.BytesToAddress([]byte("contract"))
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+	statedb.AddAddressToAccessList(address)
+
+	evm := NewEnv(cfg)
+	if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), address, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	vertices := make(map[uint64]vm.Metadata)
+	for _, v := range graphVertices(evm.Graph) {
+		if v.Index != vm.TxInputSourceMeta.Index {
+			vertices[v.Pc] = v
+		}
+	}
+	edges := func(source, target uint64) []vm.Edge {
+		return evm.Graph.Edges(vertices[source].Index, vertices[target].Index)
+	}
+	for i, tc := range []struct {
+		source, target uint64
+		want           []vm.Edge
+	}{
+		{4, 15, []vm.Edge{{Kind: vm.MemoryEdge, Offset: 0, Size: 32}}},
+		{9, 12, []vm.Edge{{Kind: vm.StorageEdge, Address: address, Slot: common.BigToHash(big.NewInt(7))}}},
+		{7, 9, []vm.Edge{{Kind: vm.StackEdge, Operand: 0}}},
+		{5, 9, []vm.Edge{{Kind: vm.StackEdge, Operand: 1}}},
+		{15, 16, []vm.Edge{{Kind: vm.StackEdge, Operand: 0}}},
+		{12, 16, []vm.Edge{{Kind: vm.StackEdge, Operand: 1}}},
+	} {
+		if have := edges(tc.source, tc.target); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("test %d: edges from pc %d to pc %d: have %+v, want %+v", i, tc.source, tc.target, have, tc.want)
+		}
+	}
+	add := vertices[16]
+	if add.Value == nil || add.Value.Uint64() != 47 {
+		t.Errorf("ADD value mismatch: have %v, want 47", add.Value)
+	}
+	if add.Depth != 1 || add.Gas != 3 {
+		t.Errorf("ADD attributes mismatch: have depth %d gas %d, want depth 1 gas 3", add.Depth, add.Gas)
+	}
+	if sstore := vertices[9]; sstore.Value != nil || sstore.Gas == 0 {
+		t.Errorf("SSTORE attributes mismatch: have value %v gas %d", sstore.Value, sstore.Gas)
+	}
+}
+
+// TestDFGValueFlow checks that value-flow mode leaves the stack shuffling out
+// of the graph while keeping the reachability between the other vertices.
+func TestDFGValueFlow(t *testing.T) {
+	var (
+		caller = common.BytesToAddress([]byte("caller"))
+		callee = common.BytesToAddress([]byte("callee"))
+	)
+	calleeCode := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0x02, byte(vm.DUP2), byte(vm.MUL), // calldatasize * 2
+		byte(vm.SWAP1), byte(vm.ADD), // + calldatasize
+		byte(vm.PUSH1), 0x00, byte(vm.SSTORE), // sstore(0, ...)
+		byte(vm.PUSH1), 0x0e, byte(vm.JUMP), byte(vm.INVALID), byte(vm.JUMPDEST),
+		byte(vm.CALLVALUE), byte(vm.POP),
+		byte(vm.PUSH1), 0x00, byte(vm.SLOAD), byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, sload(0))
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN), // return(0, 32)
+	}
+	callerCode := []byte{
+		byte(vm.CALLER), byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, caller)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, // retSize, retOffset
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, // inSize, inOffset
+		byte(vm.PUSH1), 0x00, byte(vm.PUSH20)}
+	callerCode = append(callerCode, callee.Bytes()...)
+	callerCode = append(callerCode,
+		byte(vm.GAS), byte(vm.CALL), byte(vm.POP), // call(gas, callee, 0, 0, 32, 0, 32)
+		byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0x00, byte(vm.RETURN), // return(0, 32)
+	)
+	run := func(valueFlow bool) *vm.DependencyGraph {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		cfg := &Config{State: statedb}
+		setDefaults(cfg)
+		cfg.EVMConfig.EnableDFG = true
+		cfg.EVMConfig.DFGValueFlow = valueFlow
+
+		statedb.CreateAccount(caller)
+		statedb.SetCode(caller, callerCode)
+		statedb.CreateAccount(callee)
+		statedb.SetCode(callee, calleeCode)
+
+		evm := NewEnv(cfg)
+		ret, _, err := evm.Call(vm.AccountRef(cfg.Origin), caller, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index)
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		if want := common.LeftPadBytes([]byte{0x60}, 32); !bytes.Equal(ret, want) {
+			t.Fatalf("return mismatch: have %x, want %x", ret, want)
+		}
+		return evm.Graph
+	}
+	full, compact := run(false), run(true)
+
+	// Both runs execute the same steps, so the vertices have the same ids
+	type key = vm.VertexID
+	keyOf := func(v vm.Metadata) key {
+		return v.ID
+	}
+	shuffle := func(v vm.Metadata) bool {
+		return strings.HasPrefix(v.OpCode, "PUSH") || strings.HasPrefix(v.OpCode, "DUP") || strings.HasPrefix(v.OpCode, "SWAP") || v.OpCode == "POP"
+	}
+	// reach returns the vertices reachable from every vertex that is kept in
+	// value-flow mode
+	reach := func(g *vm.DependencyGraph) map[key]map[key]bool {
+		reached := make(map[key]map[key]bool)
+		for _, v := range graphVertices(g) {
+			if shuffle(v) {
+				continue
+			}
+			seen := map[int]bool{v.Index: true}
+			queue := []int{v.Index}
+			reached[keyOf(v)] = make(map[key]bool)
+			for len(queue) > 0 {
+				n := queue[0]
+				queue = queue[1:]
+				for _, target := range g.Targets(n) {
+					if seen[target] {
+						continue
+					}
+					seen[target] = true
+					queue = append(queue, target)
+					if to, _ := g.Vertex(target); !shuffle(to) {
+						reached[keyOf(v)][keyOf(to)] = true
+					}
+				}
+			}
+		}
+		return reached
+	}
+	for _, v := range graphVertices(compact) {
+		if shuffle(v) {
+			t.Errorf("value-flow graph has a %s vertex", v.OpCode)
+		}
+	}
+	if compact.NumVertices() >= full.NumVertices() {
+		t.Errorf("value-flow graph not smaller: have %d vertices, full graph %d", compact.NumVertices(), full.NumVertices())
+	}
+	if have, want := reach(compact), reach(full); !reflect.DeepEqual(have, want) {
+		t.Errorf("reachability mismatch:\nhave %v\nwant %v", have, want)
+	}
+	// The constant multiplied with is an attribute of the MUL
+	for _, v := range graphVertices(compact) {
+		if v.OpCode != "MUL" {
+			continue
+		}
+		constants := compact.Constants(v.Index)
+		if len(constants) != 1 || constants[0].Operand != 1 || constants[0].Pc != 1 || constants[0].Value.Uint64() != 2 {
+			t.Errorf("MUL constants mismatch: have %+v", constants)
+		}
+	}
+}
+
+// dependencyLogger records the dependencies reported while the graph is built,
+// and the shadow stack and memory of the ADD.
+type dependencyLogger struct {
+	*logger.StructLogger
+	sources     map[string][]string // target opcode to source opcodes
+	calls       map[int]int         // target index to the calls reporting it
+	edges       int
+	unannotated int // targets reported before their depth was annotated
+	stack       []vm.Metadata
+	memory      []vm.Metadata
+}
+
+func (l *dependencyLogger) NeedsGraph() bool { return true }
+
+func (l *dependencyLogger) CaptureDependency(sources []vm.Metadata, target vm.Metadata) {
+	for _, v := range sources {
+		l.sources[target.OpCode] = append(l.sources[target.OpCode], v.OpCode)
+	}
+	l.calls[target.Index]++
+	if target.Depth == 0 {
+		l.unannotated++
+	}
+	l.edges += len(sources)
+}
+
+func (l *dependencyLogger) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	if op == vm.ADD {
+		l.stack, l.memory = scope.MetaStackData(), scope.MetaMemoryWriters(0, 64)
+	}
+}
+
+// TestDFGDependencyLogger checks that a DependencyLogger follows the edges of
+// the graph as they are recorded, and can read the shadow stack and memory.
+func TestDFGDependencyLogger(t *testing.T) {
+	code := []byte{
+		byte(vm.PUSH1), 0x2a, byte(vm.PUSH1), 0x00, byte(vm.MSTORE), // mstore(0, 42)
+		byte(vm.PUSH1), 0x00, byte(vm.MLOAD), byte(vm.CALLER), byte(vm.ADD), // mload(0) + caller
+		byte(vm.STOP),
+	}
+	address := common.BytesToAddress([]byte("contract"))
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	tracer := &dependencyLogger{StructLogger: logger.NewStructLogger(nil), sources: make(map[string][]string), calls: make(map[int]int)}
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.Tracer = tracer
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+
+	evm := NewEnv(cfg)
+	if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), address, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if tracer.edges != evm.Graph.NumEdges() {
+		t.Errorf("have %d edges reported, graph has %d", tracer.edges, evm.Graph.NumEdges())
+	}
+	// The edges of an instruction are reported at once when it is done, MLOAD
+	// reading both the stack and the memory
+	for index, calls := range tracer.calls {
+		if calls != 1 {
+			t.Errorf("vertex %d reported in %d calls", index, calls)
+		}
+	}
+	if tracer.unannotated != 0 {
+		t.Errorf("%d targets reported before being annotated", tracer.unannotated)
+	}
+	for target, want := range map[string][]string{
+		"MSTORE": {"PUSH1", "PUSH1"},
+		"MLOAD":  {"PUSH1", "MSTORE"},
+		"ADD":    {"CALLER", "MLOAD"},
+	} {
+		var have []string
+		for _, op := range tracer.sources[target] {
+			if !strings.HasPrefix(op, "source/") {
+				have = append(have, op)
+			}
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("%s: have sources %v, want %v", target, have, want)
+		}
+	}
+	if len(tracer.stack) != 2 || tracer.stack[0].OpCode != "MLOAD" || tracer.stack[1].OpCode != "CALLER" {
+		t.Errorf("have meta stack %+v", tracer.stack)
+	}
+	if len(tracer.memory) != 1 || tracer.memory[0].OpCode != "MSTORE" {
+		t.Errorf("have memory writers %+v", tracer.memory)
+	}
+}
+
+// BenchmarkDFGOverhead compares the interpreter recording the dependency graph
+// with the untraced one, on the loops of the iterative fibonacci.
+func BenchmarkDFGOverhead(b *testing.B) {
+	input := common.Hex2Bytes("3a9bbfcd0000000000000000000000000000000000000000000000000000000000000080")
+	for _, bench := range []struct {
+		name string
+		cfg  vm.Config
+	}{
+		{"untraced", vm.Config{}},
+		{"dfg", vm.Config{EnableDFG: true}},
+		{"valueflow", vm.Config{EnableDFG: true, DFGValueFlow: true}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			cfg := &Config{State: statedb, EVMConfig: bench.cfg}
+			_, addr, _, err := Create(common.CopyBytes(fibDeployCode), cfg)
+			if err != nil {
+				b.Fatalf("failed to deploy contract: %v", err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := Call(addr, input, cfg); err != nil {
+					b.Fatalf("call failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// graphVertices returns the vertices of the graph, in increasing order of index.
+func graphVertices(g *vm.DependencyGraph) []vm.Metadata {
+	var vertices []vm.Metadata
+	g.ForEachVertex(func(v vm.Metadata) error {
+		vertices = append(vertices, v)
+		return nil
+	})
+	return vertices
 }
@@ -41,7 +41,8 @@ func NewEnv(cfg *Config) *vm.EVM {
 		BlobBaseFee: cfg.BlobBaseFee,
 		Random:      cfg.Random,
 	}
-
-	// return vm.NewEVM(blockContext, txContext, cfg.State, cfg.ChainConfig, cfg.EVMConfig)
-	return vm.NewEVM(blockContext, txContext, cfg.FakeState, cfg.ChainConfig, cfg.EVMConfig)
+	if cfg.FakeState != nil {
+		return vm.NewEVM(blockContext, txContext, cfg.FakeState, cfg.ChainConfig, cfg.EVMConfig)
+	}
+	return vm.NewEVM(blockContext, txContext, cfg.State, cfg.ChainConfig, cfg.EVMConfig)
 }
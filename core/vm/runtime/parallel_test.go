@@ -0,0 +1,364 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package runtime
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
+)
+
+// counterCode increments storage slot 0.
+var counterCode = []byte{
+	byte(vm.PUSH1), 0, byte(vm.SLOAD),
+	byte(vm.PUSH1), 1, byte(vm.ADD),
+	byte(vm.PUSH1), 0, byte(vm.SSTORE),
+	byte(vm.STOP),
+}
+
+// appendCall appends a call of addr with 100000 gas, passing the first
+// argsSize bytes of memory and writing the output at 0.
+func appendCall(code []byte, op vm.OpCode, addr common.Address, argsSize, retSize byte) []byte {
+	code = append(code,
+		byte(vm.PUSH1), retSize, byte(vm.PUSH1), 0, // return data
+		byte(vm.PUSH1), argsSize, byte(vm.PUSH1), 0) // call data
+	if op == vm.CALL {
+		code = append(code, byte(vm.PUSH1), 0) // value
+	}
+	code = append(code, byte(vm.PUSH20))
+	code = append(code, addr.Bytes()...)
+	return append(code, byte(vm.PUSH3), 0x01, 0x86, 0xa0, byte(op))
+}
+
+// appendSpawn appends code spawning a call to target, leaving its id in
+// memory at 0.
+func appendSpawn(code []byte, target common.Address) []byte {
+	code = append(code, byte(vm.PUSH20))
+	code = append(code, target.Bytes()...)
+	code = append(code, byte(vm.PUSH1), 0, byte(vm.MSTORE))
+	code = appendCall(code, vm.CALL, vm.ParallelSpawnAddress, 32, 32)
+	return append(code, byte(vm.POP))
+}
+
+// parallelDriver returns code spawning a call to each of the targets, then
+// joining them and storing whether the join succeeded in slot 0.
+func parallelDriver(targets ...common.Address) []byte {
+	var code []byte
+	for _, target := range targets {
+		code = appendSpawn(code, target)
+	}
+	code = appendCall(code, vm.CALL, vm.ParallelJoinAddress, 0, 0)
+	return append(code, byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP))
+}
+
+func TestParallelCalls(t *testing.T) {
+	var (
+		driver = common.BytesToAddress([]byte("driver"))
+		first  = common.BytesToAddress([]byte("first"))
+		second = common.BytesToAddress([]byte("second"))
+	)
+	tests := []struct {
+		name    string
+		targets []common.Address
+		workers int
+		want    map[common.Address]uint64
+	}{
+		// Disjoint calls merge as they are
+		{"disjoint", []common.Address{first, second}, 2, map[common.Address]uint64{first: 1, second: 1}},
+		// The second call read the slot the first one wrote, and is run again
+		{"conflicting", []common.Address{first, first, first}, 2, map[common.Address]uint64{first: 3}},
+		// More calls than workers
+		{"queued", []common.Address{first, second, first, second}, 1, map[common.Address]uint64{first: 2, second: 2}},
+		// Without workers the addresses are plain accounts
+		{"disabled", []common.Address{first, second}, 0, map[common.Address]uint64{first: 0, second: 0}},
+	}
+	for _, tt := range tests {
+		for _, dfg := range []bool{false, true} {
+			statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			statedb.SetCode(driver, parallelDriver(tt.targets...))
+			statedb.SetCode(first, counterCode)
+			statedb.SetCode(second, counterCode)
+
+			cfg := &Config{State: statedb}
+			cfg.EVMConfig.ParallelCallWorkers = tt.workers
+			cfg.EVMConfig.EnableDFG = dfg
+			if _, _, err := Call(driver, nil, cfg); err != nil {
+				t.Fatalf("%s (dfg %v): call failed: %v", tt.name, dfg, err)
+			}
+			if got := statedb.GetState(driver, common.Hash{}); got != common.BigToHash(common.Big1) {
+				t.Errorf("%s (dfg %v): join failed", tt.name, dfg)
+			}
+			for addr, want := range tt.want {
+				if got := statedb.GetState(addr, common.Hash{}).Big().Uint64(); got != want {
+					t.Errorf("%s (dfg %v): counter %x = %d, want %d", tt.name, dfg, addr, got, want)
+				}
+			}
+		}
+	}
+}
+
+// TestParallelCallsDeterministic checks that the state and gas of a block of
+// parallel calls don't depend on the number of workers.
+func TestParallelCallsDeterministic(t *testing.T) {
+	var (
+		driver  = common.BytesToAddress([]byte("driver"))
+		targets []common.Address
+	)
+	for i := 0; i < 8; i++ {
+		targets = append(targets, common.BytesToAddress([]byte{byte(i % 3), 0xc0}))
+	}
+	run := func(workers int) (common.Hash, uint64) {
+		statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		statedb.SetCode(driver, parallelDriver(targets...))
+		for _, target := range targets {
+			statedb.SetCode(target, counterCode)
+		}
+		cfg := &Config{State: statedb}
+		cfg.EVMConfig.ParallelCallWorkers = workers
+		_, gas, err := Call(driver, nil, cfg)
+		if err != nil {
+			t.Fatalf("workers %d: call failed: %v", workers, err)
+		}
+		return statedb.IntermediateRoot(true), gas
+	}
+	root, gas := run(1)
+	for _, workers := range []int{2, 8} {
+		if r, g := run(workers); r != root || g != gas {
+			t.Errorf("workers %d: root %x gas %d, want root %x gas %d", workers, r, g, root, gas)
+		}
+	}
+}
+
+// TestParallelCallsFrames checks that the calls spawned by a frame which
+// reverts are dropped, that the gas left by the calls of a frame which
+// returned goes to the frame joining them, and that a static context cannot
+// join.
+func TestParallelCallsFrames(t *testing.T) {
+	var (
+		driver  = common.BytesToAddress([]byte("driver"))
+		spawner = common.BytesToAddress([]byte("spawner"))
+		joiner  = common.BytesToAddress([]byte("joiner"))
+		counter = common.BytesToAddress([]byte("counter"))
+	)
+	// The driver calls the spawner, then stores in slot 0 whether the join
+	// succeeded and in slot 1 whether it handed back more gas than it cost.
+	driverCode := appendCall(nil, vm.CALL, spawner, 0, 0)
+	driverCode = append(driverCode, byte(vm.POP), byte(vm.GAS))
+	driverCode = appendCall(driverCode, vm.CALL, vm.ParallelJoinAddress, 0, 0)
+	driverCode = append(driverCode, byte(vm.SWAP1), byte(vm.GAS), byte(vm.GT), byte(vm.PUSH1), 1, byte(vm.SSTORE),
+		byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP))
+
+	// The static driver spawns a call, then joins it through a static call
+	// of the joiner, which returns whether the join succeeded.
+	staticCode := appendSpawn(nil, counter)
+	staticCode = appendCall(staticCode, vm.STATICCALL, joiner, 0, 32)
+	staticCode = append(staticCode, byte(vm.POP), byte(vm.PUSH1), 0, byte(vm.MLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP))
+	joinerCode := appendCall(nil, vm.CALL, vm.ParallelJoinAddress, 0, 0)
+	joinerCode = append(joinerCode, byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN))
+
+	tests := []struct {
+		name    string
+		code    []byte // of the driver
+		spawner []byte
+		counter uint64
+		joined  bool
+		refund  bool
+	}{
+		{"returned", driverCode, append(appendSpawn(nil, counter), byte(vm.STOP)), 1, true, true},
+		{"reverted", driverCode, append(appendSpawn(nil, counter), byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.REVERT)), 0, true, false},
+		{"static", staticCode, nil, 0, false, false},
+	}
+	for _, tt := range tests {
+		for _, dfg := range []bool{false, true} {
+			statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+			statedb.SetCode(driver, tt.code)
+			statedb.SetCode(spawner, tt.spawner)
+			statedb.SetCode(joiner, joinerCode)
+			statedb.SetCode(counter, counterCode)
+
+			cfg := &Config{State: statedb}
+			cfg.EVMConfig.ParallelCallWorkers = 2
+			cfg.EVMConfig.EnableDFG = dfg
+			if _, _, err := Call(driver, nil, cfg); err != nil {
+				t.Fatalf("%s (dfg %v): call failed: %v", tt.name, dfg, err)
+			}
+			if got := statedb.GetState(counter, common.Hash{}).Big().Uint64(); got != tt.counter {
+				t.Errorf("%s (dfg %v): counter %d, want %d", tt.name, dfg, got, tt.counter)
+			}
+			if got := statedb.GetState(driver, common.Hash{}) == common.BigToHash(common.Big1); got != tt.joined {
+				t.Errorf("%s (dfg %v): joined %v, want %v", tt.name, dfg, got, tt.joined)
+			}
+			if got := statedb.GetState(driver, common.BigToHash(common.Big1)) == common.BigToHash(common.Big1); got != tt.refund {
+				t.Errorf("%s (dfg %v): gas handed back %v, want %v", tt.name, dfg, got, tt.refund)
+			}
+		}
+	}
+}
+
+// TestParallelCallsDFG checks that the graph of a parallel call is tied to
+// the transaction spawning it: its frame to the spawning CALL, its call data
+// to the writers of the input and its storage reads to the writes made before
+// the join, while the reads after the join see its writes.
+func TestParallelCallsDFG(t *testing.T) {
+	contract := common.BytesToAddress([]byte("contract"))
+	// With call data, the contract adds its first word to slot 0. Without, it
+	// stores 5 in slot 0, has that done in parallel with 1, then copies slot
+	// 0 to slot 1.
+	code := []byte{
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.JUMPI),
+		byte(vm.PUSH1), 5, byte(vm.PUSH1), 0, byte(vm.SSTORE), // sstore(0, 5)
+		byte(vm.ADDRESS), byte(vm.PUSH1), 0, byte(vm.MSTORE), // mstore(0, address)
+		byte(vm.PUSH1), 1, byte(vm.PUSH1), 32, byte(vm.MSTORE), // mstore(32, 1)
+	}
+	code = appendCall(code, vm.CALL, vm.ParallelSpawnAddress, 64, 0)
+	code = append(code, byte(vm.POP))
+	code = appendCall(code, vm.CALL, vm.ParallelJoinAddress, 0, 0)
+	code = append(code, byte(vm.POP),
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.SSTORE), byte(vm.STOP)) // sstore(1, sload(0))
+	code[2] = byte(len(code))
+	code = append(code, byte(vm.JUMPDEST),
+		byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.ADD),
+		byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP)) // sstore(0, sload(0) + calldataload(0))
+
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	cfg := &Config{State: statedb}
+	setDefaults(cfg)
+	cfg.EVMConfig.EnableDFG = true
+	cfg.EVMConfig.ParallelCallWorkers = 1
+	statedb.SetCode(contract, code)
+
+	evm := NewEnv(cfg)
+	rules := cfg.ChainConfig.Rules(evm.Context.BlockNumber, evm.Context.Random != nil, evm.Context.Time)
+	statedb.Prepare(rules, cfg.Origin, cfg.Coinbase, &contract, vm.ActivePrecompiles(rules), nil)
+	if _, _, err := evm.Call(vm.AccountRef(cfg.Origin), contract, nil, cfg.GasLimit, new(big.Int), vm.TxInputSourceMeta.Index); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if got := statedb.GetState(contract, common.BigToHash(common.Big1)); got != common.BigToHash(big.NewInt(6)) {
+		t.Fatalf("slot 1 = %x, want 6", got)
+	}
+	// The vertices of the transaction run at depth 1, those of the call at 2
+	vertices := make(map[string][]vm.Metadata)
+	for _, v := range graphVertices(evm.Graph) {
+		key := fmt.Sprintf("%s/%d", v.OpCode, v.Depth)
+		vertices[key] = append(vertices[key], v)
+	}
+	var (
+		write      = vertices["SSTORE/1"][0]
+		argWriter  = vertices["MSTORE/1"][1]
+		spawn      = vertices["CALL/1"][0]
+		read       = vertices["SLOAD/1"][0]
+		childLoad  = vertices["CALLDATALOAD/2"][0]
+		childRead  = vertices["SLOAD/2"][0]
+		childWrite = vertices["SSTORE/2"][0]
+	)
+	hasEdge := func(source, target vm.Metadata, kind vm.EdgeKind, frame bool) bool {
+		for _, e := range evm.Graph.Edges(source.Index, target.Index) {
+			if e.Kind == kind && (e.Size == 0) == frame {
+				return true
+			}
+		}
+		return false
+	}
+	for _, tt := range []struct {
+		name           string
+		source, target vm.Metadata
+		kind           vm.EdgeKind
+		frame          bool
+		want           bool
+	}{
+		{"call frame from spawn", spawn, childLoad, vm.CallEdge, true, true},
+		{"call frame from transaction input", vm.TxInputSourceMeta, childLoad, vm.CallEdge, true, false},
+		{"call data from input writer", argWriter, childLoad, vm.CallEdge, false, true},
+		{"call data from transaction input", vm.TxInputSourceMeta, childLoad, vm.CallEdge, false, false},
+		{"call read from write before join", write, childRead, vm.StorageEdge, true, true},
+		{"call read from pre-state", vm.StorageSourceMeta, childRead, vm.StorageEdge, true, false},
+		{"read after join from call write", childWrite, read, vm.StorageEdge, true, true},
+		{"read after join from write before join", write, read, vm.StorageEdge, true, false},
+	} {
+		if got := hasEdge(tt.source, tt.target, tt.kind, tt.frame); got != tt.want {
+			t.Errorf("%s: edge %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// callLogger records the addresses of the calls entered.
+type callLogger struct {
+	*logger.StructLogger
+	entered []common.Address
+}
+
+func (l *callLogger) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	l.entered = append(l.entered, to)
+}
+
+// TestParallelCallsTraced checks that spawns and joins are seen by the tracer
+// and are subject to the call depth limit.
+func TestParallelCallsTraced(t *testing.T) {
+	var (
+		driver  = common.BytesToAddress([]byte("driver"))
+		counter = common.BytesToAddress([]byte("counter"))
+		deep    = common.BytesToAddress([]byte("deep"))
+	)
+	statedb, _ := state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(driver, parallelDriver(counter))
+	statedb.SetCode(counter, counterCode)
+
+	tracer := &callLogger{StructLogger: logger.NewStructLogger(nil)}
+	cfg := &Config{State: statedb}
+	cfg.EVMConfig.ParallelCallWorkers = 1
+	cfg.EVMConfig.Tracer = tracer
+	if _, _, err := Call(driver, nil, cfg); err != nil {
+		t.Fatalf("call failed: %v", err)
+	}
+	if want := []common.Address{vm.ParallelSpawnAddress, vm.ParallelJoinAddress}; fmt.Sprint(tracer.entered) != fmt.Sprint(want) {
+		t.Errorf("have calls entered %x, want %x", tracer.entered, want)
+	}
+
+	// deep calls itself until the frame at depth 1025, which tries to spawn a
+	// call and stores whether it could in slot 1
+	code := []byte{
+		byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.ADD), byte(vm.DUP1), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		byte(vm.PUSH2), 0x04, 0x01, byte(vm.EQ), byte(vm.PUSH1), 0, byte(vm.JUMPI),
+	}
+	target := len(code) - 2
+	code = append(code, byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.DUP1), byte(vm.ADDRESS), byte(vm.GAS), byte(vm.CALL), byte(vm.STOP))
+	code[target] = byte(len(code))
+	code = append(code, byte(vm.JUMPDEST))
+	code = appendCall(code, vm.CALL, vm.ParallelSpawnAddress, 32, 32)
+	code = append(code, byte(vm.PUSH1), 1, byte(vm.SSTORE), byte(vm.STOP))
+
+	statedb, _ = state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetCode(deep, code)
+	cfg = &Config{State: statedb, GasLimit: 1 << 50}
+	cfg.EVMConfig.ParallelCallWorkers = 1
+	if _, _, err := Call(deep, nil, cfg); err != nil {
+		t.Fatalf("deep call failed: %v", err)
+	}
+	if depth := statedb.GetState(deep, common.Hash{}).Big().Uint64(); depth != 1025 {
+		t.Fatalf("have depth %d, want 1025", depth)
+	}
+	if statedb.GetState(deep, common.BigToHash(common.Big1)) != (common.Hash{}) {
+		t.Error("spawn made above the call depth limit")
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package taint tracks labeled values through the dependency graph of an
+// execution, and reports the instructions where they reach a sensitive
+// operand: a jump destination, the target or value of a call, a storage write
+// or a self-destruct beneficiary.
+//
+// Labels flow along the edges carrying data: stack, memory, storage, balance
+// and code edges, and the call edges of the bytes of call and return data.
+// Control dependencies and the edges from the frame source are left out, as
+// every instruction of a frame has one. A vertex carries the labels of all
+// the values it read, so the taint of an instruction with several operands
+// spreads to everything it produces.
+package taint
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// MaxSources is the number of sources a Config can label.
+const MaxSources = 64
+
+type sourceKind uint8
+
+const (
+	opSource         sourceKind = iota // the value pushed by an instruction
+	callDataSource                     // the call data of the transaction
+	returnDataSource                   // the data returned by the code of an address
+	storageSource                      // the values read from storage
+)
+
+// Source is a label, and the values it is put on.
+type Source struct {
+	Name string // The label, as it was parsed
+
+	kind sourceKind
+	op   vm.OpCode
+	addr common.Address
+	slot *common.Hash // nil for all the slots of addr
+}
+
+// DefaultSources are the sources labeled if none are given.
+var DefaultSources = []string{"calldata", "caller", "origin", "timestamp", "blockhash"}
+
+// ParseSource returns the source of the given name, which is one of
+//
+//	calldata                    the call data of the transaction
+//	returndata:<addr>           the data returned by the code of addr
+//	storage:<addr>[:<slot>]     the values read from the storage of addr
+//	<opcode>                    the values pushed by the opcode, like caller
+func ParseSource(name string) (Source, error) {
+	src := Source{Name: name}
+	kind, arg, _ := strings.Cut(name, ":")
+	switch strings.ToLower(kind) {
+	case "calldata":
+		if arg != "" {
+			return src, fmt.Errorf("source %q takes no argument", name)
+		}
+		src.kind = callDataSource
+		return src, nil
+
+	case "returndata":
+		if !common.IsHexAddress(arg) {
+			return src, fmt.Errorf("source %q wants returndata:<address>", name)
+		}
+		src.kind, src.addr = returnDataSource, common.HexToAddress(arg)
+		return src, nil
+
+	case "storage":
+		addr, slot, hasSlot := strings.Cut(arg, ":")
+		if !common.IsHexAddress(addr) {
+			return src, fmt.Errorf("source %q wants storage:<address>[:<slot>]", name)
+		}
+		src.kind, src.addr = storageSource, common.HexToAddress(addr)
+		if hasSlot {
+			hash, err := parseSlot(slot)
+			if err != nil {
+				return src, fmt.Errorf("source %q: %v", name, err)
+			}
+			src.slot = &hash
+		}
+		return src, nil
+	}
+	op := vm.StringToOp(strings.ToUpper(name))
+	if op == vm.STOP {
+		return src, fmt.Errorf("unknown source %q", name)
+	}
+	src.kind, src.op = opSource, op
+	return src, nil
+}
+
+func parseSlot(s string) (common.Hash, error) {
+	n, ok := new(big.Int).SetString(s, 0)
+	if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+		return common.Hash{}, fmt.Errorf("invalid slot %q", s)
+	}
+	return common.BigToHash(n), nil
+}
+
+// Sink is a kind of sensitive operand.
+type Sink uint8
+
+const (
+	JumpSink         Sink = iota // the destination of JUMP and JUMPI
+	CallSink                     // the target and value of CALL and CALLCODE, the target of STATICCALL
+	StoreSink                    // the slot and value of SSTORE
+	SelfdestructSink             // the beneficiary of SELFDESTRUCT
+	DelegateCallSink             // the target of DELEGATECALL
+	numSinks
+)
+
+var sinkNames = [numSinks]string{"jump", "call", "sstore", "selfdestruct", "delegatecall"}
+
+func (s Sink) String() string {
+	if s < numSinks {
+		return sinkNames[s]
+	}
+	return fmt.Sprintf("Sink(%d)", s)
+}
+
+// ParseSink returns the sink of the given name.
+func ParseSink(name string) (Sink, error) {
+	for s, n := range sinkNames {
+		if strings.EqualFold(name, n) {
+			return Sink(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sink %q, want one of %s", name, strings.Join(sinkNames[:], ", "))
+}
+
+// sinkOperand is a sensitive operand of an instruction.
+type sinkOperand struct {
+	sink     Sink
+	operand  int // position on the stack, 0 being the top
+	argument string
+}
+
+var sinkOperands = map[vm.OpCode][]sinkOperand{
+	vm.JUMP:         {{JumpSink, 0, "destination"}},
+	vm.JUMPI:        {{JumpSink, 0, "destination"}},
+	vm.CALL:         {{CallSink, 1, "address"}, {CallSink, 2, "value"}},
+	vm.CALLCODE:     {{CallSink, 1, "address"}, {CallSink, 2, "value"}},
+	vm.STATICCALL:   {{CallSink, 1, "address"}},
+	vm.SSTORE:       {{StoreSink, 0, "slot"}, {StoreSink, 1, "value"}},
+	vm.SELFDESTRUCT: {{SelfdestructSink, 0, "beneficiary"}},
+	vm.DELEGATECALL: {{DelegateCallSink, 1, "address"}},
+}
+
+// Config selects the labeled values and the operands they are reported at.
+type Config struct {
+	Sources []Source // At most MaxSources
+	Sinks   []Sink
+}
+
+// NewConfig parses the names of the sources and sinks, DefaultSources and all
+// the sinks being taken if none are given.
+func NewConfig(sources, sinks []string) (Config, error) {
+	var config Config
+	if len(sources) == 0 {
+		sources = DefaultSources
+	}
+	if len(sources) > MaxSources {
+		return config, fmt.Errorf("%d taint sources, at most %d are supported", len(sources), MaxSources)
+	}
+	for _, name := range sources {
+		src, err := ParseSource(name)
+		if err != nil {
+			return config, err
+		}
+		config.Sources = append(config.Sources, src)
+	}
+	for _, name := range sinks {
+		sink, err := ParseSink(name)
+		if err != nil {
+			return config, err
+		}
+		config.Sinks = append(config.Sinks, sink)
+	}
+	if len(config.Sinks) == 0 {
+		for s := Sink(0); s < numSinks; s++ {
+			config.Sinks = append(config.Sinks, s)
+		}
+	}
+	return config, nil
+}
+
+// Report lists where the labeled values reached a sink.
+type Report struct {
+	Sources  []string  `json:"sources"`
+	Sinks    []string  `json:"sinks"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is a sink operand holding a labeled value.
+type Finding struct {
+	Sink     string         `json:"sink"`
+	Index    int            `json:"index"` // Vertex of the sink instruction
+	ID       vm.VertexID    `json:"id"`
+	Address  common.Address `json:"address"` // Code the instruction belongs to
+	Pc       uint64         `json:"pc"`
+	OpCode   string         `json:"opcode"`
+	Operand  int            `json:"operand"`
+	Argument string         `json:"argument"` // What the operand is, like "destination" or "value"
+	Reverted bool           `json:"reverted,omitempty"`
+	Sources  []string       `json:"sources"` // Labels of the operand
+}
+
+// labels is a set of sources, by position in the Config.
+type labels uint64
+
+// node holds what the analysis needs to know of a vertex.
+type node struct {
+	op     vm.OpCode
+	addr   common.Address
+	depth  int
+	labels labels
+}
+
+// Analyze propagates the labels of the sources through an instruction level
+// graph, and reports the sink operands they reach. Sources past MaxSources
+// are ignored.
+func Analyze(g *vm.DependencyGraph, config Config) *Report {
+	report := &Report{Findings: make([]Finding, 0)}
+	sources := config.Sources
+	if len(sources) > MaxSources {
+		sources = sources[:MaxSources]
+	}
+	for _, src := range sources {
+		report.Sources = append(report.Sources, src.Name)
+	}
+	enabled := make(map[Sink]bool)
+	for _, sink := range config.Sinks {
+		if !enabled[sink] {
+			report.Sinks = append(report.Sinks, sink.String())
+		}
+		enabled[sink] = true
+	}
+
+	// Vertices are indexed from the first source on, absent ones being nil
+	var (
+		nodes []*node
+		first = vm.ContextSourceMeta.Index
+	)
+	g.ForEachVertex(func(v vm.Metadata) error {
+		for len(nodes) <= v.Index-first {
+			nodes = append(nodes, nil)
+		}
+		n := &node{op: vm.StringToOp(v.OpCode), addr: v.Addr, depth: v.Depth}
+		for i, src := range sources {
+			switch {
+			case src.kind == opSource && n.op == src.op:
+			case src.kind == callDataSource && n.depth <= 1 && (n.op == vm.CALLDATALOAD || n.op == vm.CALLDATACOPY):
+				// The call data of inner frames is labeled by the callers
+			default:
+				continue
+			}
+			n.labels |= 1 << i
+		}
+		nodes[v.Index-first] = n
+		return nil
+	})
+	lookup := func(index int) *node {
+		if index-first < 0 || index-first >= len(nodes) {
+			return nil
+		}
+		return nodes[index-first]
+	}
+
+	// Edges come by source, and sources come before their targets, so the
+	// labels of a source are all known when its edges are walked.
+	type operand struct {
+		target, operand int
+	}
+	found := make(map[operand]labels)
+	g.ForEachEdge(func(source, target int, e vm.Edge) error {
+		s, t := lookup(source), lookup(target)
+		if s == nil || t == nil {
+			return nil
+		}
+		for i, src := range sources {
+			if src.labelsEdge(s, t, e) {
+				t.labels |= 1 << i
+			}
+		}
+		if !carriesData(e) || s.labels == 0 {
+			return nil
+		}
+		t.labels |= s.labels
+		if e.Kind != vm.StackEdge {
+			return nil
+		}
+		for _, so := range sinkOperands[t.op] {
+			if so.operand == e.Operand && enabled[so.sink] {
+				found[operand{target, e.Operand}] |= s.labels
+			}
+		}
+		return nil
+	})
+
+	for at, set := range found {
+		v, _ := g.Vertex(at.target)
+		for _, so := range sinkOperands[vm.StringToOp(v.OpCode)] {
+			if so.operand != at.operand {
+				continue
+			}
+			f := Finding{
+				Sink:     so.sink.String(),
+				Index:    v.Index,
+				ID:       v.ID,
+				Address:  v.Addr,
+				Pc:       v.Pc,
+				OpCode:   v.OpCode,
+				Operand:  at.operand,
+				Argument: so.argument,
+				Reverted: v.Reverted,
+			}
+			for i, src := range sources {
+				if set&(1<<i) != 0 {
+					f.Sources = append(f.Sources, src.Name)
+				}
+			}
+			report.Findings = append(report.Findings, f)
+		}
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Index != b.Index {
+			return a.Index < b.Index
+		}
+		return a.Operand < b.Operand
+	})
+	return report
+}
+
+// labelsEdge returns whether the value read through the edge gets the label
+// of the source.
+func (src *Source) labelsEdge(s, t *node, e vm.Edge) bool {
+	switch src.kind {
+	case storageSource:
+		return e.Kind == vm.StorageEdge && t.op == vm.SLOAD && e.Address == src.addr &&
+			(src.slot == nil || e.Slot == *src.slot)
+	case returnDataSource:
+		// Returned data is read by a shallower frame than the one writing it
+		return carriesData(e) && e.Kind != vm.StackEdge && s.addr == src.addr && s.depth > t.depth
+	}
+	return false
+}
+
+// carriesData returns whether a value flows along the edge.
+func carriesData(e vm.Edge) bool {
+	switch e.Kind {
+	case vm.StackEdge, vm.MemoryEdge, vm.StorageEdge, vm.TransientStorageEdge, vm.BalanceEdge, vm.CodeEdge:
+		return true
+	case vm.CallEdge:
+		return e.Size > 0
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package taint
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+var (
+	contract = common.HexToAddress("0x000000000000000000000000000000000000c0de")
+	callee   = common.HexToAddress("0x0000000000000000000000000000000000ca11ee")
+)
+
+// testGraph returns the graph of a contract which jumps to the sum of a call
+// data word and the caller, conditioned on a storage slot, then calls the
+// address returned by another contract. The SELFDESTRUCT only depends on the
+// call data through control flow, the SSTORE writes the slot read back.
+func testGraph() *vm.DependencyGraph {
+	var (
+		g  = vm.NewDependencyGraph()
+		op = func(i int, name string, addr common.Address, depth int) vm.Metadata {
+			return vm.Metadata{Index: i, OpCode: name, Addr: addr, Depth: depth}
+		}
+		load     = op(0, "CALLDATALOAD", contract, 1)
+		caller   = op(1, "CALLER", contract, 1)
+		add      = op(2, "ADD", contract, 1)
+		sload    = op(3, "SLOAD", contract, 1)
+		jumpi    = op(4, "JUMPI", contract, 1)
+		mstore   = op(5, "MSTORE", callee, 2)
+		mload    = op(6, "MLOAD", contract, 1)
+		call     = op(7, "CALL", contract, 1)
+		address  = op(8, "ADDRESS", contract, 1)
+		destruct = op(9, "SELFDESTRUCT", contract, 1)
+		sstore   = op(10, "SSTORE", contract, 1)
+	)
+	g.AddEdge(vm.TxInputSourceMeta, load, vm.Edge{Kind: vm.CallEdge})
+	g.AddDependency([]vm.Metadata{load, caller}, add)
+	g.AddEdge(vm.StorageSourceMeta, sload, vm.Edge{Kind: vm.StorageEdge, Address: contract, Slot: common.BigToHash(common.Big1)})
+	g.AddDependency([]vm.Metadata{add, sload}, jumpi)
+	g.AddEdge(vm.TxInputSourceMeta, mstore, vm.Edge{Kind: vm.CallEdge})
+	g.AddEdge(mstore, mload, vm.Edge{Kind: vm.MemoryEdge, Offset: 0, Size: 32})
+	g.AddDependency([]vm.Metadata{caller, mload}, call)
+	g.AddControlDependency(load, address)
+	g.AddDependency([]vm.Metadata{address}, destruct)
+	g.AddDependency([]vm.Metadata{address, sload}, sstore)
+	return g
+}
+
+func TestAnalyze(t *testing.T) {
+	config, err := NewConfig([]string{"calldata", "caller", "storage:0x000000000000000000000000000000000000c0de:1", "returndata:0x0000000000000000000000000000000000ca11ee"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Analyze(testGraph(), config)
+	if !reflect.DeepEqual(report.Sinks, []string{"jump", "call", "sstore", "selfdestruct", "delegatecall"}) {
+		t.Errorf("have sinks %v", report.Sinks)
+	}
+	type finding struct {
+		index, operand int
+		argument       string
+		sources        []string
+	}
+	want := []finding{
+		{4, 0, "destination", []string{"calldata", "caller"}},
+		{7, 1, "address", []string{"returndata:0x0000000000000000000000000000000000ca11ee"}},
+		{10, 1, "value", []string{"storage:0x000000000000000000000000000000000000c0de:1"}},
+	}
+	var have []finding
+	for _, f := range report.Findings {
+		have = append(have, finding{f.Index, f.Operand, f.Argument, f.Sources})
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("have findings %+v, want %+v", have, want)
+	}
+
+	// The storage label reaches the condition only, which is no sink
+	config, _ = NewConfig([]string{"storage:0x000000000000000000000000000000000000c0de"}, []string{"jump"})
+	if report := Analyze(testGraph(), config); len(report.Findings) != 0 {
+		t.Errorf("have findings %+v of the jump condition", report.Findings)
+	}
+	// Only the enabled sinks are reported
+	config, _ = NewConfig(nil, []string{"call"})
+	if report := Analyze(testGraph(), config); len(report.Findings) != 0 {
+		t.Errorf("have findings %+v out of the call sink", report.Findings)
+	}
+}
+
+func TestParseSource(t *testing.T) {
+	for _, name := range []string{"calldata", "TIMESTAMP", "blockhash", "returndata:0x000000000000000000000000000000000000ca11", "storage:0x000000000000000000000000000000000000c0de:0x01"} {
+		if _, err := ParseSource(name); err != nil {
+			t.Errorf("source %q: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "calldata:1", "nothing", "returndata", "storage:0xc0de:nope"} {
+		if _, err := ParseSource(name); err == nil {
+			t.Errorf("source %q parsed", name)
+		}
+	}
+	if _, err := ParseSink("reentrancy"); err == nil {
+		t.Error("unknown sink parsed")
+	}
+}
@@ -151,6 +151,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables dependency graph generation in the VM
+	EnableDFG bool
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -0,0 +1,158 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// storerAddr stores the word it is called with
+	storerAddr = common.HexToAddress("0x5707e")
+	storerCode = []byte{
+		byte(vm.PUSH1), 0, byte(vm.CALLDATALOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP),
+	}
+	// looperAddr loops until it runs out of gas
+	looperAddr = common.HexToAddress("0x1007")
+	looperCode = []byte{byte(vm.JUMPDEST), byte(vm.PUSH1), 0, byte(vm.JUMP)}
+)
+
+// dfgGraph is the part of the default result of the dfgTracer checked here.
+type dfgGraph struct {
+	Vertices []json.RawMessage `json:"vertices"`
+	Edges    []json.RawMessage `json:"edges"`
+}
+
+// newDFGTestAPI returns an API over a chain of two blocks, each with a
+// transaction calling the storer, and the hash of the last one.
+func newDFGTestAPI(t *testing.T) (*tracers.API, common.Hash, func()) {
+	var (
+		key, _ = crypto.GenerateKey()
+		from   = crypto.PubkeyToAddress(key.PublicKey)
+		target common.Hash
+	)
+	genesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			from:       {Balance: big.NewInt(params.Ether)},
+			storerAddr: {Code: storerCode},
+			looperAddr: {Code: looperCode},
+		},
+	}
+	api, teardown := tracers.NewTestAPI(t, 2, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), storerAddr, common.Big0, 100000, b.BaseFee(), common.LeftPadBytes([]byte{byte(i + 1)}, 32)), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+		target = tx.Hash()
+	})
+	return api, target, teardown
+}
+
+// checkGraph fails the test unless the result is a graph with vertices and
+// edges.
+func checkGraph(t *testing.T, name string, result interface{}) {
+	t.Helper()
+	raw, ok := result.(json.RawMessage)
+	if !ok {
+		t.Fatalf("%s: unexpected result type %T", name, result)
+	}
+	var graph dfgGraph
+	if err := json.Unmarshal(raw, &graph); err != nil {
+		t.Fatalf("%s: failed to unmarshal graph: %v", name, err)
+	}
+	if len(graph.Vertices) == 0 || len(graph.Edges) == 0 {
+		t.Fatalf("%s: empty graph: %s", name, raw)
+	}
+}
+
+// Tests that the dfgTracer returns the dependency graph through the
+// debug_trace* methods.
+func TestDFGTracerAPI(t *testing.T) {
+	t.Parallel()
+
+	api, target, teardown := newDFGTestAPI(t)
+	defer teardown()
+
+	name := "dfgTracer"
+	config := &tracers.TraceConfig{Tracer: &name}
+
+	result, err := api.TraceTransaction(context.Background(), target, config)
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	checkGraph(t, "traceTransaction", result)
+
+	latest := rpc.LatestBlockNumber
+	result, err = api.TraceCall(context.Background(), ethapi.TransactionArgs{
+		To:    &storerAddr,
+		Input: (*hexutil.Bytes)(&[]byte{0x01}),
+	}, rpc.BlockNumberOrHash{BlockNumber: &latest}, &tracers.TraceCallConfig{TraceConfig: *config})
+	if err != nil {
+		t.Fatalf("failed to trace call: %v", err)
+	}
+	checkGraph(t, "traceCall", result)
+
+	results, err := api.TraceBlockByNumber(context.Background(), rpc.BlockNumber(2), config)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("traceBlockByNumber: %d results, want 1", len(results))
+	}
+	if results[0].Error != "" {
+		t.Fatalf("traceBlockByNumber: tracing failed: %v", results[0].Error)
+	}
+	checkGraph(t, "traceBlockByNumber", results[0].Result)
+}
+
+// Tests that a dfgTracer running past its timeout is stopped, and reports so.
+func TestDFGTracerAPITimeout(t *testing.T) {
+	t.Parallel()
+
+	api, _, teardown := newDFGTestAPI(t)
+	defer teardown()
+
+	var (
+		name    = "dfgTracer"
+		timeout = "10ms"
+		latest  = rpc.LatestBlockNumber
+		gas     = hexutil.Uint64(params.MaxGasLimit)
+	)
+	_, err := api.TraceCall(context.Background(), ethapi.TransactionArgs{
+		To:  &looperAddr,
+		Gas: &gas,
+	}, rpc.BlockNumberOrHash{BlockNumber: &latest}, &tracers.TraceCallConfig{
+		TraceConfig: tracers.TraceConfig{Tracer: &name, Timeout: &timeout},
+	})
+	if err == nil || err.Error() != "execution timeout" {
+		t.Fatalf("want execution timeout, have %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// NewTestAPI returns an API over a chain of n generated blocks, along with the
+// function releasing it. It lets the tests of package tracers_test, which can
+// import the native tracers without an import cycle, go through the API.
+func NewTestAPI(t *testing.T, n int, gspec *core.Genesis, generator func(i int, b *core.BlockGen)) (*API, func()) {
+	backend := newTestBackend(t, n, gspec, generator)
+	return NewAPI(backend), backend.teardown
+}
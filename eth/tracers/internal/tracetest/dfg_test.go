@@ -0,0 +1,263 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracetest
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// dfgResult is the default result of the dfgTracer.
+type dfgResult struct {
+	Vertices []struct {
+		Index  int    `json:"index"`
+		OpCode string `json:"opcode"`
+		Frame  int    `json:"frame"`
+		Gas    uint64 `json:"gas"`
+	} `json:"vertices"`
+	Edges []struct {
+		Source int    `json:"source"`
+		Target int    `json:"target"`
+		Kind   string `json:"kind"`
+	} `json:"edges"`
+}
+
+// traceDFG runs a transaction calling a contract, which stores the word
+// returned by another one, through a dfgTracer of the given config.
+func traceDFG(t *testing.T, cfg string) (json.RawMessage, error) {
+	return traceGraph(t, "dfgTracer", cfg)
+}
+
+// traceGraph runs the transaction of traceDFG through the given tracer.
+func traceGraph(t *testing.T, name, cfg string) (json.RawMessage, error) {
+	var (
+		to     = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+		callee = common.HexToAddress("0xbb")
+		origin = common.HexToAddress("0x00000000000000000000000000000000feed")
+		code   = []byte{
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.DUP1), byte(vm.DUP1), // out and in
+			byte(vm.DUP1), byte(vm.PUSH1), 0xbb, byte(vm.GAS), // value=0, address=0xbb, gas=GAS
+			byte(vm.CALL), byte(vm.POP),
+			byte(vm.PUSH1), 0, byte(vm.MLOAD), byte(vm.PUSH1), 0, byte(vm.SSTORE),
+		}
+		calleeCode = []byte{
+			byte(vm.PUSH1), 42, byte(vm.PUSH1), 0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 32, byte(vm.PUSH1), 0, byte(vm.RETURN),
+		}
+		context = vm.BlockContext{
+			CanTransfer: core.CanTransfer,
+			Transfer:    core.Transfer,
+			BlockNumber: new(big.Int).SetUint64(8000000),
+			Time:        5,
+			Difficulty:  big.NewInt(0x30000),
+			GasLimit:    uint64(6000000),
+		}
+	)
+	tracer, err := tracers.DefaultDirectory.New(name, nil, json.RawMessage(cfg))
+	if err != nil {
+		return nil, err
+	}
+	triedb, _, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(),
+		core.GenesisAlloc{
+			to:     core.GenesisAccount{Code: code},
+			callee: core.GenesisAccount{Code: calleeCode},
+			origin: core.GenesisAccount{Balance: big.NewInt(500000000000000)},
+		}, false, rawdb.HashScheme)
+	defer triedb.Close()
+
+	// The tracer gets the graph without it being enabled in the config
+	evm := vm.NewEVM(context, vm.TxContext{Origin: origin, GasPrice: big.NewInt(1)}, statedb, params.MainnetChainConfig, vm.Config{Tracer: tracer})
+	msg := &core.Message{
+		To:        &to,
+		From:      origin,
+		Value:     big.NewInt(0),
+		GasLimit:  80000,
+		GasPrice:  big.NewInt(0),
+		GasFeeCap: big.NewInt(0),
+		GasTipCap: big.NewInt(0),
+	}
+	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(msg.GasLimit))
+	if _, err := st.TransitionDb(); err != nil {
+		t.Fatalf("failed to execute transaction: %v", err)
+	}
+	return tracer.GetResult()
+}
+
+func TestDFGTracer(t *testing.T) {
+	decode := func(cfg string) *dfgResult {
+		res, err := traceDFG(t, cfg)
+		if err != nil {
+			t.Fatalf("config %s: failed to trace: %v", cfg, err)
+		}
+		var graph dfgResult
+		if err := json.Unmarshal(res, &graph); err != nil {
+			t.Fatalf("config %s: invalid result: %v", cfg, err)
+		}
+		return &graph
+	}
+	// Every instruction is a vertex by default
+	graph := decode(`{}`)
+	if have, want := len(graph.Vertices), 5+14+6; have != want {
+		t.Errorf("have %d vertices, want %d", have, want)
+	}
+	if have := graph.Vertices[len(graph.Vertices)-1].OpCode; have != "STOP" {
+		t.Errorf("have last vertex %s, want STOP", have)
+	}
+	// The frames are linked through the returned word
+	graph = decode(`{"level": "call"}`)
+	if len(graph.Vertices) != 7 {
+		t.Fatalf("have %d call vertices, want 7", len(graph.Vertices))
+	}
+	for i, v := range graph.Vertices[5:] {
+		if v.Index != i || v.Frame != i || v.OpCode != "call" || v.Gas == 0 {
+			t.Errorf("unexpected call vertex %+v", v)
+		}
+	}
+	var linked bool
+	for _, e := range graph.Edges {
+		linked = linked || (e.Source == 1 && e.Target == 0)
+	}
+	if !linked {
+		t.Errorf("callee not linked to the caller: %+v", graph.Edges)
+	}
+	// A single transaction has no dependencies on other ones
+	graph = decode(`{"level": "tx", "kinds": ["storage"]}`)
+	if len(graph.Vertices) != 6 {
+		t.Errorf("have %d tx vertices, want 6", len(graph.Vertices))
+	}
+	// Only the requested edge kinds are kept
+	graph = decode(`{"kinds": ["memory", "call"]}`)
+	if len(graph.Edges) == 0 {
+		t.Errorf("no memory or call edges")
+	}
+	for _, e := range graph.Edges {
+		if e.Kind != "memory" && e.Kind != "call" {
+			t.Errorf("unexpected %s edge", e.Kind)
+		}
+	}
+	// The other formats are returned as strings
+	res, err := traceDFG(t, `{"format": "dot"}`)
+	if err != nil {
+		t.Fatalf("failed to trace: %v", err)
+	}
+	var dot string
+	if err := json.Unmarshal(res, &dot); err != nil || !strings.HasPrefix(dot, "digraph") {
+		t.Errorf("have dot result %s, %v", res, err)
+	}
+	// The parallelism of the graph is returned instead of it if asked for
+	if res, err = traceDFG(t, `{"stats": true, "level": "call", "workers": [1]}`); err != nil {
+		t.Fatalf("failed to trace: %v", err)
+	}
+	var report analytics.Report
+	if err := json.Unmarshal(res, &report); err != nil {
+		t.Fatalf("invalid stats: %v", err)
+	}
+	if report.Vertices != 2 || report.Levels != 2 || len(report.Speedups) != 1 || report.Speedups[0].Makespan != report.Work {
+		t.Errorf("have stats %+v", report)
+	}
+	// The returned word reaches the stored value
+	if res, err = traceDFG(t, `{"taint": {"sources": ["returndata:0x00000000000000000000000000000000000000bb"]}}`); err != nil {
+		t.Fatalf("failed to trace: %v", err)
+	}
+	var findings taint.Report
+	if err := json.Unmarshal(res, &findings); err != nil {
+		t.Fatalf("invalid taint report: %v", err)
+	}
+	if len(findings.Findings) != 1 || findings.Findings[0].OpCode != "SSTORE" || findings.Findings[0].Argument != "value" {
+		t.Errorf("have taint findings %+v", findings.Findings)
+	}
+	// Invalid configs are rejected
+	for _, cfg := range []string{`{"level": "block"}`, `{"kinds": ["stack", "gas"]}`, `{"format": "svg"}`, `{"stats": true, "weight": "time"}`, `{"taint": {"sinks": ["log"]}}`} {
+		if _, err := traceDFG(t, cfg); err == nil {
+			t.Errorf("config %s accepted", cfg)
+		}
+	}
+}
+
+// TestJSDependencyTracer checks that a JS tracer following the dependency graph
+// sees the edges as they are recorded, and the shadow stack and memory, on its
+// own and through the muxTracer.
+func TestJSDependencyTracer(t *testing.T) {
+	code := `{
+		deps: {},
+		dependency: function(sources, target) {
+			var deps = this.deps[target.opcode] || [];
+			for (var i = 0; i < sources.length; i++) {
+				deps.push(sources[i].opcode);
+			}
+			this.deps[target.opcode] = deps;
+		},
+		step: function(log) {
+			if (log.op.toString() == "SSTORE") {
+				this.stored = log.stack.source(1).opcode;
+				this.writers = log.memory.writers(0, 32).map(function(v) { return v.opcode + "@" + v.depth; });
+			}
+		},
+		fault: function() {},
+		result: function() {
+			return {sstore: this.deps["SSTORE"], stored: this.stored, writers: this.writers};
+		}
+	}`
+	mux, err := json.Marshal(map[string]json.RawMessage{code: json.RawMessage("{}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		label, name, cfg string
+	}{
+		{"js", code, ""},
+		{"mux", "muxTracer", string(mux)},
+	} {
+		res, err := traceGraph(t, test.name, test.cfg)
+		if err != nil {
+			t.Fatalf("%s: failed to trace: %v", test.label, err)
+		}
+		if test.cfg != "" {
+			var results map[string]json.RawMessage
+			if err := json.Unmarshal(res, &results); err != nil {
+				t.Fatalf("invalid mux result %s: %v", res, err)
+			}
+			res = results[code]
+		}
+		var have struct {
+			Sstore  []string `json:"sstore"`
+			Stored  string   `json:"stored"`
+			Writers []string `json:"writers"`
+		}
+		if err := json.Unmarshal(res, &have); err != nil {
+			t.Fatalf("invalid result %s: %v", res, err)
+		}
+		if len(have.Sstore) < 2 || have.Sstore[1] != "MLOAD" || have.Stored != "MLOAD" {
+			t.Errorf("%s: have SSTORE sources %v and stored value from %s", test.label, have.Sstore, have.Stored)
+		}
+		if len(have.Writers) != 1 || have.Writers[0] != "MSTORE@2" {
+			t.Errorf("%s: have memory writers %v", test.label, have.Writers)
+		}
+	}
+}
@@ -104,16 +104,18 @@ type jsTracer struct {
 	activePrecompiles []common.Address      // List of active precompiles at current block
 	traceStep         bool                  // True if tracer object exposes a `step()` method
 	traceFrame        bool                  // True if tracer object exposes the `enter()` and `exit()` methods
+	traceDependency   bool                  // True if tracer object exposes a `dependency()` method
 	gasLimit          uint64                // Amount of gas bought for the whole tx
 	err               error                 // Any error that should stop tracing
 	obj               *goja.Object          // Trace object
 
 	// Methods exposed by tracer
-	result goja.Callable
-	fault  goja.Callable
-	step   goja.Callable
-	enter  goja.Callable
-	exit   goja.Callable
+	result     goja.Callable
+	fault      goja.Callable
+	step       goja.Callable
+	enter      goja.Callable
+	exit       goja.Callable
+	dependency goja.Callable
 
 	// Underlying structs being passed into JS
 	log         *steplog
@@ -133,7 +135,10 @@ type jsTracer struct {
 //
 // The methods `result` and `fault` are required to be present.
 // The methods `step`, `enter`, and `exit` are optional, but note that
-// `enter` and `exit` always go together.
+// `enter` and `exit` always go together. The optional `dependency` method
+// makes the EVM build the dependency graph, and is called with the vertices
+// an instruction depends on as the graph is recorded. The shadow stack and
+// memory can then be read from `step` as well.
 func newJsTracer(code string, ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
 	vm := goja.New()
 	// By default field names are exported to JS as is, i.e. capitalized.
@@ -177,6 +182,9 @@ func newJsTracer(code string, ctx *tracers.Context, cfg json.RawMessage) (tracer
 		return nil, errors.New("trace object must expose either both or none of enter() and exit()")
 	}
 	t.traceFrame = hasEnter
+	dependency, ok := goja.AssertFunction(obj.Get("dependency"))
+	t.traceDependency = ok
+	t.dependency = dependency
 	t.obj = obj
 	t.step = step
 	t.enter = enter
@@ -199,7 +207,7 @@ func newJsTracer(code string, ctx *tracers.Context, cfg json.RawMessage) (tracer
 		vm:       vm,
 		op:       &opObj{vm: vm},
 		memory:   &memoryObj{vm: vm, toBig: t.toBig, toBuf: t.toBuf},
-		stack:    &stackObj{vm: vm, toBig: t.toBig},
+		stack:    &stackObj{vm: vm, toBig: t.toBig, toBuf: t.toBuf},
 		contract: &contractObj{vm: vm, toBig: t.toBig, toBuf: t.toBuf},
 	}
 	t.frame = &callframe{vm: vm, toBig: t.toBig, toBuf: t.toBuf}
@@ -266,7 +274,9 @@ func (t *jsTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope
 	log := t.log
 	log.op.op = op
 	log.memory.memory = scope.Memory
+	log.memory.scope = scope
 	log.stack.stack = scope.Stack
+	log.stack.scope = scope
 	log.contract.contract = scope.Contract
 	log.pc = pc
 	log.gas = gas
@@ -339,6 +349,37 @@ func (t *jsTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
 	}
 }
 
+// NeedsGraph implements the vm.GraphLogger interface, the graph is built if
+// the tracer follows it.
+func (t *jsTracer) NeedsGraph() bool {
+	return t.traceDependency
+}
+
+// CaptureDependency implements the vm.DependencyLogger interface to trace the
+// edges of the dependency graph.
+func (t *jsTracer) CaptureDependency(sources []vm.Metadata, target vm.Metadata) {
+	if !t.traceDependency || t.err != nil {
+		return
+	}
+	vertices := make([]goja.Value, len(sources))
+	for i, v := range sources {
+		vertex, err := newVertexObject(t.vm, t.toBig, t.toBuf, v)
+		if err != nil {
+			t.onError("dependency", err)
+			return
+		}
+		vertices[i] = vertex
+	}
+	vertex, err := newVertexObject(t.vm, t.toBig, t.toBuf, target)
+	if err != nil {
+		t.onError("dependency", err)
+		return
+	}
+	if _, err := t.dependency(t.obj, t.vm.ToValue(vertices), vertex); err != nil {
+		t.onError("dependency", err)
+	}
+}
+
 // GetResult calls the Javascript 'result' function and returns its value, or any accumulated error
 func (t *jsTracer) GetResult() (json.RawMessage, error) {
 	ctx := t.vm.ToValue(t.ctx)
@@ -545,6 +586,7 @@ func (o *opObj) setupObject() *goja.Object {
 
 type memoryObj struct {
 	memory *vm.Memory
+	scope  *vm.ScopeContext
 	vm     *goja.Runtime
 	toBig  toBigFn
 	toBuf  toBufFn
@@ -605,18 +647,44 @@ func (mo *memoryObj) Length() int {
 	return mo.memory.Len()
 }
 
+// Writers returns the vertices which last wrote the requested range of memory,
+// or null if the dependency graph is not built.
+func (mo *memoryObj) Writers(begin, end int64) goja.Value {
+	if end < begin || begin < 0 {
+		mo.vm.Interrupt(fmt.Errorf("tracer accessed out of bound memory: offset %d, end %d", begin, end))
+		return nil
+	}
+	writers := mo.scope.MetaMemoryWriters(uint64(begin), uint64(end-begin))
+	if writers == nil {
+		return goja.Null()
+	}
+	vertices := make([]goja.Value, len(writers))
+	for i, v := range writers {
+		vertex, err := newVertexObject(mo.vm, mo.toBig, mo.toBuf, v)
+		if err != nil {
+			mo.vm.Interrupt(err)
+			return nil
+		}
+		vertices[i] = vertex
+	}
+	return mo.vm.ToValue(vertices)
+}
+
 func (m *memoryObj) setupObject() *goja.Object {
 	o := m.vm.NewObject()
 	o.Set("slice", m.vm.ToValue(m.Slice))
 	o.Set("getUint", m.vm.ToValue(m.GetUint))
 	o.Set("length", m.vm.ToValue(m.Length))
+	o.Set("writers", m.vm.ToValue(m.Writers))
 	return o
 }
 
 type stackObj struct {
 	stack *vm.Stack
+	scope *vm.ScopeContext
 	vm    *goja.Runtime
 	toBig toBigFn
+	toBuf toBufFn
 }
 
 func (s *stackObj) Peek(idx int) goja.Value {
@@ -645,13 +713,59 @@ func (s *stackObj) Length() int {
 	return len(s.stack.Data())
 }
 
+// Source returns the vertex which pushed the nth-from-the-top element of the
+// stack, or null if the dependency graph is not built.
+func (s *stackObj) Source(idx int) goja.Value {
+	data := s.scope.MetaStackData()
+	if data == nil {
+		return goja.Null()
+	}
+	if len(data) <= idx || idx < 0 {
+		s.vm.Interrupt(fmt.Errorf("tracer accessed out of bound stack: size %d, index %d", len(data), idx))
+		return nil
+	}
+	res, err := newVertexObject(s.vm, s.toBig, s.toBuf, data[len(data)-idx-1])
+	if err != nil {
+		s.vm.Interrupt(err)
+		return nil
+	}
+	return res
+}
+
 func (s *stackObj) setupObject() *goja.Object {
 	o := s.vm.NewObject()
 	o.Set("peek", s.vm.ToValue(s.Peek))
 	o.Set("length", s.vm.ToValue(s.Length))
+	o.Set("source", s.vm.ToValue(s.Source))
 	return o
 }
 
+// newVertexObject returns the JS object of a vertex of the dependency graph.
+// The value is only set on constants and on the vertices of executed
+// instructions which pushed one.
+func newVertexObject(vm *goja.Runtime, toBig toBigFn, toBuf toBufFn, meta vm.Metadata) (goja.Value, error) {
+	addr, err := toBuf(vm, meta.Addr.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	o := vm.NewObject()
+	o.Set("index", meta.Index)
+	o.Set("id", meta.ID.String())
+	o.Set("address", addr)
+	o.Set("pc", meta.Pc)
+	o.Set("opcode", meta.OpCode)
+	o.Set("depth", meta.Depth)
+	o.Set("constant", meta.Constant)
+	if meta.Value != nil {
+		value, err := toBig(vm, meta.Value.Dec())
+		if err != nil {
+			return nil, err
+		}
+		o.Set("value", value)
+	}
+	return o, nil
+}
+
 type dbObj struct {
 	db      vm.StateDB
 	vm      *goja.Runtime
@@ -0,0 +1,165 @@
+// Copyright 2023 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package native
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/analytics"
+	"github.com/ethereum/go-ethereum/core/vm/taint"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+func init() {
+	tracers.DefaultDirectory.Register("dfgTracer", newDFGTracer, false)
+}
+
+// dfgTracer returns the dependency graph the EVM builds for it while executing
+// the transaction. By default the graph is a JSON document with the vertices
+// and edges of every instruction, see vm.JSONExporter. With stats set, only
+// the parallelism metrics of the graph are returned, see analytics.Report, and
+// with taint set the labeled values reaching a sink, see taint.Report.
+type dfgTracer struct {
+	noopTracer
+	env      *vm.EVM
+	level    vm.GraphLevel
+	kinds    []vm.EdgeKind // nil for all kinds
+	format   string
+	exporter vm.GraphExporter
+	stats    *analytics.Config // nil for the graph
+	taint    *taint.Config     // nil for the graph
+	reason   error             // Textual reason for the interruption
+}
+
+type dfgTracerConfig struct {
+	Level   string   `json:"level"`   // "instruction" if empty, "call" or "tx"
+	Kinds   []string `json:"kinds"`   // Edge kinds to keep, all if empty
+	Format  string   `json:"format"`  // "json" if empty, the other formats are returned as a string
+	Stats   bool     `json:"stats"`   // If true, the metrics of the graph are returned instead
+	Weight  string   `json:"weight"`  // Cost of the vertices in the metrics, "count" if empty or "gas"
+	Workers []int    `json:"workers"` // Numbers of workers to compute the speedup for
+
+	Taint *dfgTaintConfig `json:"taint"` // If set, the taint findings are returned instead
+}
+
+type dfgTaintConfig struct {
+	Sources []string `json:"sources"` // Labeled values, taint.DefaultSources if empty
+	Sinks   []string `json:"sinks"`   // Operands to report, all if empty
+}
+
+// newDFGTracer returns a native go tracer which returns the dependency graph
+// of a tx, and implements vm.GraphLogger.
+func newDFGTracer(ctx *tracers.Context, cfg json.RawMessage) (tracers.Tracer, error) {
+	var config dfgTracerConfig
+	if cfg != nil {
+		if err := json.Unmarshal(cfg, &config); err != nil {
+			return nil, err
+		}
+	}
+	t := &dfgTracer{format: config.Format}
+	if t.format == "" {
+		t.format = "json"
+	}
+	var err error
+	if t.level, err = vm.ParseGraphLevel(config.Level); err != nil {
+		return nil, err
+	}
+	for _, name := range config.Kinds {
+		kind, err := vm.ParseEdgeKind(name)
+		if err != nil {
+			return nil, err
+		}
+		t.kinds = append(t.kinds, kind)
+	}
+	if t.exporter, err = vm.NewGraphExporter(t.format); err != nil {
+		return nil, err
+	}
+	if config.Stats {
+		t.stats = &analytics.Config{Workers: config.Workers}
+		if t.stats.Weight, err = analytics.ParseWeight(config.Weight); err != nil {
+			return nil, err
+		}
+	}
+	if config.Taint != nil {
+		taintConfig, err := taint.NewConfig(config.Taint.Sources, config.Taint.Sinks)
+		if err != nil {
+			return nil, err
+		}
+		t.taint = &taintConfig
+	}
+	return t, nil
+}
+
+// NeedsGraph implements vm.GraphLogger.
+func (t *dfgTracer) NeedsGraph() bool {
+	return true
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *dfgTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+}
+
+// GetResult returns the dependency graph in the configured format, or its
+// metrics or taint findings, and any error arising from the encoding or
+// forceful termination (via `Stop`).
+func (t *dfgTracer) GetResult() (json.RawMessage, error) {
+	graph := vm.NewDependencyGraph()
+	if t.env != nil && t.env.Graph != nil {
+		graph = t.env.Graph
+	}
+	if t.taint != nil {
+		// Taint flows between instructions, whatever the level and kinds
+		res, err := json.Marshal(taint.Analyze(graph, *t.taint))
+		if err != nil {
+			return nil, err
+		}
+		return res, t.reason
+	}
+	if t.kinds != nil {
+		graph = graph.FilterEdges(t.kinds...)
+	}
+	graph = graph.Condense(t.level)
+	if t.stats != nil {
+		res, err := json.Marshal(analytics.Analyze(graph, *t.stats))
+		if err != nil {
+			return nil, err
+		}
+		return res, t.reason
+	}
+	var buf bytes.Buffer
+	if err := t.exporter.Export(&buf, graph); err != nil {
+		return nil, err
+	}
+	if t.format == "json" {
+		return buf.Bytes(), t.reason
+	}
+	res, err := json.Marshal(buf.String())
+	if err != nil {
+		return nil, err
+	}
+	return res, t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *dfgTracer) Stop(err error) {
+	t.reason = err
+}
@@ -136,3 +136,24 @@ func (t *muxTracer) Stop(err error) {
 		t.Stop(err)
 	}
 }
+
+// NeedsGraph implements vm.GraphLogger, the graph being built if any of the
+// tracers needs it.
+func (t *muxTracer) NeedsGraph() bool {
+	for _, t := range t.tracers {
+		if tracer, ok := t.(vm.GraphLogger); ok && tracer.NeedsGraph() {
+			return true
+		}
+	}
+	return false
+}
+
+// CaptureDependency implements vm.DependencyLogger, forwarding the edges to the
+// tracers following them.
+func (t *muxTracer) CaptureDependency(sources []vm.Metadata, target vm.Metadata) {
+	for _, t := range t.tracers {
+		if tracer, ok := t.(vm.DependencyLogger); ok && tracer.NeedsGraph() {
+			tracer.CaptureDependency(sources, target)
+		}
+	}
+}
@@ -43,6 +43,7 @@ var (
 	difficultyTestDir  = filepath.Join(baseDir, "BasicTests")
 	executionSpecDir   = filepath.Join(".", "spec-tests", "fixtures")
 	benchmarksDir      = filepath.Join(".", "evm-benchmarks", "benchmarks")
+	dfgStateTestDir    = filepath.Join(".", "dfg-state-tests")
 )
 
 func readJSON(reader io.Reader, value interface{}) error {
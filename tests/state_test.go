@@ -28,6 +28,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -133,6 +134,108 @@ func TestState(t *testing.T) {
 	}
 }
 
+// TestStateDFG runs every state test through both the stock interpreter and the
+// dependency-tracking one, and checks that they produce identical results.
+func TestStateDFG(t *testing.T) {
+	t.Parallel()
+
+	st := new(testMatcher)
+	st.slow(`^stAttackTest/ContractCreationSpam`)
+	st.slow(`^stBadOpcode/badOpcodes`)
+	st.slow(`^stPreCompiledContracts/modexp`)
+	st.slow(`^stQuadraticComplexityTest/`)
+	st.slow(`^stStaticCall/static_Call50000`)
+	st.slow(`^stStaticCall/static_Return50000`)
+	st.slow(`^stSystemOperationsTest/CallRecursiveBomb`)
+	st.slow(`^stTransactionTest/Opcodes_TransactionInit`)
+	st.skipLoad(`^stTimeConsuming/`)
+	st.skipLoad(`.*vmPerformance/loop.*`)
+	st.skipLoad(`^stStaticCall/static_Call1MB`)
+	st.skipLoad(`^stEOF/`)
+	st.skipLoad(`^stEIP4844-blobtransactions/`)
+
+	// The in-repo tests run even if the test submodule was not cloned, the
+	// other directories are skipped on their own
+	for _, dir := range []string{
+		dfgStateTestDir,
+		filepath.Join(baseDir, "EIPTests", "StateTests"),
+		stateTestDir,
+		legacyStateTestDir,
+	} {
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			st.walk(t, dir, func(t *testing.T, name string, test *StateTest) {
+				for _, subtest := range test.Subtests() {
+					subtest := subtest
+					key := fmt.Sprintf("%s/%d", subtest.Fork, subtest.Index)
+
+					t.Run(key, func(t *testing.T) {
+						if err := checkDFGEquivalence(test, subtest); err != nil {
+							t.Error(err)
+						}
+					})
+				}
+			})
+		})
+	}
+}
+
+// gasRecorder is an EVMLogger recording the gas used by the transaction.
+type gasRecorder struct {
+	limit, used uint64
+}
+
+func (r *gasRecorder) CaptureTxStart(gasLimit uint64) { r.limit = gasLimit }
+func (r *gasRecorder) CaptureTxEnd(restGas uint64)    { r.used = r.limit - restGas }
+func (r *gasRecorder) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+}
+func (r *gasRecorder) CaptureEnd(output []byte, gasUsed uint64, err error) {}
+func (r *gasRecorder) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+}
+func (r *gasRecorder) CaptureExit(output []byte, gasUsed uint64, err error) {}
+func (r *gasRecorder) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+func (r *gasRecorder) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
+
+// checkDFGEquivalence executes the subtest with and without dependency graph
+// generation and compares the returned error, gas used, post-state root and
+// logs.
+func checkDFGEquivalence(test *StateTest, subtest StateSubtest) error {
+	type result struct {
+		err  error
+		gas  uint64
+		root common.Hash
+		logs common.Hash
+	}
+	run := func(cfg vm.Config) result {
+		gas := new(gasRecorder)
+		cfg.Tracer = gas
+		triedb, _, statedb, root, err := test.RunNoVerify(subtest, cfg, false, rawdb.HashScheme)
+		if triedb != nil {
+			defer triedb.Close()
+		}
+		res := result{err: err, gas: gas.used, root: root}
+		if statedb != nil {
+			res.logs = rlpHash(statedb.Logs())
+		}
+		return res
+	}
+	stock, dfg := run(vm.Config{}), run(vm.Config{EnableDFG: true})
+	if !reflect.DeepEqual(stock.err, dfg.err) {
+		return fmt.Errorf("error mismatch: stock %v, dfg %v", stock.err, dfg.err)
+	}
+	if stock.gas != dfg.gas {
+		return fmt.Errorf("gas used mismatch: stock %d, dfg %d", stock.gas, dfg.gas)
+	}
+	if stock.root != dfg.root {
+		return fmt.Errorf("post state root mismatch: stock %x, dfg %x", stock.root, dfg.root)
+	}
+	if stock.logs != dfg.logs {
+		return fmt.Errorf("post state logs hash mismatch: stock %x, dfg %x", stock.logs, dfg.logs)
+	}
+	return nil
+}
+
 // Transactions with gasLimit above this value will not get a VM trace on failure.
 const traceErrorLimit = 400000
 
@@ -266,7 +369,7 @@ func runBenchmark(b *testing.B, t *StateTest) {
 
 			// Create "contract" for sender to cache code analysis.
 			sender := vm.NewContract(vm.AccountRef(msg.From), vm.AccountRef(msg.From),
-				nil, 0)
+				nil, 0, vm.TxInputSourceMeta.Index)
 
 			var (
 				gasUsed uint64
@@ -281,7 +384,7 @@ func runBenchmark(b *testing.B, t *StateTest) {
 				start := time.Now()
 
 				// Execute the message.
-				_, leftOverGas, err := evm.Call(sender, *msg.To, msg.Data, msg.GasLimit, msg.Value)
+				_, leftOverGas, err := evm.Call(sender, *msg.To, msg.Data, msg.GasLimit, msg.Value, vm.TxInputSourceMeta.Index)
 				if err != nil {
 					b.Error(err)
 					return